@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/plugin"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
 	"github.com/spf13/cobra"
 )
 
@@ -50,7 +51,15 @@ var (
 				outputPath = base + ".difypkg"
 			}
 
-			plugin.PackagePlugin(inputPath, outputPath)
+			var vendor *packager.VendorOptions
+			if v, _ := cmd.Flags().GetBool("vendor"); v {
+				vendor = &packager.VendorOptions{
+					PythonPath: cmd.Flag("vendor_python").Value.String(),
+					IndexUrl:   cmd.Flag("vendor_index_url").Value.String(),
+				}
+			}
+
+			plugin.PackagePlugin(inputPath, outputPath, vendor)
 		},
 	}
 
@@ -172,4 +181,7 @@ func init() {
 	// pluginTestCommand.Flags().StringP("timeout", "t", "", "timeout")
 
 	pluginPackageCommand.Flags().StringP("output_path", "o", "", "output path")
+	pluginPackageCommand.Flags().Bool("vendor", false, "vendor wheels of requirements.txt for every declared arch, allows installing without network access")
+	pluginPackageCommand.Flags().String("vendor_python", "python3", "python interpreter with pip used to download wheels")
+	pluginPackageCommand.Flags().String("vendor_index_url", "", "index to download wheels from, pypi by default")
 }
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// go templates are suffixed with .tmpl to keep them out of the module's packages
+
+//go:embed templates/go/main.go.tmpl
+var GO_ENTRYPOINT_TEMPLATE []byte
+
+//go:embed templates/go/go.mod.tmpl
+var GO_MOD_TEMPLATE []byte
+
+//go:embed templates/go/tool.go.tmpl
+var GO_TOOL_TEMPLATE []byte
+
+//go:embed templates/go/tool.yaml
+var GO_TOOL_MANIFEST_TEMPLATE []byte
+
+//go:embed templates/go/tool_provider.yaml
+var GO_TOOL_PROVIDER_MANIFEST_TEMPLATE []byte
+
+//go:embed templates/go/GUIDE.md
+var GO_GUIDE []byte
+
+//go:embed templates/go/.difyignore
+var GO_DIFYIGNORE []byte
+
+//go:embed templates/go/.gitignore
+var GO_GITIGNORE []byte
+
+func createGoEnvironment(
+	root string, entrypoint string, manifest *plugin_entities.PluginDeclaration, category string,
+) error {
+	if category != "tool" {
+		return fmt.Errorf("only tool plugins are supported in go for now, got: %s", category)
+	}
+
+	files := map[string][]byte{
+		"GUIDE.md":         GO_GUIDE,
+		"go.mod":           GO_MOD_TEMPLATE,
+		entrypoint + ".go": GO_ENTRYPOINT_TEMPLATE,
+		"tool.go":          GO_TOOL_TEMPLATE,
+		filepath.Join("tools", manifest.Name+".yaml"):    GO_TOOL_MANIFEST_TEMPLATE,
+		filepath.Join("provider", manifest.Name+".yaml"): GO_TOOL_PROVIDER_MANIFEST_TEMPLATE,
+	}
+
+	for name, tmpl := range files {
+		content, err := renderTemplate(tmpl, manifest, []string{})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(root, name), content); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(filepath.Join(root, ".difyignore"), string(GO_DIFYIGNORE)); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(root, ".gitignore"), string(GO_GITIGNORE)); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -30,7 +30,7 @@ func InitPlugin() {
 				m.createPlugin()
 			}
 		} else {
-			log.Error("Error running program:", err)
+			log.Error("Error running program: %s", err)
 			return
 		}
 	}
@@ -190,6 +190,14 @@ func (m model) createPlugin() {
 		manifest.Meta.Runner.Entrypoint = "main"
 		manifest.Meta.Runner.Language = constants.Python
 		manifest.Meta.Runner.Version = "3.12"
+	case constants.Go:
+		manifest.Meta.Runner.Entrypoint = "main"
+		manifest.Meta.Runner.Language = constants.Go
+		manifest.Meta.Runner.Version = "1.22"
+	case constants.NodeJS:
+		manifest.Meta.Runner.Entrypoint = "dist/index.js"
+		manifest.Meta.Runner.Language = constants.NodeJS
+		manifest.Meta.Runner.Version = "20"
 	default:
 		log.Error("unsupported language: %s", m.subMenus[SUB_MENU_KEY_LANGUAGE].(language).Language())
 		return
@@ -248,14 +256,30 @@ func (m model) createPlugin() {
 		return
 	}
 
-	err = createPythonEnvironment(
-		pluginDir,
-		manifest.Meta.Runner.Entrypoint,
-		manifest,
-		m.subMenus[SUB_MENU_KEY_CATEGORY].(category).Category(),
-	)
+	switch manifest.Meta.Runner.Language {
+	case constants.Python:
+		err = createPythonEnvironment(
+			pluginDir,
+			manifest.Meta.Runner.Entrypoint,
+			manifest,
+			m.subMenus[SUB_MENU_KEY_CATEGORY].(category).Category(),
+		)
+	case constants.Go:
+		err = createGoEnvironment(
+			pluginDir,
+			manifest.Meta.Runner.Entrypoint,
+			manifest,
+			m.subMenus[SUB_MENU_KEY_CATEGORY].(category).Category(),
+		)
+	case constants.NodeJS:
+		err = createNodeJSEnvironment(
+			pluginDir,
+			manifest,
+			m.subMenus[SUB_MENU_KEY_CATEGORY].(category).Category(),
+		)
+	}
 	if err != nil {
-		log.Error("failed to create python environment: %s", err)
+		log.Error("failed to create %s environment: %s", manifest.Meta.Runner.Language, err)
 		return
 	}
 
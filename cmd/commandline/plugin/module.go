@@ -122,6 +122,12 @@ Permissions:
     Enabled: {{.Resource.Permission.Storage.Enabled}}
     Size: {{.Resource.Permission.Storage.Size}} bytes
   {{- end}}
+  {{- if .Resource.Permission.Network}}
+  Network:
+    {{- range .Resource.Permission.Network.Domains}}
+    - {{.}}
+    {{- end}}
+  {{- end}}
 `
 
 func ModuleList(pluginPath string) {
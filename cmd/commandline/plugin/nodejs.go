@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+//go:embed templates/nodejs/package.json
+var NODEJS_PACKAGE_JSON_TEMPLATE []byte
+
+//go:embed templates/nodejs/tsconfig.json
+var NODEJS_TSCONFIG []byte
+
+//go:embed templates/nodejs/src/index.ts
+var NODEJS_ENTRYPOINT []byte
+
+//go:embed templates/nodejs/src/protocol.ts
+var NODEJS_PROTOCOL []byte
+
+//go:embed templates/nodejs/src/tool.ts
+var NODEJS_TOOL []byte
+
+//go:embed templates/nodejs/tool.yaml
+var NODEJS_TOOL_MANIFEST_TEMPLATE []byte
+
+//go:embed templates/nodejs/tool_provider.yaml
+var NODEJS_TOOL_PROVIDER_MANIFEST_TEMPLATE []byte
+
+//go:embed templates/nodejs/GUIDE.md
+var NODEJS_GUIDE []byte
+
+//go:embed templates/nodejs/.difyignore
+var NODEJS_DIFYIGNORE []byte
+
+//go:embed templates/nodejs/.gitignore
+var NODEJS_GITIGNORE []byte
+
+func createNodeJSEnvironment(
+	root string, manifest *plugin_entities.PluginDeclaration, category string,
+) error {
+	if category != "tool" {
+		return fmt.Errorf("only tool plugins are supported in nodejs for now, got: %s", category)
+	}
+
+	templates := map[string][]byte{
+		"GUIDE.md":     NODEJS_GUIDE,
+		"package.json": NODEJS_PACKAGE_JSON_TEMPLATE,
+		filepath.Join("tools", manifest.Name+".yaml"):    NODEJS_TOOL_MANIFEST_TEMPLATE,
+		filepath.Join("provider", manifest.Name+".yaml"): NODEJS_TOOL_PROVIDER_MANIFEST_TEMPLATE,
+	}
+
+	for name, tmpl := range templates {
+		content, err := renderTemplate(tmpl, manifest, []string{})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(root, name), content); err != nil {
+			return err
+		}
+	}
+
+	// typescript sources are not rendered, generics would be taken as html tags by the template engine
+	files := map[string][]byte{
+		"tsconfig.json":                     NODEJS_TSCONFIG,
+		filepath.Join("src", "index.ts"):    NODEJS_ENTRYPOINT,
+		filepath.Join("src", "protocol.ts"): NODEJS_PROTOCOL,
+		filepath.Join("src", "tool.ts"):     NODEJS_TOOL,
+		".difyignore":                       NODEJS_DIFYIGNORE,
+		".gitignore":                        NODEJS_GITIGNORE,
+	}
+
+	for name, content := range files {
+		if err := writeFile(filepath.Join(root, name), string(content)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -12,7 +12,8 @@ var (
 	MaxPluginPackageSize = int64(52428800) // 50MB
 )
 
-func PackagePlugin(inputPath string, outputPath string) {
+// PackagePlugin packs the plugin directory into a .difypkg, wheels are vendored into it if vendor is not nil
+func PackagePlugin(inputPath string, outputPath string, vendor *packager.VendorOptions) {
 	decoder, err := decoder.NewFSPluginDecoder(inputPath)
 	if err != nil {
 		log.Error("failed to create plugin decoder , plugin path: %s, error: %v", inputPath, err)
@@ -21,7 +22,17 @@ func PackagePlugin(inputPath string, outputPath string) {
 	}
 
 	packager := packager.NewPackager(decoder)
+	if vendor != nil {
+		log.Info("downloading wheels, it may take a while")
+		if err := packager.Vendor(*vendor); err != nil {
+			log.Error("failed to vendor wheels: %v", err)
+			os.Exit(1)
+			return
+		}
+	}
+
 	zipFile, err := packager.Pack(MaxPluginPackageSize)
+	packager.Cleanup()
 
 	if err != nil {
 		log.Error("failed to package plugin %v", err)
@@ -1,6 +1,10 @@
 package plugin
 
 import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -36,3 +40,72 @@ func TestRenderPythonToolTemplate(t *testing.T) {
 		t.Errorf("template content does not contain TestTool, snakeToCamel failed")
 	}
 }
+
+func TestCreateGoEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	manifest := &plugin_entities.PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: plugin_entities.PluginDeclarationWithoutAdvancedFields{
+			Name:   "test",
+			Author: "test",
+			Description: plugin_entities.I18nObject{
+				EnUS: "test",
+			},
+		},
+	}
+
+	root := t.TempDir()
+	if err := createGoEnvironment(root, "main", manifest, "tool"); err != nil {
+		t.Fatalf("failed to create go environment: %v", err)
+	}
+
+	// the generated plugin should compile as is
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated plugin does not compile: %v, output: %s", err, output)
+	}
+
+	if err := createGoEnvironment(t.TempDir(), "main", manifest, "llm"); err == nil {
+		t.Fatalf("expected an error for unsupported category")
+	}
+}
+
+func TestCreateNodeJSEnvironment(t *testing.T) {
+	manifest := &plugin_entities.PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: plugin_entities.PluginDeclarationWithoutAdvancedFields{
+			Name:    "test",
+			Author:  "test",
+			Version: "0.0.1",
+			Description: plugin_entities.I18nObject{
+				EnUS: "test",
+			},
+		},
+	}
+
+	root := t.TempDir()
+	if err := createNodeJSEnvironment(root, manifest, "tool"); err != nil {
+		t.Fatalf("failed to create nodejs environment: %v", err)
+	}
+
+	packageJson, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := map[string]any{}
+	if err := json.Unmarshal(packageJson, &pkg); err != nil {
+		t.Fatalf("package.json is not valid json: %v", err)
+	}
+	if pkg["name"] != "test" || pkg["version"] != "0.0.1" {
+		t.Fatalf("package.json is not rendered: %s", packageJson)
+	}
+
+	for _, file := range []string{"src/index.ts", "src/protocol.ts", "src/tool.ts", "tools/test.yaml", "provider/test.yaml"} {
+		if _, err := os.Stat(filepath.Join(root, file)); err != nil {
+			t.Fatalf("%s is not created: %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+import { serve } from "./protocol";
+import { invokeTool, validateCredentials } from "./tool";
+
+serve({ invokeTool, validateCredentials });
@@ -0,0 +1,96 @@
+// the plugin talks to the plugin daemon through stdin/stdout,
+// every line on stdin is a request and every line written to stdout is an event in JSON
+
+import * as readline from "readline";
+
+type Request = {
+  session_id: string;
+  event: string;
+  data: {
+    action: string;
+    tool?: string;
+    tool_parameters?: { [key: string]: unknown };
+    credentials?: { [key: string]: unknown };
+  };
+};
+
+function emit(sessionId: string, event: string, data: unknown): void {
+  process.stdout.write(JSON.stringify({ session_id: sessionId, event, data }) + "\n");
+}
+
+function stream(sessionId: string, data: unknown): void {
+  emit(sessionId, "session", { type: "stream", data });
+}
+
+function end(sessionId: string): void {
+  emit(sessionId, "session", { type: "end", data: {} });
+}
+
+function fail(sessionId: string, errorType: string, message: string): void {
+  emit(sessionId, "session", {
+    type: "error",
+    data: { error_type: errorType, message, args: {} },
+  });
+}
+
+// log writes a log line which shows up in the plugin logs of the daemon
+export function log(level: string, message: string): void {
+  emit("", "log", { level, message, timestamp: Date.now() / 1000 });
+}
+
+export type Handlers = {
+  invokeTool: (
+    tool: string,
+    credentials: { [key: string]: unknown },
+    parameters: { [key: string]: unknown },
+    send: (chunk: { [key: string]: unknown }) => void,
+  ) => Promise<void>;
+  validateCredentials: (credentials: { [key: string]: unknown }) => Promise<void>;
+};
+
+async function handle(handlers: Handlers, request: Request): Promise<void> {
+  const sessionId = request.session_id;
+  const data = request.data;
+
+  try {
+    switch (data.action) {
+      case "invoke_tool":
+        await handlers.invokeTool(data.tool ?? "", data.credentials ?? {}, data.tool_parameters ?? {}, (chunk) =>
+          stream(sessionId, chunk),
+        );
+        break;
+      case "validate_tool_credentials":
+        await handlers.validateCredentials(data.credentials ?? {});
+        stream(sessionId, { result: true });
+        break;
+      default:
+        fail(sessionId, "NotImplementedError", `action not implemented: ${data.action}`);
+        return;
+    }
+    end(sessionId);
+  } catch (e) {
+    fail(sessionId, "ToolInvokeError", e instanceof Error ? e.message : String(e));
+  }
+}
+
+// serve reads requests until stdin is closed
+export function serve(handlers: Handlers): void {
+  // heartbeat keeps the daemon from restarting the plugin
+  emit("", "heartbeat", {});
+  setInterval(() => emit("", "heartbeat", {}), 10 * 1000);
+
+  readline.createInterface({ input: process.stdin }).on("line", (line) => {
+    let request: Request;
+    try {
+      request = JSON.parse(line);
+    } catch (e) {
+      emit("", "error", { message: String(e) });
+      return;
+    }
+    if (request.event === "request") {
+      void handle(handlers, request);
+    }
+  });
+
+  process.stdin.on("end", () => process.exit(0));
+}
@@ -0,0 +1,21 @@
+import { log } from "./protocol";
+
+type Chunk = { [key: string]: unknown };
+
+// validateCredentials checks the credentials configured for the provider, throw to reject them
+export async function validateCredentials(credentials: { [key: string]: unknown }): Promise<void> {}
+
+// invokeTool runs the tool, each chunk passed to send is a tool response message
+export async function invokeTool(
+  tool: string,
+  credentials: { [key: string]: unknown },
+  parameters: { [key: string]: unknown },
+  send: (chunk: Chunk) => void,
+): Promise<void> {
+  log("info", `invoking ${tool}`);
+
+  send({
+    type: "json",
+    message: { json_object: { result: "Hello, world!" } },
+  });
+}
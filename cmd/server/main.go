@@ -25,5 +25,9 @@ func main() {
 		log.Panic("Invalid configuration: %s", err.Error())
 	}
 
+	if err := log.Init(config.LogLevel, config.LogFormat); err != nil {
+		log.Panic("Invalid log settings: %s", err.Error())
+	}
+
 	(&server.App{}).Run(&config)
 }
@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// NodeSelectionStrategy decides which node a redirected request goes to
+// when a plugin is available on multiple nodes of the cluster
+type NodeSelectionStrategy string
+
+const (
+	// requests are distributed to the nodes one by one
+	NODE_SELECTION_STRATEGY_ROUND_ROBIN NodeSelectionStrategy = "round_robin"
+	// requests go to the node with the least in-flight sessions
+	NODE_SELECTION_STRATEGY_LEAST_SESSIONS NodeSelectionStrategy = "least_sessions"
+	// requests of the same tenant always go to the same node until the node leaves the cluster
+	NODE_SELECTION_STRATEGY_CONSISTENT_HASH NodeSelectionStrategy = "consistent_hash"
+)
+
+const (
+	// once a redirection to a node failed, the node will be deprioritized for $NODE_UNHEALTHY_COOLDOWN
+	NODE_UNHEALTHY_COOLDOWN = time.Second * 10
+)
+
+// nodeCandidate is a node which is able to serve the request
+type nodeCandidate struct {
+	ID   string
+	Load nodeLoad
+}
+
+// NodeSelector orders candidates by preference, the first one is the most preferred
+// key is the routing key of the request, like tenant id, it's used by hashing strategies
+type NodeSelector interface {
+	Select(key string, candidates []nodeCandidate) []string
+}
+
+func newNodeSelector(strategy NodeSelectionStrategy) NodeSelector {
+	switch strategy {
+	case NODE_SELECTION_STRATEGY_LEAST_SESSIONS:
+		return &leastSessionsSelector{}
+	case NODE_SELECTION_STRATEGY_CONSISTENT_HASH:
+		return &consistentHashSelector{}
+	default:
+		return &roundRobinSelector{}
+	}
+}
+
+type roundRobinSelector struct {
+	counter uint64
+}
+
+func (s *roundRobinSelector) Select(key string, candidates []nodeCandidate) []string {
+	result := make([]string, len(candidates))
+	if len(candidates) == 0 {
+		return result
+	}
+
+	// sort to keep the order stable between calls
+	ids := candidateIds(candidates)
+	sort.Strings(ids)
+
+	offset := int(atomic.AddUint64(&s.counter, 1) % uint64(len(ids)))
+	for i := range ids {
+		result[i] = ids[(offset+i)%len(ids)]
+	}
+
+	return result
+}
+
+type leastSessionsSelector struct{}
+
+func (s *leastSessionsSelector) Select(key string, candidates []nodeCandidate) []string {
+	sorted := make([]nodeCandidate, len(candidates))
+	copy(sorted, candidates)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Load.Sessions == sorted[j].Load.Sessions {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].Load.Sessions < sorted[j].Load.Sessions
+	})
+
+	return candidateIds(sorted)
+}
+
+// consistentHashSelector uses rendezvous hashing, only the requests routed to
+// a node which has left the cluster will be moved to other nodes
+type consistentHashSelector struct{}
+
+func (s *consistentHashSelector) Select(key string, candidates []nodeCandidate) []string {
+	type weighted struct {
+		id     string
+		weight uint64
+	}
+
+	weights := make([]weighted, 0, len(candidates))
+	for _, candidate := range candidates {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		hash.Write([]byte{':'})
+		hash.Write([]byte(candidate.ID))
+		weights = append(weights, weighted{id: candidate.ID, weight: hash.Sum64()})
+	}
+
+	sort.Slice(weights, func(i, j int) bool {
+		return weights[i].weight > weights[j].weight
+	})
+
+	result := make([]string, len(weights))
+	for i, w := range weights {
+		result[i] = w.id
+	}
+
+	return result
+}
+
+func candidateIds(candidates []nodeCandidate) []string {
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.ID
+	}
+	return ids
+}
+
+// SelectNodes orders the given nodes by the node selection strategy of the cluster,
+// nodes failed recently will be put at the end
+func (c *Cluster) SelectNodes(key string, nodeIds []string) []string {
+	candidates := make([]nodeCandidate, 0, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		node, ok := c.nodes.Load(nodeId)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, nodeCandidate{ID: nodeId, Load: node.Load})
+	}
+
+	selected := c.nodeSelector.Select(key, candidates)
+
+	healthy := make([]string, 0, len(selected))
+	unhealthy := make([]string, 0)
+	for _, nodeId := range selected {
+		if c.isNodeUnhealthy(nodeId) {
+			unhealthy = append(unhealthy, nodeId)
+		} else {
+			healthy = append(healthy, nodeId)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (c *Cluster) markNodeUnhealthy(nodeId string) {
+	c.unhealthyNodes.Store(nodeId, time.Now())
+}
+
+func (c *Cluster) isNodeUnhealthy(nodeId string) bool {
+	failedAt, ok := c.unhealthyNodes.Load(nodeId)
+	if !ok {
+		return false
+	}
+
+	if time.Since(failedAt) > NODE_UNHEALTHY_COOLDOWN {
+		c.unhealthyNodes.Delete(nodeId)
+		return false
+	}
+
+	return true
+}
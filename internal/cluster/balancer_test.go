@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"testing"
+)
+
+func TestRoundRobinSelector(t *testing.T) {
+	selector := newNodeSelector(NODE_SELECTION_STRATEGY_ROUND_ROBIN)
+	candidates := []nodeCandidate{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	counts := map[string]int{}
+	for i := 0; i < 30; i++ {
+		selected := selector.Select("", candidates)
+		if len(selected) != len(candidates) {
+			t.Fatalf("all candidates should be returned, got %v", selected)
+		}
+		counts[selected[0]]++
+	}
+
+	for _, candidate := range candidates {
+		if counts[candidate.ID] != 10 {
+			t.Fatalf("requests should be distributed evenly, got %v", counts)
+		}
+	}
+}
+
+func TestLeastSessionsSelector(t *testing.T) {
+	selector := newNodeSelector(NODE_SELECTION_STRATEGY_LEAST_SESSIONS)
+	selected := selector.Select("", []nodeCandidate{
+		{ID: "a", Load: nodeLoad{Sessions: 10}},
+		{ID: "b", Load: nodeLoad{Sessions: 1}},
+		{ID: "c", Load: nodeLoad{Sessions: 5}},
+	})
+
+	if selected[0] != "b" || selected[1] != "c" || selected[2] != "a" {
+		t.Fatalf("nodes should be ordered by sessions, got %v", selected)
+	}
+}
+
+func TestConsistentHashSelector(t *testing.T) {
+	selector := newNodeSelector(NODE_SELECTION_STRATEGY_CONSISTENT_HASH)
+	candidates := []nodeCandidate{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+
+	first := selector.Select("tenant", candidates)
+	for i := 0; i < 10; i++ {
+		if selector.Select("tenant", candidates)[0] != first[0] {
+			t.Fatalf("same key should always be routed to the same node")
+		}
+	}
+
+	// remove a node which is not selected, the selected node should not change
+	remaining := []nodeCandidate{}
+	for _, candidate := range candidates {
+		if candidate.ID != first[len(first)-1] {
+			remaining = append(remaining, candidate)
+		}
+	}
+
+	if selector.Select("tenant", remaining)[0] != first[0] {
+		t.Fatalf("removing other nodes should not affect the selected node")
+	}
+}
+
+func TestSelectNodesDeprioritizeUnhealthyNodes(t *testing.T) {
+	c := &Cluster{nodeSelector: newNodeSelector(NODE_SELECTION_STRATEGY_LEAST_SESSIONS)}
+	c.nodes.Store("a", node{Load: nodeLoad{Sessions: 1}})
+	c.nodes.Store("b", node{Load: nodeLoad{Sessions: 2}})
+
+	c.markNodeUnhealthy("a")
+
+	selected := c.SelectNodes("", []string{"a", "b", "unknown"})
+	if len(selected) != 2 {
+		t.Fatalf("unknown nodes should be filtered, got %v", selected)
+	}
+
+	if selected[0] != "b" || selected[1] != "a" {
+		t.Fatalf("unhealthy nodes should be put at the end, got %v", selected)
+	}
+}
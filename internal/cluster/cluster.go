@@ -30,6 +30,19 @@ type Cluster struct {
 	// nodes stores all the nodes of the cluster
 	nodes mapping.Map[string, node]
 
+	// nodeSelector decides which node a redirected request goes to
+	nodeSelector NodeSelector
+
+	// proxy forwards redirected requests to other nodes
+	proxy *nodeProxy
+
+	// replicas of local plugins, 0 means every node
+	pluginReplicasDefault  int
+	pluginReplicasOverride map[string]int
+
+	// unhealthyNodes stores the nodes failed to serve redirected requests recently
+	unhealthyNodes mapping.Map[string, time.Time]
+
 	// signals for waiting for the cluster to stop
 	stopChan chan bool
 	stopped  int32
@@ -63,8 +76,19 @@ type Cluster struct {
 }
 
 func NewCluster(config *app.Config, plugin_manager *plugin_manager.PluginManager) *Cluster {
+	id := uuid.New().String()
+
+	// all nodes share the same server key, use it if no dedicated secret configured
+	nodeSecret := config.ClusterNodeSecret
+	if nodeSecret == "" {
+		nodeSecret = config.ServerKey
+	}
+
+	// validated when loading config
+	pluginReplicasOverride, _ := config.PluginLocalReplicasOverrideMap()
+
 	return &Cluster{
-		id:                            uuid.New().String(),
+		id:                            id,
 		port:                          uint16(config.ServerPort),
 		stopChan:                      make(chan bool),
 		showLog:                       config.DisplayClusterLog,
@@ -79,6 +103,11 @@ func NewCluster(config *app.Config, plugin_manager *plugin_manager.PluginManager
 		pluginDeactivatedTimeout:      PLUGIN_DEACTIVATED_TIMEOUT,
 
 		manager: plugin_manager,
+		proxy:   newNodeProxy(id, nodeSecret),
+
+		pluginReplicasDefault:  config.PluginLocalReplicas,
+		pluginReplicasOverride: pluginReplicasOverride,
+		nodeSelector:           newNodeSelector(NodeSelectionStrategy(config.ClusterNodeSelectionStrategy)),
 
 		notifyBecomeMasterChan:            make(chan bool),
 		notifyMasterGcChan:                make(chan bool),
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 )
@@ -101,6 +102,14 @@ func (c *Cluster) clusterLifetime() {
 	newNodeChan, cancel := cache.Subscribe[newNodeEvent](CLUSTER_NEW_NODE_CHANNEL)
 	defer cancel()
 
+	pluginPlacementChan, cancelPluginPlacement := cache.Subscribe[pluginPlacementEvent](CLUSTER_PLUGIN_PLACEMENT_CHANNEL)
+	defer cancelPluginPlacement()
+
+	declarationUpdatedChan, cancelDeclarationUpdated := cache.Subscribe[helper.PluginDeclarationUpdatedEvent](
+		helper.PLUGIN_DECLARATION_UPDATED_CHANNEL,
+	)
+	defer cancelDeclarationUpdated()
+
 	for {
 		select {
 		case <-tickerLockMaster.C:
@@ -138,6 +147,10 @@ func (c *Cluster) clusterLifetime() {
 					log.Error("failed to gc the plugins have already stopped: %s", err.Error())
 				}
 				c.notifyMasterGCCompleted()
+				// nodes may have left the cluster, replace their plugins
+				if err := c.placePlugins(); err != nil {
+					log.Error("failed to place the plugins: %s", err.Error())
+				}
 			}
 		case <-nodeVoteTicker.C:
 			if err := c.voteAddresses(); err != nil {
@@ -149,6 +162,25 @@ func (c *Cluster) clusterLifetime() {
 				if err := c.voteAddresses(); err != nil {
 					log.Error("failed to vote the ips of the nodes: %s", err.Error())
 				}
+				// rebalance plugins onto the new node
+				if err := c.updateNodeStatus(); err != nil {
+					log.Error("failed to update the status of the node: %s", err.Error())
+				}
+				if err := c.placePlugins(); err != nil {
+					log.Error("failed to place the plugins: %s", err.Error())
+				}
+			}
+		case _, ok := <-pluginPlacementChan:
+			if ok {
+				routine.Submit(map[string]string{
+					"module":   "cluster",
+					"function": "syncPlacedPlugins",
+				}, c.syncPlacedPlugins)
+			}
+		case event, ok := <-declarationUpdatedChan:
+			if ok {
+				// declaration of a debugging plugin is replaced, drop the stale memory cache
+				helper.OnPluginDeclarationUpdated(event)
 			}
 		case <-pluginSchedulerTicker.C:
 			if err := c.schedulePlugins(); err != nil {
@@ -23,6 +23,15 @@ type vote struct {
 type node struct {
 	Addresses  []address `json:"ips"`
 	LastPingAt int64     `json:"last_ping_at"`
+	Load       nodeLoad  `json:"load"`
+}
+
+// nodeLoad is published by each node along with its status, used to balance redirected requests
+type nodeLoad struct {
+	// number of in-flight sessions of the node
+	Sessions int64 `json:"sessions"`
+	// number of plugins running on the node
+	Plugins int64 `json:"plugins"`
 }
 
 type newNodeEvent struct {
@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
@@ -72,6 +73,12 @@ func (c *Cluster) updateNodeStatus() error {
 	// refresh the last ping time
 	nodeStatus.LastPingAt = time.Now().Unix()
 
+	// publish current load of the node
+	nodeStatus.Load = nodeLoad{
+		Sessions: int64(session_manager.Count()),
+		Plugins:  int64(c.plugins.Len()),
+	}
+
 	// update the status of the node
 	if err := cache.SetMapOneField(CLUSTER_STATUS_HASH_MAP_KEY, c.id, nodeStatus); err != nil {
 		return err
@@ -114,6 +121,29 @@ func (c *Cluster) GetNodes() (map[string]node, error) {
 
 // FetchPluginAvailableNodesByHashedId fetches the available nodes of the given plugin
 func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]string, error) {
+	return c.fetchPluginNodes(hashedPluginId, func(state plugin_entities.PluginRuntimeState) bool {
+		// draining and quarantined plugins accept no new sessions
+		return !state.Draining && state.Status != plugin_entities.PLUGIN_RUNTIME_STATUS_QUARANTINED
+	})
+}
+
+func (c *Cluster) FetchPluginAvailableNodesById(plugin_id string) ([]string, error) {
+	hashedPluginId := plugin_entities.HashedIdentity(plugin_id)
+	return c.FetchPluginAvailableNodesByHashedId(hashedPluginId)
+}
+
+// FetchPluginNodesById returns all nodes running the plugin, draining ones included,
+// requests about the runtime itself like its logs are redirected to them
+func (c *Cluster) FetchPluginNodesById(plugin_id string) ([]string, error) {
+	hashedPluginId := plugin_entities.HashedIdentity(plugin_id)
+	return c.fetchPluginNodes(hashedPluginId, func(state plugin_entities.PluginRuntimeState) bool {
+		return true
+	})
+}
+
+func (c *Cluster) fetchPluginNodes(
+	hashedPluginId string, accepts func(state plugin_entities.PluginRuntimeState) bool,
+) ([]string, error) {
 	states, err := cache.ScanMap[plugin_entities.PluginRuntimeState](
 		PLUGIN_STATE_MAP_KEY, c.getScanPluginsByIdKey(hashedPluginId),
 	)
@@ -122,7 +152,10 @@ func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]
 	}
 
 	nodes := make([]string, 0)
-	for key := range states {
+	for key, state := range states {
+		if !accepts(state) {
+			continue
+		}
 		nodeId, _, err := c.splitNodePluginJoin(key)
 		if err != nil {
 			continue
@@ -135,15 +168,15 @@ func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]
 	return nodes, nil
 }
 
-func (c *Cluster) FetchPluginAvailableNodesById(plugin_id string) ([]string, error) {
-	hashedPluginId := plugin_entities.HashedIdentity(plugin_id)
-	return c.FetchPluginAvailableNodesByHashedId(hashedPluginId)
-}
-
 func (c *Cluster) IsMaster() bool {
 	return c.iAmMaster
 }
 
+// NodeCount returns the number of alive nodes known by current node
+func (c *Cluster) NodeCount() int {
+	return c.nodes.Len()
+}
+
 func (c *Cluster) IsNodeAlive(nodeId string) bool {
 	nodeStatus, err := cache.GetMapField[node](CLUSTER_STATUS_HASH_MAP_KEY, nodeId)
 	if err != nil {
@@ -0,0 +1,300 @@
+package cluster
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// Local plugins are placed onto a subset of the cluster nodes by the master,
+// each plugin runs on $replicas nodes, replicas could be overridden per plugin
+// and 0 means the plugin runs on every node.
+//
+// The master recomputes placements periodically and whenever a node joins or leaves,
+// nodes already running a plugin are preferred to avoid unnecessary restarts,
+// once placements changed, a placement event is published to notify all nodes to
+// launch the plugins assigned to them and stop the ones moved away.
+//
+// State:
+//	- hashmap[plugin-placement]
+//		- plugin_unique_identifier:
+//			- nodes: list[node_id]
+//			- replicas: int
+//			- updated_at: int64
+
+const (
+	PLUGIN_PLACEMENT_MAP_KEY         = "cluster-plugin-placement-hash-map"
+	CLUSTER_PLUGIN_PLACEMENT_CHANNEL = "cluster-plugin-placement-channel"
+)
+
+type pluginPlacement struct {
+	Nodes     []string `json:"nodes"`
+	Replicas  int      `json:"replicas"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+type pluginPlacementEvent struct {
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// pluginReplicas returns how many nodes the plugin should run on, 0 means every node
+func (c *Cluster) pluginReplicas(pluginId string) int {
+	if replicas, ok := c.pluginReplicasOverride[pluginId]; ok {
+		return replicas
+	}
+	return c.pluginReplicasDefault
+}
+
+// IsPluginPlacedOnCurrentNode returns true if the local plugin should run on current node
+func (c *Cluster) IsPluginPlacedOnCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool {
+	if c.pluginReplicas(identity.PluginID()) <= 0 {
+		return true
+	}
+
+	placement, err := cache.GetMapField[pluginPlacement](PLUGIN_PLACEMENT_MAP_KEY, identity.String())
+	if err == cache.ErrNotFound {
+		// not placed by master yet, it will be launched once placed
+		return false
+	} else if err != nil {
+		// keep the plugin available if the placement is unknown
+		log.Error("failed to fetch placement of plugin %s: %s", identity.String(), err.Error())
+		return true
+	}
+
+	return slices.Contains(placement.Nodes, c.id)
+}
+
+// PlaceInstalledPlugin records current node as the initial placement of a newly installed plugin,
+// so it's not stopped before the master places it, the missing replicas are filled by the master later.
+// returns whether the plugin should be launched on current node
+func (c *Cluster) PlaceInstalledPlugin(identity plugin_entities.PluginUniqueIdentifier) (bool, error) {
+	if c.pluginReplicas(identity.PluginID()) <= 0 {
+		return true, nil
+	}
+
+	placed, err := cache.SetMapOneFieldNX(PLUGIN_PLACEMENT_MAP_KEY, identity.String(), pluginPlacement{
+		Nodes:     []string{c.id},
+		Replicas:  c.pluginReplicas(identity.PluginID()),
+		UpdatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if placed || c.IsPluginPlacedOnCurrentNode(identity) {
+		return true, nil
+	}
+
+	// already placed onto other nodes, notify them to launch it
+	return false, cache.Publish(CLUSTER_PLUGIN_PLACEMENT_CHANNEL, pluginPlacementEvent{UpdatedAt: time.Now().Unix()})
+}
+
+// placePlugins computes the placements of all installed local plugins, only the master does it
+func (c *Cluster) placePlugins() error {
+	if c.manager == nil || !c.iAmMaster {
+		return nil
+	}
+
+	installed, err := c.manager.InstalledLocalPlugins()
+	if err != nil {
+		return err
+	}
+
+	current, err := cache.GetMap[pluginPlacement](PLUGIN_PLACEMENT_MAP_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return err
+	}
+
+	nodes := []string{}
+	c.nodes.Range(func(nodeId string, _ node) bool {
+		nodes = append(nodes, nodeId)
+		return true
+	})
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	plugins := make([]string, 0, len(installed))
+	replicas := map[string]int{}
+	running := map[string][]string{}
+	for _, identity := range installed {
+		r := c.pluginReplicas(identity.PluginID())
+		if r <= 0 {
+			// plugin runs on every node, no placement needed
+			continue
+		}
+
+		plugins = append(plugins, identity.String())
+		replicas[identity.String()] = r
+
+		runningNodes, err := c.FetchPluginAvailableNodesById(identity.String())
+		if err == nil {
+			running[identity.String()] = runningNodes
+		}
+	}
+
+	planned := planPluginPlacements(plugins, replicas, current, running, nodes)
+
+	changed := false
+	now := time.Now().Unix()
+	for plugin, placement := range planned {
+		if existing, ok := current[plugin]; ok && existing.Replicas == placement.Replicas &&
+			slices.Equal(existing.Nodes, placement.Nodes) {
+			continue
+		}
+
+		placement.UpdatedAt = now
+		if err := cache.SetMapOneField(PLUGIN_PLACEMENT_MAP_KEY, plugin, placement); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	// remove placements of uninstalled plugins
+	for plugin := range current {
+		if _, ok := planned[plugin]; !ok {
+			if err := cache.DelMapField(PLUGIN_PLACEMENT_MAP_KEY, plugin); err != nil {
+				return err
+			}
+			changed = true
+		}
+	}
+
+	if changed {
+		if c.showLog {
+			log.Info("plugin placements changed, notifying all nodes")
+		}
+		return cache.Publish(CLUSTER_PLUGIN_PLACEMENT_CHANNEL, pluginPlacementEvent{UpdatedAt: now})
+	}
+
+	return nil
+}
+
+// planPluginPlacements places each plugin onto $replicas nodes
+//   - existing placements on alive nodes are kept
+//   - nodes already running the plugin are preferred, then the least loaded nodes
+//   - replicas are moved from the most loaded nodes to the least loaded ones until balanced
+func planPluginPlacements(
+	plugins []string,
+	replicas map[string]int,
+	current map[string]pluginPlacement,
+	running map[string][]string,
+	nodes []string,
+) map[string]pluginPlacement {
+	nodes = slices.Clone(nodes)
+	sort.Strings(nodes)
+	plugins = slices.Clone(plugins)
+	sort.Strings(plugins)
+
+	load := map[string]int{}
+	for _, node := range nodes {
+		load[node] = 0
+	}
+
+	wanted := func(plugin string) int {
+		r := replicas[plugin]
+		if r <= 0 || r > len(nodes) {
+			return len(nodes)
+		}
+		return r
+	}
+
+	result := map[string]pluginPlacement{}
+
+	// keep existing placements on alive nodes
+	for _, plugin := range plugins {
+		placed := []string{}
+		for _, node := range current[plugin].Nodes {
+			if _, alive := load[node]; alive && !slices.Contains(placed, node) && len(placed) < wanted(plugin) {
+				placed = append(placed, node)
+				load[node]++
+			}
+		}
+		result[plugin] = pluginPlacement{Nodes: placed, Replicas: replicas[plugin]}
+	}
+
+	// fill the missing replicas
+	for _, plugin := range plugins {
+		placement := result[plugin]
+		for len(placement.Nodes) < wanted(plugin) {
+			candidate := ""
+			for _, node := range running[plugin] {
+				if _, alive := load[node]; alive && !slices.Contains(placement.Nodes, node) {
+					candidate = node
+					break
+				}
+			}
+
+			if candidate == "" {
+				for _, node := range nodes {
+					if slices.Contains(placement.Nodes, node) {
+						continue
+					}
+					if candidate == "" || load[node] < load[candidate] {
+						candidate = node
+					}
+				}
+			}
+
+			placement.Nodes = append(placement.Nodes, candidate)
+			load[candidate]++
+		}
+		result[plugin] = placement
+	}
+
+	// rebalance, a moving replica restarts the plugin, so only move when the gap is larger than 1
+	for i := 0; i < len(plugins)*len(nodes); i++ {
+		busiest, idlest := nodes[0], nodes[0]
+		for _, node := range nodes {
+			if load[node] > load[busiest] {
+				busiest = node
+			}
+			if load[node] < load[idlest] {
+				idlest = node
+			}
+		}
+
+		if load[busiest]-load[idlest] <= 1 {
+			break
+		}
+
+		moved := false
+		for _, plugin := range plugins {
+			placement := result[plugin]
+			index := slices.Index(placement.Nodes, busiest)
+			if index == -1 || slices.Contains(placement.Nodes, idlest) {
+				continue
+			}
+
+			placement.Nodes[index] = idlest
+			result[plugin] = placement
+			load[busiest]--
+			load[idlest]++
+			moved = true
+			break
+		}
+
+		if !moved {
+			break
+		}
+	}
+
+	for plugin, placement := range result {
+		sort.Strings(placement.Nodes)
+		result[plugin] = placement
+	}
+
+	return result
+}
+
+// syncPlacedPlugins launches the plugins placed onto current node and stops the ones moved away
+func (c *Cluster) syncPlacedPlugins() {
+	if c.manager == nil {
+		return
+	}
+	c.manager.SyncLocalPlugins()
+}
@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func placementLoad(placements map[string]pluginPlacement) map[string]int {
+	load := map[string]int{}
+	for _, placement := range placements {
+		for _, node := range placement.Nodes {
+			load[node]++
+		}
+	}
+	return load
+}
+
+func TestPlanPluginPlacementsReplicas(t *testing.T) {
+	plugins := []string{}
+	replicas := map[string]int{}
+	for i := 0; i < 10; i++ {
+		plugin := fmt.Sprintf("plugin-%d", i)
+		plugins = append(plugins, plugin)
+		replicas[plugin] = 2
+	}
+	// override, more replicas than nodes means every node
+	replicas["plugin-0"] = 100
+
+	nodes := []string{"a", "b", "c", "d", "e"}
+	placements := planPluginPlacements(plugins, replicas, nil, nil, nodes)
+
+	for _, plugin := range plugins {
+		expected := 2
+		if plugin == "plugin-0" {
+			expected = len(nodes)
+		}
+		if len(placements[plugin].Nodes) != expected {
+			t.Fatalf("plugin %s should be placed onto %d nodes, got %v", plugin, expected, placements[plugin].Nodes)
+		}
+	}
+
+	load := placementLoad(placements)
+	for _, node := range nodes {
+		if load[node] < 4 || load[node] > 5 {
+			t.Fatalf("plugins should be placed evenly, got %v", load)
+		}
+	}
+}
+
+func TestPlanPluginPlacementsNodeLeaves(t *testing.T) {
+	plugins := []string{"plugin-0", "plugin-1"}
+	replicas := map[string]int{"plugin-0": 2, "plugin-1": 2}
+	current := map[string]pluginPlacement{
+		"plugin-0": {Nodes: []string{"a", "b"}, Replicas: 2},
+		"plugin-1": {Nodes: []string{"b", "c"}, Replicas: 2},
+	}
+
+	// node b left the cluster
+	placements := planPluginPlacements(plugins, replicas, current, nil, []string{"a", "c"})
+
+	if !slices.Equal(placements["plugin-0"].Nodes, []string{"a", "c"}) {
+		t.Fatalf("plugin-0 should be moved from b to c, got %v", placements["plugin-0"].Nodes)
+	}
+	if !slices.Equal(placements["plugin-1"].Nodes, []string{"a", "c"}) {
+		t.Fatalf("plugin-1 should be moved from b to a, got %v", placements["plugin-1"].Nodes)
+	}
+}
+
+func TestPlanPluginPlacementsNodeJoins(t *testing.T) {
+	plugins := []string{"plugin-0", "plugin-1", "plugin-2", "plugin-3"}
+	replicas := map[string]int{}
+	current := map[string]pluginPlacement{}
+	for _, plugin := range plugins {
+		replicas[plugin] = 1
+		current[plugin] = pluginPlacement{Nodes: []string{"a"}, Replicas: 1}
+	}
+
+	placements := planPluginPlacements(plugins, replicas, current, nil, []string{"a", "b"})
+
+	load := placementLoad(placements)
+	if load["a"] != 2 || load["b"] != 2 {
+		t.Fatalf("plugins should be rebalanced onto the new node, got %v", load)
+	}
+}
+
+func TestPlanPluginPlacementsPrefersRunningNodes(t *testing.T) {
+	placements := planPluginPlacements(
+		[]string{"plugin-0"},
+		map[string]int{"plugin-0": 1},
+		nil,
+		map[string][]string{"plugin-0": {"c"}},
+		[]string{"a", "b", "c"},
+	)
+
+	if !slices.Equal(placements["plugin-0"].Nodes, []string{"c"}) {
+		t.Fatalf("node already running the plugin should be preferred, got %v", placements["plugin-0"].Nodes)
+	}
+}
+
+func TestPlaceInstalledPlugin(t *testing.T) {
+	clusters, err := createSimulationCluster(2)
+	if err != nil {
+		t.Errorf("create simulation cluster failed: %v", err)
+		return
+	}
+	for _, cluster := range clusters {
+		cluster.pluginReplicasDefault = 1
+	}
+
+	identity, err := plugin_entities.NewPluginUniqueIdentifier("langgenius/placement:0.0.1@1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.DelMapField(PLUGIN_PLACEMENT_MAP_KEY, identity.String()); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.DelMapField(PLUGIN_PLACEMENT_MAP_KEY, identity.String())
+
+	// the installing node is recorded as the initial placement, before the master places it
+	placed, err := clusters[0].PlaceInstalledPlugin(identity)
+	if err != nil || !placed {
+		t.Fatalf("plugin should be placed onto the installing node, got %v, %v", placed, err)
+	}
+	if !clusters[0].IsPluginPlacedOnCurrentNode(identity) {
+		t.Fatal("installing node should keep the plugin running")
+	}
+
+	// installed again on another node, the existing placement is kept
+	placed, err = clusters[1].PlaceInstalledPlugin(identity)
+	if err != nil || placed {
+		t.Fatalf("plugin should stay on the node it was placed onto, got %v, %v", placed, err)
+	}
+}
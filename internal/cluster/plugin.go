@@ -286,8 +286,29 @@ func (c *Cluster) autoGCPlugins() error {
 	)
 }
 
+// PublishPluginState publishes the state of a registered plugin immediately,
+// e.g. once it starts draining, other nodes stop redirecting new requests to current node
+func (c *Cluster) PublishPluginState(lifetime plugin_entities.PluginLifetime) {
+	identity, err := lifetime.Identity()
+	if err != nil {
+		return
+	}
+
+	c.pluginLock.Lock()
+	defer c.pluginLock.Unlock()
+
+	l, ok := c.plugins.Load(identity.String())
+	if !ok {
+		return
+	}
+
+	if err := c.doPluginStateUpdate(l); err != nil {
+		log.Error("failed to update plugin state: %s", err.Error())
+	}
+}
+
 func (c *Cluster) IsPluginOnCurrentNode(identity plugin_entities.PluginUniqueIdentifier) (bool, error) {
-	_, ok := c.plugins.Load(identity.String())
+	l, ok := c.plugins.Load(identity.String())
 	if !ok {
 		_, err := c.manager.Get(identity)
 		if err != nil {
@@ -296,5 +317,17 @@ func (c *Cluster) IsPluginOnCurrentNode(identity plugin_entities.PluginUniqueIde
 			return true, nil
 		}
 	}
+
+	// a draining plugin finishes in-flight sessions only, new requests are redirected to other nodes
+	state := l.lifetime.RuntimeState()
+	if state.Draining {
+		return false, errors.New("plugin is draining on current node")
+	}
+
+	// so does a quarantined one, replicas on other nodes may still be healthy
+	if state.Status == plugin_entities.PLUGIN_RUNTIME_STATUS_QUARANTINED {
+		return false, errors.New("plugin is quarantined on current node")
+	}
+
 	return ok, nil
 }
@@ -41,8 +41,8 @@ func (r *fakePlugin) Wait() (<-chan bool, error) {
 	return nil, nil
 }
 
-func (r *fakePlugin) Listen(string) *entities.Broadcast[plugin_entities.SessionMessage] {
-	return nil
+func (r *fakePlugin) Listen(string) (*entities.Broadcast[plugin_entities.SessionMessage], error) {
+	return nil, nil
 }
 
 func (r *fakePlugin) Write(string, access_types.PluginAccessAction, []byte) {
@@ -260,3 +260,30 @@ func TestPluginScheduleLifetime(t *testing.T) {
 // 		return
 // 	}
 // }
+
+func TestIsPluginOnCurrentNodeDraining(t *testing.T) {
+	plugin := getRandomPluginRuntime()
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/test:0.0.1@a0c5e1d1d1f1c0c0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0",
+	)
+
+	cluster := &Cluster{}
+	cluster.plugins.Store(identity.String(), &pluginLifeTime{lifetime: &plugin})
+
+	if ok, err := cluster.IsPluginOnCurrentNode(identity); !ok {
+		t.Fatalf("registered plugin should be on current node: %v", err)
+	}
+
+	// new requests to a draining plugin are redirected
+	plugin.SetDraining(true)
+	if ok, _ := cluster.IsPluginOnCurrentNode(identity); ok {
+		t.Fatal("draining plugin should not be treated as on current node")
+	}
+
+	// so are the ones to a quarantined plugin
+	plugin.SetDraining(false)
+	plugin.SetQuarantined()
+	if ok, _ := cluster.IsPluginOnCurrentNode(identity); ok {
+		t.Fatal("quarantined plugin should not be treated as on current node")
+	}
+}
@@ -0,0 +1,354 @@
+package cluster
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Requests redirected between nodes are forwarded by nodeProxy, it keeps the full url,
+// streams both request and response bodies and cancels the forwarded request once
+// the original one is canceled by the client.
+//
+// Every forwarded request is signed with the node-to-node secret, so that the peer
+// is able to tell a redirected request from an external one. The signature covers
+// the method, the url, whether a body follows and a nonce which is accepted only once,
+// a captured request could not be replayed. The body is hashed while it's streamed,
+// the hash is signed in a trailer and checked by the peer once the body is fully read.
+
+const (
+	X_PLUGIN_DAEMON_NODE_ID        = "X-Plugin-Daemon-Node-Id"
+	X_PLUGIN_DAEMON_NODE_TIMESTAMP = "X-Plugin-Daemon-Node-Timestamp"
+	X_PLUGIN_DAEMON_NODE_SIGNATURE = "X-Plugin-Daemon-Node-Signature"
+	X_PLUGIN_DAEMON_NODE_NONCE     = "X-Plugin-Daemon-Node-Nonce"
+	// trailer signing the hash of the body
+	X_PLUGIN_DAEMON_NODE_BODY_SIGNATURE = "X-Plugin-Daemon-Node-Body-Signature"
+
+	// signatures older than $REDIRECT_SIGNATURE_MAX_AGE are considered invalid
+	REDIRECT_SIGNATURE_MAX_AGE = time.Minute * 5
+
+	REDIRECT_NONCE_PREFIX = "cluster:redirect:nonce"
+
+	REDIRECT_DIAL_TIMEOUT             = time.Second * 5
+	REDIRECT_IDLE_CONN_TIMEOUT        = time.Second * 90
+	REDIRECT_MAX_IDLE_CONNS_PER_HOST  = 64
+	REDIRECT_RESPONSE_COPY_BUFFER_LEN = 32 * 1024
+)
+
+// hop-by-hop headers should not be forwarded, see RFC 7230 section 6.1
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+var (
+	errRedirectBodyTampered = errors.New("body of the redirected request does not match its signature")
+)
+
+type nodeProxy struct {
+	nodeId string
+	secret []byte
+	client *http.Client
+
+	// useNonce returns true if the nonce has never been used, it's shared by the cluster
+	useNonce func(nonce string) (bool, error)
+}
+
+func newNodeProxy(nodeId string, secret string) *nodeProxy {
+	transport := &http.Transport{
+		Proxy: nil, // traffic between nodes should never go through the outbound proxy
+		DialContext: (&net.Dialer{
+			Timeout:   REDIRECT_DIAL_TIMEOUT,
+			KeepAlive: time.Second * 30,
+		}).DialContext,
+		MaxIdleConns:        REDIRECT_MAX_IDLE_CONNS_PER_HOST * 4,
+		MaxIdleConnsPerHost: REDIRECT_MAX_IDLE_CONNS_PER_HOST,
+		IdleConnTimeout:     REDIRECT_IDLE_CONN_TIMEOUT,
+		// responses are streamed, decompressing them here breaks the stream semantics
+		DisableCompression: true,
+	}
+
+	return &nodeProxy{
+		nodeId: nodeId,
+		secret: []byte(secret),
+		// no overall timeout, long-running streams are bounded by the original request
+		client: &http.Client{Transport: transport},
+		useNonce: func(nonce string) (bool, error) {
+			return cache.SetNX(
+				strings.Join([]string{REDIRECT_NONCE_PREFIX, nonce}, ":"),
+				true,
+				REDIRECT_SIGNATURE_MAX_AGE*2,
+			)
+		},
+	}
+}
+
+// sign signs the request line and headers, contentLength is 0 if no body follows, -1 otherwise
+func (p *nodeProxy) sign(method string, uri string, timestamp string, nonce string, contentLength int64) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(strings.Join([]string{
+		method, uri, timestamp, nonce, strconv.FormatInt(contentLength, 10),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signBody signs the hash of the body, bound to the signature of the request
+func (p *nodeProxy) signBody(signature string, bodyHash []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(signature + "\n" + hex.EncodeToString(bodyHash)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signingBody hashes the body while it's streamed and sets the signature of the hash
+// in the trailer once the body is fully read, the transport sends the trailer after the body
+type signingBody struct {
+	body    io.Reader
+	hash    hash.Hash
+	trailer http.Header
+	sign    func(bodyHash []byte) string
+}
+
+func (b *signingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		b.trailer.Set(X_PLUGIN_DAEMON_NODE_BODY_SIGNATURE, b.sign(b.hash.Sum(nil)))
+	}
+	return n, err
+}
+
+// verifyingBody hashes the body while it's read by the handler and checks the trailer at the end,
+// a body not matching its signature fails the handler instead of reaching EOF
+type verifyingBody struct {
+	body    io.ReadCloser
+	hash    hash.Hash
+	request *http.Request
+	sign    func(bodyHash []byte) string
+}
+
+func (b *verifyingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		// trailers are available once the body is fully read
+		expected := b.sign(b.hash.Sum(nil))
+		if !hmac.Equal([]byte(b.request.Trailer.Get(X_PLUGIN_DAEMON_NODE_BODY_SIGNATURE)), []byte(expected)) {
+			return n, errRedirectBodyTampered
+		}
+	}
+	return n, err
+}
+
+func (b *verifyingBody) Close() error {
+	return b.body.Close()
+}
+
+// forward sends the request to the address and returns the response of the peer
+func (p *nodeProxy) forward(addr address, request *http.Request) (*http.Response, error) {
+	uri := request.URL.RequestURI()
+
+	ctx := request.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, span := tracing.Start(
+		ctx,
+		"cluster.redirect",
+		trace.SpanKindClient,
+		attribute.String("cluster.node_address", addr.fullAddress()),
+		attribute.String("http.request.method", request.Method),
+	)
+
+	forwarded, err := http.NewRequestWithContext(ctx, request.Method, "http://"+addr.fullAddress()+uri, http.NoBody)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	for key, values := range request.Header {
+		for _, value := range values {
+			forwarded.Header.Add(key, value)
+		}
+	}
+	removeHopByHopHeaders(forwarded.Header)
+	// the forwarded request is a child of the redirect span instead of the original caller
+	tracing.Inject(ctx, forwarded.Header)
+
+	nonce, err := newNonce()
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	// a non-nil body with zero length is treated as unknown length, the body is sent chunked
+	// with its signature in the trailer
+	if request.Body != nil && request.Body != http.NoBody && request.ContentLength != 0 {
+		forwarded.ContentLength = -1
+		forwarded.Trailer = http.Header{X_PLUGIN_DAEMON_NODE_BODY_SIGNATURE: nil}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := p.sign(request.Method, uri, timestamp, nonce, forwarded.ContentLength)
+	forwarded.Header.Set(X_PLUGIN_DAEMON_NODE_ID, p.nodeId)
+	forwarded.Header.Set(X_PLUGIN_DAEMON_NODE_TIMESTAMP, timestamp)
+	forwarded.Header.Set(X_PLUGIN_DAEMON_NODE_NONCE, nonce)
+	forwarded.Header.Set(X_PLUGIN_DAEMON_NODE_SIGNATURE, signature)
+
+	if forwarded.ContentLength != 0 {
+		forwarded.Body = io.NopCloser(&signingBody{
+			body:    request.Body,
+			hash:    sha256.New(),
+			trailer: forwarded.Trailer,
+			sign: func(bodyHash []byte) string {
+				return p.signBody(signature, bodyHash)
+			},
+		})
+	}
+
+	resp, err := p.client.Do(forwarded)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	tracing.End(span, nil)
+
+	removeHopByHopHeaders(resp.Header)
+
+	return resp, nil
+}
+
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// verify checks whether the request is redirected by a node of the cluster,
+// the nonce is consumed, so it returns true only once for the same request
+func (p *nodeProxy) verify(request *http.Request) bool {
+	signature := request.Header.Get(X_PLUGIN_DAEMON_NODE_SIGNATURE)
+	timestamp := request.Header.Get(X_PLUGIN_DAEMON_NODE_TIMESTAMP)
+	nonce := request.Header.Get(X_PLUGIN_DAEMON_NODE_NONCE)
+	if signature == "" || timestamp == "" || nonce == "" || request.Header.Get(X_PLUGIN_DAEMON_NODE_ID) == "" {
+		return false
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	age := time.Since(time.Unix(signedAt, 0))
+	if age > REDIRECT_SIGNATURE_MAX_AGE || age < -REDIRECT_SIGNATURE_MAX_AGE {
+		return false
+	}
+
+	expected := p.sign(request.Method, request.URL.RequestURI(), timestamp, nonce, request.ContentLength)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return false
+	}
+
+	// the signature is valid, make sure it's not a replay
+	unused, err := p.useNonce(nonce)
+	if err != nil {
+		log.Error("failed to check the nonce of a redirected request: %s", err.Error())
+		return false
+	}
+	if !unused {
+		return false
+	}
+
+	if request.ContentLength != 0 {
+		request.Body = &verifyingBody{
+			body:    request.Body,
+			hash:    sha256.New(),
+			request: request,
+			sign: func(bodyHash []byte) string {
+				return p.signBody(signature, bodyHash)
+			},
+		}
+	}
+	return true
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	// headers listed in Connection are hop-by-hop as well
+	for _, value := range header.Values("Connection") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				header.Del(field)
+			}
+		}
+	}
+
+	for _, key := range hopByHopHeaders {
+		header.Del(key)
+	}
+}
+
+// IsRedirectedRequest returns true if the request was redirected by another node of the cluster,
+// it should be called once per request, the nonce of the request is consumed
+func (c *Cluster) IsRedirectedRequest(request *http.Request) bool {
+	return c.proxy.verify(request)
+}
+
+// flushWriter flushes after each write to keep streaming responses like SSE in real time
+type flushWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return n, err
+}
+
+// WriteRedirectedResponse streams the response of a redirected request to the client
+func WriteRedirectedResponse(
+	w http.ResponseWriter, statusCode int, header http.Header, body io.Reader,
+) error {
+	// headers must be set before writing the status code
+	for key, values := range header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(statusCode)
+
+	flusher, _ := w.(http.Flusher)
+	writer := &flushWriter{writer: w, flusher: flusher}
+
+	_, err := io.CopyBuffer(writer, body, make([]byte, REDIRECT_RESPONSE_COPY_BUFFER_LEN))
+	return err
+}
@@ -0,0 +1,244 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listenAddress(t *testing.T, server *httptest.Server) address {
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address{Ip: host, Port: uint16(p)}
+}
+
+// newTestNodeProxy keeps used nonces in memory instead of redis
+func newTestNodeProxy(nodeId string, secret string) *nodeProxy {
+	proxy := newNodeProxy(nodeId, secret)
+	used := sync.Map{}
+	proxy.useNonce = func(nonce string) (bool, error) {
+		_, loaded := used.LoadOrStore(nonce, true)
+		return !loaded, nil
+	}
+	return proxy
+}
+
+func TestNodeProxyKeepsQueryAndBody(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !proxy.verify(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.RequestURI() + "|" + string(body)))
+	}))
+	defer server.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/plugin/tenant/dispatch?page=1&size=2", bytes.NewBufferString("payload"))
+	resp, err := proxy.forward(listenAddress(t, server), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	content, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("forwarded request should be verified by the peer, got %d", resp.StatusCode)
+	}
+
+	if string(content) != "/plugin/tenant/dispatch?page=1&size=2|payload" {
+		t.Fatalf("query or body was not forwarded correctly: %s", content)
+	}
+}
+
+func TestNodeProxyRejectsUnsignedRequests(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	request := httptest.NewRequest(http.MethodGet, "/plugin/tenant/dispatch", nil)
+	if proxy.verify(request) {
+		t.Fatal("unsigned request should not be verified")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_ID, "node-b")
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_TIMESTAMP, timestamp)
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_NONCE, "nonce")
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_SIGNATURE, newNodeProxy("node-b", "another").sign(
+		http.MethodGet, "/plugin/tenant/dispatch", timestamp, "nonce", 0,
+	))
+	if proxy.verify(request) {
+		t.Fatal("request signed with another secret should not be verified")
+	}
+}
+
+// capturedRequest rebuilds a request received by the peer, so that it could be verified again
+func capturedRequest(r *http.Request, body string) *http.Request {
+	captured := httptest.NewRequest(r.Method, r.URL.RequestURI(), bytes.NewBufferString(body))
+	captured.Header = r.Header.Clone()
+	captured.Trailer = r.Trailer.Clone()
+	captured.ContentLength = r.ContentLength
+	return captured
+}
+
+func TestNodeProxyRejectsReplayedRequests(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	captured := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// trailers are read along with the body
+		body, _ := io.ReadAll(r.Body)
+		captured <- capturedRequest(r, string(body))
+	}))
+	defer server.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/e/hook/path", bytes.NewBufferString("payload"))
+	resp, err := proxy.forward(listenAddress(t, server), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	original := <-captured
+
+	// the same request with another body, the headers are valid but the body fails to read
+	tampered := capturedRequest(original, "tampered")
+	if !proxy.verify(tampered) {
+		t.Fatal("headers of the captured request should be verified")
+	}
+	if _, err := io.ReadAll(tampered.Body); err != errRedirectBodyTampered {
+		t.Fatalf("request with a modified body should fail to read, got %v", err)
+	}
+
+	// the same request sent again
+	if proxy.verify(capturedRequest(original, "payload")) {
+		t.Fatal("replayed request should not be verified")
+	}
+}
+
+func TestNodeProxyStreamsBody(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	firstChunk := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !proxy.verify(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, buf); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		firstChunk <- string(buf)
+
+		rest, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(append(buf, rest...))
+	}))
+	defer server.Close()
+
+	reader, writer := io.Pipe()
+	request := httptest.NewRequest(http.MethodPost, "/e/hook/path", reader)
+	request.ContentLength = -1
+
+	go func() {
+		writer.Write([]byte("first"))
+		// the peer receives the first chunk before the body is complete
+		select {
+		case <-firstChunk:
+		case <-time.After(5 * time.Second):
+		}
+		writer.Write([]byte("|second"))
+		writer.Close()
+	}()
+
+	resp, err := proxy.forward(listenAddress(t, server), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	content, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(content) != "first|second" {
+		t.Fatalf("body should be streamed and verified, got %d %s", resp.StatusCode, content)
+	}
+}
+
+func TestNodeProxyVerifyOnce(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := proxy.sign(http.MethodPost, "/e/hook/path", timestamp, "nonce", -1)
+	bodyHash := sha256.Sum256([]byte("payload"))
+	request := httptest.NewRequest(http.MethodPost, "/e/hook/path", bytes.NewBufferString("payload"))
+	request.ContentLength = -1
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_ID, "node-b")
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_TIMESTAMP, timestamp)
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_NONCE, "nonce")
+	request.Header.Set(X_PLUGIN_DAEMON_NODE_SIGNATURE, signature)
+	request.Trailer = http.Header{}
+	request.Trailer.Set(X_PLUGIN_DAEMON_NODE_BODY_SIGNATURE, proxy.signBody(signature, bodyHash[:]))
+
+	if !proxy.verify(request) {
+		t.Fatal("signed request should be verified")
+	}
+	// the nonce has been consumed, the result is passed to handlers by the caller
+	if proxy.verify(request) {
+		t.Fatal("a request should be verified only once")
+	}
+
+	// body is still readable by the handler
+	body, err := io.ReadAll(request.Body)
+	if err != nil || string(body) != "payload" {
+		t.Fatalf("body should be kept after verification, got %s, %v", body, err)
+	}
+}
+
+func TestNodeProxyPropagatesCancellation(t *testing.T) {
+	proxy := newTestNodeProxy("node-a", "secret")
+
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request := httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(ctx)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := proxy.forward(listenAddress(t, server), request); err == nil {
+		t.Fatal("forward should fail once the original request is canceled")
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation was not propagated to the peer")
+	}
+}
@@ -4,6 +4,14 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"sync/atomic"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	// max nodes to try when redirecting a request
+	REDIRECT_MAX_ATTEMPTS = 3
 )
 
 // RedirectRequest redirects the request to the specified node
@@ -20,32 +28,76 @@ func (c *Cluster) RedirectRequest(
 		return 0, nil, nil, errors.New("no available ip found")
 	}
 
-	ip := ips[0]
-
-	// create a new request
-	redirectedRequest, err := http.NewRequest(
-		request.Method,
-		"http://"+ip.fullAddress()+request.URL.Path,
-		request.Body,
-	)
-
+	resp, err := c.proxy.forward(ips[0], request)
 	if err != nil {
 		return 0, nil, nil, err
 	}
 
-	// copy headers
-	for key, values := range request.Header {
-		for _, value := range values {
-			redirectedRequest.Header.Add(key, value)
-		}
+	return resp.StatusCode, resp.Header, resp.Body, nil
+}
+
+// redirectBody tracks whether the request body has been consumed,
+// a request could only be retried on another node if nothing has been sent
+type redirectBody struct {
+	body io.ReadCloser
+	read int32
+}
+
+func (b *redirectBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		atomic.StoreInt32(&b.read, 1)
 	}
+	return n, err
+}
 
-	client := http.DefaultClient
-	resp, err := client.Do(redirectedRequest)
+// Close does nothing, the original body is closed by the server
+func (b *redirectBody) Close() error {
+	return nil
+}
 
-	if err != nil {
-		return 0, nil, nil, err
+func (b *redirectBody) consumed() bool {
+	return atomic.LoadInt32(&b.read) == 1
+}
+
+// RedirectRequestToNodes redirects the request to the given nodes in order,
+// key is the routing key used to select nodes, like tenant id
+// once the connection to a node failed before the request body was sent, the next node will be tried
+func (c *Cluster) RedirectRequestToNodes(
+	key string, nodeIds []string, request *http.Request,
+) (int, http.Header, io.ReadCloser, error) {
+	nodeIds = c.SelectNodes(key, nodeIds)
+	if len(nodeIds) == 0 {
+		return 0, nil, nil, errors.New("no available node")
 	}
 
-	return resp.StatusCode, resp.Header, resp.Body, nil
+	var body *redirectBody
+	if request.Body != nil && request.Body != http.NoBody {
+		body = &redirectBody{body: request.Body}
+		request.Body = body
+	}
+
+	var finalError error
+	for i, nodeId := range nodeIds {
+		if i >= REDIRECT_MAX_ATTEMPTS {
+			break
+		}
+
+		statusCode, header, respBody, err := c.RedirectRequest(nodeId, request)
+		if err == nil {
+			return statusCode, header, respBody, nil
+		}
+
+		c.markNodeUnhealthy(nodeId)
+		finalError = errors.Join(finalError, err)
+
+		if body != nil && body.consumed() {
+			// part of the body has been sent, it's not safe to retry
+			break
+		}
+
+		log.Warn("redirect request to node %s failed, trying next node: %s", nodeId, err.Error())
+	}
+
+	return 0, nil, nil, finalError
 }
@@ -1,6 +1,8 @@
 package dify_invocation
 
 import (
+	"context"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
@@ -34,3 +36,9 @@ type BackwardsInvocation interface {
 	// UploadFile
 	UploadFile(payload *UploadFileRequest) (*UploadFileResponse, error)
 }
+
+// TracedBackwardsInvocation is implemented by backwards invocations which propagate
+// the trace context to dify, WithContext returns a copy bound to the span in ctx
+type TracedBackwardsInvocation interface {
+	WithContext(ctx context.Context) BackwardsInvocation
+}
@@ -1,6 +1,7 @@
 package real
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"net/url"
@@ -33,3 +34,9 @@ func NewDifyInvocationDaemon(base string, calling_key string) (dify_invocation.B
 
 	return invocation, nil
 }
+
+func (i *RealBackwardsInvocation) WithContext(ctx context.Context) dify_invocation.BackwardsInvocation {
+	invocation := *i
+	invocation.ctx = ctx
+	return &invocation
+}
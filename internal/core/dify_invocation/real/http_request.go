@@ -8,6 +8,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/http_requests"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
@@ -19,6 +20,7 @@ func Request[T any](i *RealBackwardsInvocation, method string, path string, opti
 		http_requests.HttpHeader(map[string]string{
 			"X-Inner-Api-Key": i.difyInnerApiKey,
 		}),
+		http_requests.HttpHeader(tracing.Carrier(i.ctx)),
 		http_requests.HttpWriteTimeout(5000),
 		http_requests.HttpReadTimeout(240000),
 	)
@@ -55,6 +57,7 @@ func StreamResponse[T any](i *RealBackwardsInvocation, method string, path strin
 		options, http_requests.HttpHeader(map[string]string{
 			"X-Inner-Api-Key": i.difyInnerApiKey,
 		}),
+		http_requests.HttpHeader(tracing.Carrier(i.ctx)),
 		http_requests.HttpWriteTimeout(5000),
 		http_requests.HttpReadTimeout(240000),
 	)
@@ -1,6 +1,7 @@
 package real
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -9,6 +10,9 @@ type RealBackwardsInvocation struct {
 	difyInnerApiKey     string
 	difyInnerApiBaseurl *url.URL
 	client              *http.Client
+
+	// span of the backwards invocation, its trace context is sent to dify
+	ctx context.Context
 }
 
 type BaseBackwardsInvocationResponse[T any] struct {
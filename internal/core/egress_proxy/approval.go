@@ -0,0 +1,45 @@
+package egress_proxy
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func approvalCacheKey(tenantID string, pluginID string) []db.KeyValuePair {
+	return []db.KeyValuePair{
+		{Key: "tenant_id", Val: tenantID},
+		{Key: "plugin_id", Val: pluginID},
+	}
+}
+
+// ApprovedDomains returns the domains the tenant approved the plugin to access, absence is cached as well
+func ApprovedDomains(tenantID string, pluginID string) ([]string, error) {
+	approval, err := db.GetCache(&db.GetCachePayload[models.PluginNetworkApproval]{
+		Getter: func() (*models.PluginNetworkApproval, error) {
+			approval, err := db.GetOne[models.PluginNetworkApproval](
+				db.Equal("tenant_id", tenantID),
+				db.Equal("plugin_id", pluginID),
+			)
+			if errors.Is(err, db.ErrDatabaseNotFound) {
+				return &models.PluginNetworkApproval{TenantID: tenantID, PluginID: pluginID}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &approval, nil
+		},
+		CacheKey: approvalCacheKey(tenantID, pluginID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return approval.Domains, nil
+}
+
+// InvalidateApproval should be called once the approval is changed
+func InvalidateApproval(tenantID string, pluginID string) error {
+	return db.InvalidateCache[models.PluginNetworkApproval](approvalCacheKey(tenantID, pluginID))
+}
@@ -0,0 +1,365 @@
+package egress_proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// username in the proxy url given to plugins, the password identifies the plugin
+	PROXY_USERNAME = "dify"
+)
+
+var ErrInternalAddress = errors.New("internal address is not reachable through the egress proxy")
+
+// Proxy is a forward proxy for outbound http(s) requests of local plugins,
+// plugins authenticate with a token issued on launch, a request is allowed only if the host
+// is declared in the manifest and approved by every tenant the plugin is serving at the moment.
+//
+// plugins are shared between tenants and the proxy is not able to tell which invocation a
+// connection belongs to, so a request is attributed to all in-flight sessions of the plugin,
+// it's rejected if there is none.
+//
+// hosts are matched by name, so connections are checked again once the name is resolved,
+// loopback, private and link-local addresses are never dialed
+type Proxy struct {
+	address  string
+	listener net.Listener
+	server   *http.Server
+
+	lock    sync.RWMutex
+	plugins map[string]*proxyPlugin // token -> plugin
+	tokens  map[string]string       // plugin unique identifier -> token
+
+	// overridable for testing
+	sessions  func(plugin_entities.PluginUniqueIdentifier) []*session_manager.Session
+	approvals func(tenantID string, pluginID string) ([]string, error)
+	internal  func(net.IP) bool
+
+	dialer    *net.Dialer
+	forwarder *httputil.ReverseProxy
+}
+
+type proxyPlugin struct {
+	identifier plugin_entities.PluginUniqueIdentifier
+	domains    []string
+}
+
+func NewProxy(address string) *Proxy {
+	p := &Proxy{
+		address:   address,
+		plugins:   map[string]*proxyPlugin{},
+		tokens:    map[string]string{},
+		sessions:  session_manager.ListByPlugin,
+		approvals: ApprovedDomains,
+		internal:  isInternalAddress,
+	}
+	p.dialer = &net.Dialer{Timeout: 30 * time.Second, Control: p.dialControl}
+
+	p.forwarder = &httputil.ReverseProxy{
+		// the request is already absolute, hop-by-hop headers including Proxy-Authorization are dropped by ReverseProxy
+		Director: func(r *http.Request) {},
+		Transport: &http.Transport{
+			DialContext:         p.dialer.DialContext,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("failed to reach %s: %s", r.URL.Host, err), dialErrorStatus(err))
+		},
+	}
+
+	return p
+}
+
+// Start listens on the address and serves in background
+func (p *Proxy) Start() error {
+	listener, err := net.Listen("tcp", p.address)
+	if err != nil {
+		return fmt.Errorf("failed to start egress proxy: %s", err)
+	}
+
+	p.listener = listener
+	p.server = &http.Server{Handler: p}
+
+	routine.Submit(map[string]string{
+		"module":   "egress_proxy",
+		"function": "Start",
+	}, func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("egress proxy stopped: %s", err.Error())
+		}
+	})
+
+	log.Info("egress proxy listening on %s", listener.Addr().String())
+	return nil
+}
+
+func (p *Proxy) Stop() {
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// Register issues a token for the plugin and returns the proxy url carrying it,
+// the url should be used as both HTTP_PROXY and HTTPS_PROXY of the plugin
+func (p *Proxy) Register(
+	identifier plugin_entities.PluginUniqueIdentifier,
+	declaration *plugin_entities.PluginDeclaration,
+) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	p.lock.Lock()
+	if previous, ok := p.tokens[identifier.String()]; ok {
+		delete(p.plugins, previous)
+	}
+	p.tokens[identifier.String()] = token
+	p.plugins[token] = &proxyPlugin{
+		identifier: identifier,
+		domains:    declaration.Resource.Permission.NetworkDomains(),
+	}
+	p.lock.Unlock()
+
+	return p.url(token), nil
+}
+
+// Unregister revokes the token carried by proxyUrl, which is returned by Register,
+// nothing is revoked if the plugin has been registered again since
+func (p *Proxy) Unregister(identifier plugin_entities.PluginUniqueIdentifier, proxyUrl string) {
+	u, err := url.Parse(proxyUrl)
+	if err != nil {
+		return
+	}
+	token, _ := u.User.Password()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if current, ok := p.tokens[identifier.String()]; ok && current == token {
+		delete(p.plugins, token)
+		delete(p.tokens, identifier.String())
+	}
+}
+
+func (p *Proxy) url(token string) string {
+	address := p.address
+	if p.listener != nil {
+		address = p.listener.Addr().String()
+	}
+
+	// plugins run on the same host, an unspecified address is not dialable
+	host, port, err := net.SplitHostPort(address)
+	if err == nil && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		address = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	return (&url.URL{
+		Scheme: "http",
+		User:   url.UserPassword(PROXY_USERNAME, token),
+		Host:   address,
+	}).String()
+}
+
+func (p *Proxy) authenticate(r *http.Request) *proxyPlugin {
+	// reuse the basic auth parser of net/http
+	auth := &http.Request{Header: http.Header{"Authorization": r.Header.Values("Proxy-Authorization")}}
+	username, token, ok := auth.BasicAuth()
+	if !ok || username != PROXY_USERNAME {
+		return nil
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.plugins[token]
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plugin := p.authenticate(r)
+	if plugin == nil {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="dify"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	var host string
+	if r.Method == http.MethodConnect {
+		hostname, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			http.Error(w, "invalid CONNECT target", http.StatusBadRequest)
+			return
+		}
+		host = hostname
+	} else {
+		if !r.URL.IsAbs() {
+			http.Error(w, "absolute url required", http.StatusBadRequest)
+			return
+		}
+		host = r.URL.Hostname()
+	}
+
+	if err := p.check(plugin, r.Method, host); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+	} else {
+		p.forwarder.ServeHTTP(w, r)
+	}
+}
+
+// check decides whether the plugin is allowed to access host, the decision is logged with every in-flight session
+func (p *Proxy) check(plugin *proxyPlugin, method string, host string) error {
+	sessions := p.sessions(plugin.identifier)
+
+	err := p.decide(plugin, host, sessions)
+
+	if len(sessions) == 0 {
+		log.With(log.FIELD_PLUGIN, plugin.identifier.String(), "egress_host", host, "egress_allowed", false).
+			Warn("egress %s %s rejected: %s", method, host, err)
+		return err
+	}
+
+	for _, session := range sessions {
+		logger := session.Logger().With("egress_host", host, "egress_allowed", err == nil)
+		if err != nil {
+			logger.Warn("egress %s %s rejected: %s", method, host, err)
+		} else {
+			logger.Info("egress %s %s", method, host)
+		}
+	}
+
+	return err
+}
+
+func (p *Proxy) decide(plugin *proxyPlugin, host string, sessions []*session_manager.Session) error {
+	if !matchAny(plugin.domains, host) {
+		return fmt.Errorf("%s is not declared in the manifest", host)
+	}
+
+	if len(sessions) == 0 {
+		return errors.New("no invocation in flight")
+	}
+
+	checked := map[string]bool{}
+	for _, session := range sessions {
+		if checked[session.TenantID] {
+			continue
+		}
+		checked[session.TenantID] = true
+
+		approved, err := p.approvals(session.TenantID, plugin.identifier.PluginID())
+		if err != nil {
+			return fmt.Errorf("failed to get network approval: %s", err)
+		}
+
+		if !matchAny(approved, host) {
+			return fmt.Errorf("%s is not approved by tenant %s", host, session.TenantID)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if plugin_entities.MatchNetworkDomain(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialControl runs after the host is resolved, a declared domain may resolve to an internal address
+func (p *Proxy) dialControl(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || p.internal(ip) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+func isInternalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+func dialErrorStatus(err error) int {
+	if errors.Is(err, ErrInternalAddress) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadGateway
+}
+
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to reach %s: %s", r.Host, err), dialErrorStatus(err))
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	defer conn.Close()
+	defer upstream.Close()
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	// bytes the client sent right after the CONNECT request
+	if n := buf.Reader.Buffered(); n > 0 {
+		pending, _ := buf.Reader.Peek(n)
+		if _, err := upstream.Write(pending); err != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,258 @@
+package egress_proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func prepareProxy(t *testing.T, approved map[string][]string, tenants ...string) (*Proxy, string) {
+	routine.InitPool(1024)
+
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(
+		"langgenius/openai:0.0.1@a0c5e1d1d1f1c0c0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewProxy("127.0.0.1:0")
+	proxy.sessions = func(plugin_entities.PluginUniqueIdentifier) []*session_manager.Session {
+		sessions := []*session_manager.Session{}
+		for _, tenant := range tenants {
+			sessions = append(sessions, &session_manager.Session{
+				ID:                     "session-" + tenant,
+				TenantID:               tenant,
+				PluginUniqueIdentifier: identifier,
+			})
+		}
+		return sessions
+	}
+	proxy.approvals = func(tenantID string, pluginID string) ([]string, error) {
+		return approved[tenantID], nil
+	}
+	// upstreams of the tests listen on loopback
+	proxy.internal = func(net.IP) bool { return false }
+
+	if err := proxy.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(proxy.Stop)
+
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Resource.Permission = &plugin_entities.PluginPermissionRequirement{
+		Network: &plugin_entities.PluginPermissionNetworkRequirement{
+			Domains: []string{"127.0.0.1"},
+		},
+	}
+
+	proxyUrl, err := proxy.Register(identifier, declaration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return proxy, proxyUrl
+}
+
+func get(t *testing.T, proxyUrl string, target string) int {
+	u, err := url.Parse(proxyUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+func TestProxyForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Error("proxy credentials should not be forwarded")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	_, proxyUrl := prepareProxy(t, map[string][]string{
+		"tenant-a": {"127.0.0.1"},
+	}, "tenant-a")
+
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	// not declared in the manifest
+	if code := get(t, proxyUrl, strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for undeclared host, got %d", code)
+	}
+
+	// wrong credentials
+	u, _ := url.Parse(proxyUrl)
+	u.User = url.UserPassword(PROXY_USERNAME, "invalid")
+	if code := get(t, u.String(), upstream.URL); code != http.StatusProxyAuthRequired {
+		t.Fatalf("expected 407, got %d", code)
+	}
+}
+
+func TestProxyRequiresApprovalOfEveryTenant(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	_, proxyUrl := prepareProxy(t, map[string][]string{
+		"tenant-a": {"127.0.0.1"},
+	}, "tenant-a", "tenant-b")
+
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusForbidden {
+		t.Fatalf("expected 403 while an unapproved tenant is served, got %d", code)
+	}
+
+	// nothing to attribute the request to
+	_, proxyUrl = prepareProxy(t, map[string][]string{
+		"tenant-a": {"127.0.0.1"},
+	})
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without in-flight sessions, got %d", code)
+	}
+}
+
+func TestProxyTunnel(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		conn, err := echo.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	proxy, proxyUrl := prepareProxy(t, map[string][]string{
+		"tenant-a": {"127.0.0.1"},
+	}, "tenant-a")
+
+	u, _ := url.Parse(proxyUrl)
+	conn, err := net.Dial("tcp", proxy.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: echo.Addr().String()},
+		Host:   echo.Addr().String(),
+		Header: http.Header{},
+	}
+	password, _ := u.User.Password()
+	req.SetBasicAuth(PROXY_USERNAME, password)
+	req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	req.Header.Del("Authorization")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("unexpected echo: %q", buf)
+	}
+}
+
+func TestProxyUnregister(t *testing.T) {
+	proxy, proxyUrl := prepareProxy(t, nil, "tenant-a")
+
+	for token := range proxy.plugins {
+		proxy.Unregister(proxy.plugins[token].identifier, proxyUrl)
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusProxyAuthRequired {
+		t.Fatalf("expected 407 after unregistered, got %d", code)
+	}
+}
+
+func TestProxyUnregisterKeepsNewerToken(t *testing.T) {
+	proxy, staleUrl := prepareProxy(t, map[string][]string{"tenant-a": {"127.0.0.1"}}, "tenant-a")
+
+	var identifier plugin_entities.PluginUniqueIdentifier
+	var declaration *plugin_entities.PluginDeclaration
+	for _, plugin := range proxy.plugins {
+		identifier = plugin.identifier
+		declaration = &plugin_entities.PluginDeclaration{}
+		declaration.Resource.Permission = &plugin_entities.PluginPermissionRequirement{
+			Network: &plugin_entities.PluginPermissionNetworkRequirement{Domains: plugin.domains},
+		}
+	}
+
+	// the plugin is relaunched before the previous runtime unregisters
+	proxyUrl, err := proxy.Register(identifier, declaration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.Unregister(identifier, staleUrl)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusOK {
+		t.Fatalf("expected token of the relaunched plugin to be kept, got %d", code)
+	}
+}
+
+func TestProxyRejectsInternalAddress(t *testing.T) {
+	proxy, proxyUrl := prepareProxy(t, map[string][]string{"tenant-a": {"127.0.0.1"}}, "tenant-a")
+	proxy.internal = isInternalAddress
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	if code := get(t, proxyUrl, upstream.URL); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a loopback upstream, got %d", code)
+	}
+
+	for _, address := range []string{"127.0.0.1", "10.0.0.1", "192.168.1.1", "169.254.169.254", "::1", "fe80::1"} {
+		if !isInternalAddress(net.ParseIP(address)) {
+			t.Fatalf("expected %s to be internal", address)
+		}
+	}
+	if isInternalAddress(net.ParseIP("1.1.1.1")) {
+		t.Fatal("expected 1.1.1.1 to be public")
+	}
+}
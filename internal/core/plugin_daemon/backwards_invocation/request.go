@@ -2,9 +2,11 @@ package backwards_invocation
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 )
 
 type BackwardsInvocationType = dify_invocation.InvokeType
@@ -33,6 +35,10 @@ type BackwardsInvocation struct {
 
 	// backwardsInvocation is the backwards invocation that is used to invoke dify
 	backwardsInvocation dify_invocation.BackwardsInvocation
+
+	// used to report metrics once the response ends
+	startedAt time.Time
+	failed    bool
 }
 
 func NewBackwardsInvocation(
@@ -49,6 +55,7 @@ func NewBackwardsInvocation(
 		session:             session,
 		writer:              writer,
 		backwardsInvocation: session.BackwardsInvocation(),
+		startedAt:           time.Now(),
 	}
 }
 
@@ -57,6 +64,7 @@ func (bi *BackwardsInvocation) GetID() string {
 }
 
 func (bi *BackwardsInvocation) WriteError(err error) {
+	bi.failed = true
 	bi.writer.Write(
 		session_manager.PLUGIN_IN_STREAM_EVENT_RESPONSE,
 		NewErrorEvent(bi.id, err.Error()),
@@ -71,6 +79,12 @@ func (bi *BackwardsInvocation) WriteResponse(message string, data any) {
 }
 
 func (bi *BackwardsInvocation) EndResponse() {
+	status := metrics.STATUS_SUCCESS
+	if bi.failed {
+		status = metrics.STATUS_ERROR
+	}
+	metrics.ObserveBackwardsInvocation(string(bi.typ), status, time.Since(bi.startedAt))
+
 	bi.writer.Write(
 		session_manager.PLUGIN_IN_STREAM_EVENT_RESPONSE,
 		NewEndEvent(bi.id),
@@ -9,10 +9,13 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // returns error only if payload is not correct
@@ -260,6 +263,25 @@ func genericDispatchTask[T any](
 }
 
 func dispatchDifyInvocationTask(handle *BackwardsInvocation) {
+	ctx, span := tracing.Start(
+		handle.session.Context(),
+		"backwards_invocation",
+		trace.SpanKindClient,
+		attribute.String("backwards_invocation.id", handle.id),
+		attribute.String("backwards_invocation.type", string(handle.typ)),
+	)
+	defer func() {
+		if handle.failed {
+			span.SetStatus(codes.Error, "backwards invocation failed")
+		}
+		span.End()
+	}()
+
+	// requests sent to dify carry the trace context of the span
+	if traced, ok := handle.backwardsInvocation.(dify_invocation.TracedBackwardsInvocation); ok {
+		handle.backwardsInvocation = traced.WithContext(ctx)
+	}
+
 	requestData := handle.RequestData()
 	tenantId, err := handle.TenantID()
 	if err != nil {
@@ -482,7 +504,7 @@ func executeDifyInvocationStorageTask(
 	if request.Opt == dify_invocation.STORAGE_OPT_GET {
 		data, err := persistence.Load(tenantId, pluginId.PluginID(), request.Key)
 		if err != nil {
-			log.Error("load data failed: %s", err.Error())
+			handle.session.Logger().Error("load data failed: %s", err.Error())
 			handle.WriteError(errors.New("load data failed, please check if the key is correct or you have not set it"))
 			return
 		}
@@ -116,7 +116,7 @@ func (h *AWSTransactionHandler) Handle(
 		func(err string) {
 			log.Warn("invoke dify failed, received errors: %s", err)
 		},
-		func(message string) {}, //log
+		func(string, plugin_entities.PluginLogEvent) {}, //log
 	)
 
 	select {
@@ -1,6 +1,21 @@
 package plugin_daemon
 
-import "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// blob chunks are base64 encoded in json lines, keep them small
+	MAX_BLOB_CHUNK_SIZE = 8192
+	// plugins speaking framed protocol send blob chunks in binary
+	MAX_FRAMED_BLOB_CHUNK_SIZE = 1024 * 1024
+)
 
 func getBasicPluginAccessMap(
 	user_id string,
@@ -13,3 +28,37 @@ func getBasicPluginAccessMap(
 		"action":  action,
 	}
 }
+
+// maxBlobChunkSize returns the limit of a single blob chunk sent by the plugin serving the session
+func maxBlobChunkSize(session *session_manager.Session) int {
+	if wire, ok := session.Runtime().(plugin_entities.PluginWireProtocolInterface); ok &&
+		wire.WireProtocolVersion(session.ID) >= wire_protocol.PROTOCOL_VERSION_FRAMED {
+		return MAX_FRAMED_BLOB_CHUNK_SIZE
+	}
+	return MAX_BLOB_CHUNK_SIZE
+}
+
+// decodeBlobChunk returns the data of a blob chunk, plugins speaking framed protocol send bytes as is,
+// json lines carry it in base64
+func decodeBlobChunk(blob any) ([]byte, error) {
+	switch blob := blob.(type) {
+	case []byte:
+		return blob, nil
+	case string:
+		return base64.StdEncoding.DecodeString(blob)
+	}
+	return nil, errors.New("blob is neither bytes nor a base64 string")
+}
+
+// intOf converts a number decoded from json or cbor to int
+func intOf(v any) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case int64:
+		return int(v), true
+	}
+	return 0, false
+}
@@ -3,13 +3,19 @@ package plugin_daemon
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func GenericInvokePlugin[Req any, Rsp any](
@@ -24,19 +30,63 @@ func GenericInvokePlugin[Req any, Rsp any](
 
 	response := stream.NewStream[Rsp](response_buffer_size)
 
-	listener := runtime.Listen(session.ID)
+	invocation := metrics.StartInvocation(
+		string(session.InvokeFrom),
+		string(session.Action),
+		session.PluginUniqueIdentifier.PluginID(),
+	)
+	ctx, span := tracing.Start(
+		session.Context(),
+		"plugin.invoke",
+		trace.SpanKindClient,
+		attribute.String("session.id", session.ID),
+		attribute.String("plugin.id", session.PluginUniqueIdentifier.PluginID()),
+		attribute.String("plugin.access_type", string(session.InvokeFrom)),
+		attribute.String("plugin.action", string(session.Action)),
+		attribute.String("plugin.runtime_type", string(runtime.Type())),
+	)
+	// the request sent to the plugin and backwards invocations are children of the invocation span
+	session.BindTraceContext(ctx)
+
+	chunks := new(int64)
+	finished := new(int32)
+	finish := func(status string) {
+		if atomic.CompareAndSwapInt32(finished, 0, 1) {
+			invocation.Finish(status)
+
+			span.SetAttributes(
+				attribute.String("plugin.invocation_status", status),
+				attribute.Int64("plugin.stream_chunks", atomic.LoadInt64(chunks)),
+			)
+			if status == metrics.STATUS_ERROR {
+				span.SetStatus(codes.Error, "plugin invocation failed")
+			}
+			span.End()
+		}
+	}
+
+	listener, err := runtime.Listen(session.ID)
+	if err != nil {
+		finish(metrics.STATUS_ERROR)
+		return nil, err
+	}
 	listener.Listen(func(chunk plugin_entities.SessionMessage) {
 		switch chunk.Type {
 		case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
-			chunk, err := parser.UnmarshalJsonBytes[Rsp](chunk.Data)
+			chunk, err := parser.UnmarshalJsonOrCborBytes[Rsp](chunk.Data)
 			if err != nil {
 				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "unmarshal_error",
 					"message":    fmt.Sprintf("unmarshal json failed: %s", err.Error()),
 				})))
+				finish(metrics.STATUS_ERROR)
 				response.Close()
 				return
 			} else {
+				if atomic.AddInt64(chunks, 1) == 1 {
+					span.AddEvent("first chunk")
+				}
+				invocation.Chunk()
 				response.Write(chunk)
 			}
 		case plugin_entities.SESSION_MESSAGE_TYPE_INVOKE:
@@ -46,43 +96,55 @@ func GenericInvokePlugin[Req any, Rsp any](
 					"error_type": "aws_event_not_supported",
 					"message":    "aws event is not supported by full duplex",
 				})))
+				finish(metrics.STATUS_ERROR)
 				response.Close()
 				return
 			}
-			if err := backwards_invocation.InvokeDify(
-				runtime.Configuration(),
-				session.InvokeFrom,
-				session,
-				transaction.NewFullDuplexEventWriter(session),
-				chunk.Data,
-			); err != nil {
+			// backwards invocations are json, requests in cbor frames are converted
+			request, err := parser.CborToJson(chunk.Data)
+			if err == nil {
+				err = backwards_invocation.InvokeDify(
+					runtime.Configuration(),
+					session.InvokeFrom,
+					session,
+					transaction.NewFullDuplexEventWriter(session),
+					request,
+				)
+			}
+			if err != nil {
 				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "invoke_dify_error",
 					"message":    fmt.Sprintf("invoke dify failed: %s", err.Error()),
 				})))
+				finish(metrics.STATUS_ERROR)
 				response.Close()
 				return
 			}
 		case plugin_entities.SESSION_MESSAGE_TYPE_END:
+			finish(metrics.STATUS_SUCCESS)
 			response.Close()
 		case plugin_entities.SESSION_MESSAGE_TYPE_ERROR:
-			e, err := parser.UnmarshalJsonBytes[plugin_entities.ErrorResponse](chunk.Data)
+			e, err := parser.UnmarshalJsonOrCborBytes[plugin_entities.ErrorResponse](chunk.Data)
 			if err != nil {
 				break
 			}
 			response.WriteError(errors.New(e.Error()))
+			finish(metrics.STATUS_ERROR)
 			response.Close()
 		default:
 			response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 				"error_type": "unknown_stream_message_type",
 				"message":    "unknown stream message type: " + string(chunk.Type),
 			})))
+			finish(metrics.STATUS_ERROR)
 			response.Close()
 		}
 	})
 
 	// close the listener if stream outside is closed due to close of connection
 	response.OnClose(func() {
+		// closed before the plugin finished, e.g. the caller disconnected
+		finish(metrics.STATUS_CANCELED)
 		listener.Close()
 	})
 
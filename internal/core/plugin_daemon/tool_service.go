@@ -2,7 +2,6 @@ package plugin_daemon
 
 import (
 	"bytes"
-	"encoding/base64"
 	"errors"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
@@ -72,16 +71,16 @@ func InvokeTool(
 					continue
 				}
 
-				totalLength, ok := item.Message["total_length"].(float64)
+				totalLengthInt, ok := intOf(item.Message["total_length"])
 				if !ok {
 					continue
 				}
 
-				// convert total_length to int
-				totalLengthInt := int(totalLength)
-
-				blob, ok := item.Message["blob"].(string)
-				if !ok {
+				// base64 in json, bytes in cbor frames
+				blob := item.Message["blob"]
+				switch blob.(type) {
+				case string, []byte:
+				default:
 					continue
 				}
 
@@ -109,13 +108,12 @@ func InvokeTool(
 						newResponse.WriteError(errors.New("file is too large"))
 						return
 					} else {
-						// decode the blob using base64
-						decoded, err := base64.StdEncoding.DecodeString(blob)
+						decoded, err := decodeBlobChunk(blob)
 						if err != nil {
 							newResponse.WriteError(err)
 							return
 						}
-						if len(decoded) > 8192 {
+						if len(decoded) > maxBlobChunkSize(session) {
 							// single chunk is too large, raises error
 							newResponse.WriteError(errors.New("single file chunk is too large"))
 							return
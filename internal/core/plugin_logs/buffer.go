@@ -0,0 +1,118 @@
+package plugin_logs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// plugin_logs keeps the latest stdout/stderr lines of each plugin running on current node
+// in a bounded ring buffer, so that operators are able to check what a plugin printed
+// without access to the daemon's own logs
+
+type Stream string
+
+const (
+	STREAM_STDOUT Stream = "stdout"
+	STREAM_STDERR Stream = "stderr"
+
+	// longer lines are truncated
+	MAX_LINE_LENGTH = 4096
+
+	DEFAULT_CAPACITY = 1000
+)
+
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Stream    Stream    `json:"stream"`
+	Level     string    `json:"level,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Message   string    `json:"message"`
+}
+
+var (
+	buffers  sync.Map
+	capacity = atomic.Int64{}
+)
+
+func init() {
+	capacity.Store(DEFAULT_CAPACITY)
+}
+
+// SetCapacity sets the max lines kept for each plugin, it only affects plugins seen afterwards
+func SetCapacity(lines int) {
+	if lines > 0 {
+		capacity.Store(int64(lines))
+	}
+}
+
+type ringBuffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	start   int
+	size    int
+}
+
+func (r *ringBuffer) push(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size < len(r.entries) {
+		r.entries[(r.start+r.size)%len(r.entries)] = entry
+		r.size++
+		return
+	}
+
+	// full, overwrite the oldest one
+	r.entries[r.start] = entry
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+// last returns at most n latest entries, oldest first
+func (r *ringBuffer) last(n int) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n <= 0 || n > r.size {
+		n = r.size
+	}
+
+	result := make([]Entry, 0, n)
+	for i := r.size - n; i < r.size; i++ {
+		result = append(result, r.entries[(r.start+i)%len(r.entries)])
+	}
+	return result
+}
+
+// Append records a line of the plugin
+func Append(pluginUniqueIdentifier string, entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if len(entry.Message) > MAX_LINE_LENGTH {
+		entry.Message = entry.Message[:MAX_LINE_LENGTH]
+	}
+
+	buffer, ok := buffers.Load(pluginUniqueIdentifier)
+	if !ok {
+		buffer, _ = buffers.LoadOrStore(pluginUniqueIdentifier, &ringBuffer{
+			entries: make([]Entry, capacity.Load()),
+		})
+	}
+
+	buffer.(*ringBuffer).push(entry)
+}
+
+// Get returns at most limit latest lines of the plugin, oldest first, all kept lines if limit <= 0
+func Get(pluginUniqueIdentifier string, limit int) []Entry {
+	buffer, ok := buffers.Load(pluginUniqueIdentifier)
+	if !ok {
+		return []Entry{}
+	}
+	return buffer.(*ringBuffer).last(limit)
+}
+
+// Remove drops all lines of the plugin
+func Remove(pluginUniqueIdentifier string) {
+	buffers.Delete(pluginUniqueIdentifier)
+}
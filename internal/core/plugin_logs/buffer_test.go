@@ -0,0 +1,59 @@
+package plugin_logs
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingBufferKeepsLatestEntries(t *testing.T) {
+	SetCapacity(3)
+	defer SetCapacity(DEFAULT_CAPACITY)
+
+	plugin := "langgenius/test:0.0.1@ring"
+	defer Remove(plugin)
+
+	for i := 0; i < 5; i++ {
+		Append(plugin, Entry{Stream: STREAM_STDOUT, Message: fmt.Sprintf("line %d", i)})
+	}
+
+	entries := Get(plugin, 0)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Message != fmt.Sprintf("line %d", i+2) {
+			t.Fatalf("unexpected entry %d: %s", i, entry.Message)
+		}
+	}
+
+	entries = Get(plugin, 1)
+	if len(entries) != 1 || entries[0].Message != "line 4" {
+		t.Fatalf("expected the latest entry, got %v", entries)
+	}
+}
+
+func TestLineWriterSplitsLines(t *testing.T) {
+	plugin := "langgenius/test:0.0.1@writer"
+	defer Remove(plugin)
+
+	writer := NewLineWriter(plugin, STREAM_STDERR)
+	writer.Write([]byte("Traceback (most recent call last):\n  File"))
+	writer.Write([]byte(" \"main.py\"\r\n"))
+	writer.Write([]byte("ValueError"))
+
+	if entries := Get(plugin, 0); len(entries) != 2 {
+		t.Fatalf("expected 2 complete lines, got %d", len(entries))
+	}
+
+	writer.Flush()
+	entries := Get(plugin, 0)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 lines after flush, got %d", len(entries))
+	}
+	if entries[1].Message != "  File \"main.py\"" || entries[2].Message != "ValueError" {
+		t.Fatalf("unexpected lines: %v", entries)
+	}
+	if entries[0].Stream != STREAM_STDERR {
+		t.Fatalf("unexpected stream: %s", entries[0].Stream)
+	}
+}
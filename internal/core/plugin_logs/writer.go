@@ -0,0 +1,67 @@
+package plugin_logs
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LineWriter splits raw output into lines and appends them to the plugin's buffer,
+// an incomplete line is kept until its end arrives or Flush is called
+type LineWriter struct {
+	mu                     sync.Mutex
+	pluginUniqueIdentifier string
+	stream                 Stream
+	pending                []byte
+}
+
+func NewLineWriter(pluginUniqueIdentifier string, stream Stream) *LineWriter {
+	return &LineWriter{
+		pluginUniqueIdentifier: pluginUniqueIdentifier,
+		stream:                 stream,
+	}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.appendLine(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+
+	// a line without end should not grow without limit
+	if len(w.pending) > MAX_LINE_LENGTH {
+		w.appendLine(w.pending)
+		w.pending = nil
+	}
+
+	return len(p), nil
+}
+
+// Flush appends the incomplete line if any
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		w.appendLine(w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *LineWriter) appendLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+	Append(w.pluginUniqueIdentifier, Entry{
+		Stream:  w.stream,
+		Message: string(line),
+	})
+}
@@ -0,0 +1,54 @@
+package plugin_manager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// ColdStart wakes the local plugin up if all its workers have been stopped for idling
+// and waits until one of them started, it returns immediately for plugins already running
+func (p *PluginManager) ColdStart(identity plugin_entities.PluginUniqueIdentifier) error {
+	v, ok := p.m.Load(identity.String())
+	if !ok {
+		return nil
+	}
+
+	runtime, ok := v.(*local_runtime.LocalPluginRuntime)
+	if !ok || !runtime.ColdStarting() {
+		return nil
+	}
+
+	// subscribe before waking up to not miss the event
+	started := runtime.WaitStarted()
+	startedAt := time.Now()
+	woken := runtime.Wake()
+	if woken {
+		log.Info("cold starting plugin %s", identity.String())
+	}
+
+	// the first worker may have started in between
+	if !runtime.ColdStarting() {
+		return nil
+	}
+
+	timer := time.NewTimer(p.coldStartTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-started:
+		if woken {
+			metrics.ObserveColdStart(identity.PluginID(), metrics.STATUS_SUCCESS, time.Since(startedAt))
+		}
+		return nil
+	case <-timer.C:
+		if woken {
+			metrics.ObserveColdStart(identity.PluginID(), metrics.STATUS_ERROR, time.Since(startedAt))
+		}
+		return errors.New("plugin cold start timed out")
+	}
+}
@@ -0,0 +1,29 @@
+package plugin_manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestColdStartRunningPlugin(t *testing.T) {
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/test:0.0.1@a0c5e1d1d1f1c0c0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0",
+	)
+
+	manager := &PluginManager{platform: app.PLATFORM_LOCAL, coldStartTimeout: time.Second}
+	manager.m.Store(identity.String(), local_runtime.NewLocalPluginRuntime(local_runtime.LocalPluginRuntimeConfig{}))
+
+	// never suspended, nothing to wait for
+	if err := manager.ColdStart(identity); err != nil {
+		t.Fatal(err)
+	}
+
+	// not running on current node
+	if err := manager.ColdStart(plugin_entities.PluginUniqueIdentifier("langgenius/other:0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+package plugin_manager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// how often a quarantined plugin checks if it has been released
+var quarantineCheckInterval = time.Second
+
+const (
+	// releases are published to all nodes, the operator can not tell which node runs the plugin
+	PLUGIN_QUARANTINE_RELEASED_CHANNEL = "plugin:quarantine:released"
+)
+
+type quarantineReleasedEvent struct {
+	PluginUniqueIdentifier string `json:"plugin_unique_identifier"`
+}
+
+type CrashLoopPolicy struct {
+	// delay before the first restart, doubled on each crash within the window
+	BackoffBase time.Duration
+	// upper bound of the restart delay
+	BackoffMax time.Duration
+	// crashes within the window to quarantine the plugin, 0 disables quarantine
+	Threshold int
+	Window    time.Duration
+}
+
+// crashLoopDetector tracks crashes of a plugin, it's owned by the lifecycle of the plugin
+type crashLoopDetector struct {
+	policy  CrashLoopPolicy
+	crashes []time.Time
+}
+
+func newCrashLoopDetector(policy CrashLoopPolicy) *crashLoopDetector {
+	return &crashLoopDetector{policy: policy}
+}
+
+// Crashed records a crash, returns the delay before restarting and whether the plugin should be quarantined
+func (d *crashLoopDetector) Crashed(now time.Time) (time.Duration, bool) {
+	recent := []time.Time{}
+	for _, crash := range d.crashes {
+		if now.Sub(crash) < d.policy.Window {
+			recent = append(recent, crash)
+		}
+	}
+	d.crashes = append(recent, now)
+
+	if d.policy.Threshold > 0 && len(d.crashes) >= d.policy.Threshold {
+		return 0, true
+	}
+
+	delay := d.policy.BackoffBase
+	for i := 1; i < len(d.crashes) && delay < d.policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > d.policy.BackoffMax {
+		delay = d.policy.BackoffMax
+	}
+
+	return delay, false
+}
+
+// Reset forgets all crashes, called once the plugin is released from quarantine
+func (d *crashLoopDetector) Reset() {
+	d.crashes = nil
+}
+
+// waitReleased blocks until the quarantined plugin is released or stopped
+func waitReleased(r plugin_entities.PluginFullDuplexLifetime) {
+	ticker := time.NewTicker(quarantineCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !r.Quarantined() || r.Stopped() {
+			return
+		}
+	}
+}
+
+// ReleaseQuarantine lets a quarantined plugin on current node restart immediately
+func (p *PluginManager) ReleaseQuarantine(identity plugin_entities.PluginUniqueIdentifier) error {
+	runtime, ok := p.m.Load(identity.String())
+	if !ok {
+		return errors.New("plugin not found")
+	}
+
+	fullDuplex, ok := runtime.(plugin_entities.PluginFullDuplexLifetime)
+	if !ok || !fullDuplex.Quarantined() {
+		return errors.New("plugin is not quarantined")
+	}
+
+	log.Info("plugin %s released from quarantine", identity.String())
+	fullDuplex.SetRestarting()
+	return nil
+}
+
+// PublishQuarantineRelease asks every node to release the plugin if it's quarantined there
+func (p *PluginManager) PublishQuarantineRelease(identity plugin_entities.PluginUniqueIdentifier) error {
+	return cache.Publish(PLUGIN_QUARANTINE_RELEASED_CHANNEL, quarantineReleasedEvent{
+		PluginUniqueIdentifier: identity.String(),
+	})
+}
+
+// watchQuarantineReleases releases plugins on current node once a release is published by any node
+func (p *PluginManager) watchQuarantineReleases() {
+	events, _ := cache.Subscribe[quarantineReleasedEvent](PLUGIN_QUARANTINE_RELEASED_CHANNEL)
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "watchQuarantineReleases",
+	}, func() {
+		for event := range events {
+			identity, err := plugin_entities.NewPluginUniqueIdentifier(event.PluginUniqueIdentifier)
+			if err != nil {
+				continue
+			}
+			// nodes not running the plugin or running it healthily ignore the release
+			p.ReleaseQuarantine(identity)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package plugin_manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func init() {
+	quarantineCheckInterval = 10 * time.Millisecond
+}
+
+func TestCrashLoopBackoff(t *testing.T) {
+	detector := newCrashLoopDetector(CrashLoopPolicy{
+		BackoffBase: time.Second,
+		BackoffMax:  5 * time.Second,
+		Window:      time.Minute,
+	})
+
+	now := time.Now()
+	for i, expected := range []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	} {
+		delay, quarantine := detector.Crashed(now.Add(time.Duration(i) * time.Second))
+		if quarantine {
+			t.Fatal("quarantine is disabled")
+		}
+		if delay != expected {
+			t.Fatalf("crash %d: expected delay %s, got %s", i+1, expected, delay)
+		}
+	}
+
+	// crashes out of the window are forgotten
+	if delay, _ := detector.Crashed(now.Add(2 * time.Minute)); delay != time.Second {
+		t.Fatalf("expected backoff to be reset, got %s", delay)
+	}
+}
+
+func TestCrashLoopQuarantine(t *testing.T) {
+	detector := newCrashLoopDetector(CrashLoopPolicy{
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+		Threshold:   3,
+		Window:      time.Minute,
+	})
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, quarantine := detector.Crashed(now); quarantine {
+			t.Fatalf("crash %d should not quarantine the plugin", i+1)
+		}
+	}
+	if _, quarantine := detector.Crashed(now); !quarantine {
+		t.Fatal("plugin should be quarantined once the threshold is reached")
+	}
+
+	detector.Reset()
+	if _, quarantine := detector.Crashed(now); quarantine {
+		t.Fatal("crashes should be forgotten after released")
+	}
+}
+
+func TestReleaseQuarantine(t *testing.T) {
+	runtime := getRandomPluginRuntime()
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/test:0.0.1@a0c5e1d1d1f1c0c0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0",
+	)
+
+	manager := &PluginManager{platform: app.PLATFORM_LOCAL}
+	manager.m.Store(identity.String(), runtime)
+
+	if err := manager.ReleaseQuarantine(identity); err == nil {
+		t.Fatal("releasing a running plugin should fail")
+	}
+
+	runtime.SetQuarantined()
+	if _, err := manager.Get(identity); !errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		t.Fatalf("expected plugin unavailable, got %v", err)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		waitReleased(runtime)
+		close(released)
+	}()
+
+	if err := manager.ReleaseQuarantine(identity); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("quarantined plugin should resume once released")
+	}
+
+	if _, err := manager.Get(identity); err != nil {
+		t.Fatalf("released plugin should be available: %v", err)
+	}
+}
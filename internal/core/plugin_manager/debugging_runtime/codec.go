@@ -1,14 +1,15 @@
 package debugging_runtime
 
 import (
-	"bytes"
 	"errors"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/panjf2000/gnet/v2"
 )
 
 type codec struct {
-	buf bytes.Buffer
+	decoder wire_protocol.Decoder
 }
 
 func (w *codec) Decode(c gnet.Conn) ([][]byte, error) {
@@ -24,27 +25,19 @@ func (w *codec) Decode(c gnet.Conn) ([][]byte, error) {
 		return nil, errors.New("read less than size")
 	}
 
-	return w.getLines(buf), nil
+	return w.feed(buf)
 }
 
-func (w *codec) getLines(data []byte) [][]byte {
-	// write to buffer
-	w.buf.Write(data)
-
-	// read line by line, split by \n, remaining data will be kept in buffer
-	buf := make([]byte, w.buf.Len())
-	w.buf.Read(buf)
-	w.buf.Reset()
-
-	lines := bytes.Split(buf, []byte("\n"))
-
-	// if last line is not completed, keep it in buffer
-	if len(lines[len(lines)-1]) != 0 {
-		w.buf.Write(lines[len(lines)-1])
-		lines = lines[:len(lines)-1]
-	} else if len(lines) > 0 {
-		lines = lines[:len(lines)-1]
+// feed splits data into messages, json lines and frames are both accepted,
+// remaining data will be kept until the message is completed
+func (w *codec) feed(data []byte) ([][]byte, error) {
+	messages, err := w.decoder.Feed(data)
+	if errors.Is(err, wire_protocol.ErrMessageTooLarge) {
+		return nil, err
+	} else if err != nil {
+		// the frame is skipped, the stream is still in sync
+		log.Warn("invalid frame from debugging plugin: %s", err.Error())
 	}
 
-	return lines
+	return messages, nil
 }
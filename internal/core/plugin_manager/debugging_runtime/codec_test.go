@@ -6,17 +6,17 @@ import (
 
 func TestCodec(t *testing.T) {
 	codec := &codec{}
-	liens := codec.getLines([]byte("test\n"))
+	liens, _ := codec.feed([]byte("test\n"))
 	if len(liens) != 1 {
 		t.Error("getLines failed")
 	}
 
-	liens = codec.getLines([]byte("test\ntest"))
+	liens, _ = codec.feed([]byte("test\ntest"))
 	if len(liens) == 2 {
 		t.Error("getLines failed")
 	}
 
-	liens = codec.getLines([]byte("\n"))
+	liens, _ = codec.feed([]byte("\n"))
 	if len(liens) != 1 {
 		t.Error("getLines failed")
 	}
@@ -27,7 +27,7 @@ func TestCodec2(t *testing.T) {
 
 	msg := "9c3df1b4-6daf-4cb4-bcaa-3f05a2dbc3a1\n{\"version\":\"1.0.0\",\"type\":\"plugin\",\"author\":\"Yeuoly\",\"name\":\"ci_test\",\"created_at\":\"2024-08-14T19:48:04.867581+08:00\",\"resource\":{\"memory\":1,\"storage\":1,\"permission\":null},\"plugins\":[\"test\"],\"execution\":{\"install\":\"echo 'hello'\",\"launch\":\"echo 'hello'\"},\"meta\":{\"version\":\"0.0.1\",\"arch\":[\"amd64\"],\"runner\":{\"language\":\"python\",\"version\":\"3.12\",\"entrypoint\":\"main\"}}}"
 
-	lines := codec.getLines([]byte(msg))
+	lines, _ := codec.feed([]byte(msg))
 	if len(lines) != 1 {
 		if string(lines[0]) != msg[:len(lines[0])] {
 			t.Error("getLines failed")
@@ -1,6 +1,8 @@
 package debugging_runtime
 
 import (
+	"errors"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,94 +14,155 @@ import (
 
 /*
  * When connect to dify plugin daemon server, we need identify who is connecting.
- * Therefore, we need to a key-value pair to connect a random string to a tenant.
+ * Therefore, we need to a key-value pair to connect a random string to a developer.
  *
  * $random_key => $tenant_id, $user_id
- * $tenant_id => $random_key
+ * $tenant_id, $user_id => $random_key
+ * $tenant_id => [$key_id => $random_key]
  *
  * It's a double mapping for each key, therefore a transaction is needed.
+ * Keys expire after their ttl, they are not renewed when requested again,
+ * plugins attached before the key expired or was revoked are not affected by expiration
+ * but disconnected on revocation.
  * */
 
 type ConnectionInfo struct {
 	TenantId string `json:"tenant_id" validate:"required"`
+	UserId   string `json:"user_id"`
+	// identifies the key in lists and audit logs without revealing it
+	KeyId string `json:"key_id"`
+	// plugins attached with the key at the same time, 0 means unlimited
+	MaxSessions int       `json:"max_sessions"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiredAt   time.Time `json:"expired_at"`
 }
 
 type Key struct {
 	Key string `json:"key" validate:"required"`
 }
 
+// DebuggingKey is a key along with its connection info, the key is only returned to its owner
+type DebuggingKey struct {
+	Key string `json:"key"`
+	ConnectionInfo
+}
+
 const (
-	CONNECTION_KEY_MANAGER_KEY2ID_PREFIX = "{remote:key:manager}:key2id"
-	CONNECTION_KEY_MANAGER_ID2KEY_PREFIX = "{remote:key:manager}:id2key"
-	CONNECTION_KEY_LOCK                  = "connection_lock"
-	CONNECTION_KEY_EXPIRE_TIME           = time.Minute * 98271932 // 2 hours
+	CONNECTION_KEY_MANAGER_KEY2ID_PREFIX   = "{remote:key:manager}:key2id"
+	CONNECTION_KEY_MANAGER_ID2KEY_PREFIX   = "{remote:key:manager}:id2key"
+	CONNECTION_KEY_MANAGER_TENANT_PREFIX   = "{remote:key:manager}:tenant"
+	CONNECTION_KEY_MANAGER_SESSIONS_PREFIX = "{remote:key:manager}:sessions"
+	CONNECTION_KEY_REVOKED_CHANNEL         = "remote:key:revoked"
+	CONNECTION_KEY_LOCK                    = "connection_lock"
+	CONNECTION_KEY_DEFAULT_EXPIRE_TIME     = time.Hour * 2
 )
 
-// returns a random string, create it if not exists
-func GetConnectionKey(info ConnectionInfo) (string, error) {
-	var key *Key
-	var err error
+var (
+	ErrConnectionKeyNotFound = errors.New("debugging key not found")
+)
 
-	key, err = cache.Get[Key](
-		strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"),
-	)
+// connectionKeyRevokedEvent is published once a key is revoked, nodes disconnect plugins attached with it
+type connectionKeyRevokedEvent struct {
+	KeyId string `json:"key_id"`
+}
 
-	if err == cache.ErrNotFound {
-		err := cache.Transaction(func(p redis.Pipeliner) error {
-			k := uuid.New().String()
-			_, err = cache.SetNX(
-				strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"),
-				Key{Key: k},
-				CONNECTION_KEY_EXPIRE_TIME,
-				p,
-			)
-			if err != nil {
-				return err
-			}
+func key2idKey(key string) string {
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key}, ":")
+}
 
-			_, err = cache.SetNX(
-				strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, k}, ":"),
-				info,
-				CONNECTION_KEY_EXPIRE_TIME,
-				p,
-			)
-			if err != nil {
-				return err
-			}
+func id2keyKey(tenant_id string, user_id string) string {
+	if user_id == "" {
+		// keys shared by the tenant, issued by callers not aware of users
+		return strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id}, ":")
+	}
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id, user_id}, ":")
+}
+
+func tenantKeysKey(tenant_id string) string {
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_TENANT_PREFIX, tenant_id}, ":")
+}
 
-			key = &Key{Key: k}
+func sessionsKey(key_id string) string {
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_SESSIONS_PREFIX, key_id}, ":")
+}
 
-			return nil
-		})
+// returns the key of the user in the tenant, issue a new one if not exists or expired
+func GetConnectionKey(info ConnectionInfo, ttl time.Duration) (*DebuggingKey, error) {
+	if ttl <= 0 {
+		ttl = CONNECTION_KEY_DEFAULT_EXPIRE_TIME
+	}
 
-		if err != nil {
-			return "", err
+	key, err := cache.Get[Key](id2keyKey(info.TenantId, info.UserId))
+	if err == nil {
+		existing, err := GetConnectionInfo(key.Key)
+		if err == nil {
+			return &DebuggingKey{Key: key.Key, ConnectionInfo: *existing}, nil
+		} else if err != cache.ErrNotFound {
+			return nil, err
 		}
-	} else if err != nil {
-		return "", err
-	} else {
-		// update expire time
-		_, err = cache.Expire(strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"), CONNECTION_KEY_EXPIRE_TIME)
+		// the key has been revoked, issue a new one
+		if err := cache.Del(id2keyKey(info.TenantId, info.UserId)); err != nil && err != cache.ErrNotFound {
+			return nil, err
+		}
+	} else if err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	now := time.Now()
+	info.KeyId = uuid.New().String()
+	info.CreatedAt = now
+	info.ExpiredAt = now.Add(ttl)
+	issued := &DebuggingKey{Key: uuid.New().String(), ConnectionInfo: info}
+
+	// the key is stored before it's claimed for the user, so that the winner of concurrent requests
+	// is complete once it's visible to the others
+	err = cache.Transaction(func(p redis.Pipeliner) error {
+		if err := cache.Store(key2idKey(issued.Key), info, ttl, p); err != nil {
+			return err
+		}
+
+		// keys of the tenant are listed from the map, expired ones are dropped when listing
+		if err := cache.SetMapOneField(tenantKeysKey(info.TenantId), info.KeyId, issued, p); err != nil {
+			return err
+		}
+
+		// the newest key lives the longest
+		_, err := cache.Expire(tenantKeysKey(info.TenantId), ttl, p)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claimed, err := cache.SetNX(id2keyKey(info.TenantId, info.UserId), Key{Key: issued.Key}, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claimed {
+		// another request issued a key meanwhile, drop this one and return the winner
+		cache.Del(key2idKey(issued.Key))
+		cache.DelMapField(tenantKeysKey(info.TenantId), info.KeyId)
+
+		key, err := cache.Get[Key](id2keyKey(info.TenantId, info.UserId))
 		if err != nil {
-			log.Error("failed to update connection key expire time: %s", err.Error())
+			return nil, err
 		}
 
-		// update expire time for key
-		_, err = cache.Expire(strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key.Key}, ":"), CONNECTION_KEY_EXPIRE_TIME)
+		existing, err := GetConnectionInfo(key.Key)
 		if err != nil {
-			log.Error("failed to update connection key expire time: %s", err.Error())
+			return nil, err
 		}
+
+		return &DebuggingKey{Key: key.Key, ConnectionInfo: *existing}, nil
 	}
 
-	return key.Key, nil
+	return issued, nil
 }
 
 // get connection info by key
 func GetConnectionInfo(key string) (*ConnectionInfo, error) {
-	info, err := cache.Get[ConnectionInfo](
-		strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key}, ":"),
-	)
-
+	info, err := cache.Get[ConnectionInfo](key2idKey(key))
 	if err != nil {
 		return nil, err
 	}
@@ -107,17 +170,116 @@ func GetConnectionInfo(key string) (*ConnectionInfo, error) {
 	return info, nil
 }
 
-// clear connection key
-func ClearConnectionKey(tenant_id string) error {
-	key, err := cache.Get[Key](
-		strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id}, ":"),
-	)
+// ListConnectionKeys returns unexpired keys of the tenant, newest first, keys themselves are not included
+func ListConnectionKeys(tenant_id string) ([]ConnectionInfo, error) {
+	keys, err := cache.GetMap[DebuggingKey](tenantKeysKey(tenant_id))
+	if err == cache.ErrNotFound {
+		return []ConnectionInfo{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := []ConnectionInfo{}
+	for keyId, key := range keys {
+		if now.After(key.ExpiredAt) {
+			if err := cache.DelMapField(tenantKeysKey(tenant_id), keyId); err != nil {
+				log.Warn("failed to drop expired debugging key %s: %s", keyId, err.Error())
+			}
+			continue
+		}
+		result = append(result, key.ConnectionInfo)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// RevokeConnectionKey invalidates the key immediately and disconnects plugins attached with it on all nodes
+func RevokeConnectionKey(tenant_id string, key_id string) error {
+	key, err := cache.GetMapField[DebuggingKey](tenantKeysKey(tenant_id), key_id)
+	if err == cache.ErrNotFound {
+		return ErrConnectionKeyNotFound
+	} else if err != nil {
+		return err
+	}
+
+	if err := cache.Del(key2idKey(key.Key)); err != nil && err != cache.ErrNotFound {
+		return err
+	}
+
+	// the user may have been issued a newer key
+	current, err := cache.Get[Key](id2keyKey(tenant_id, key.UserId))
+	if err == nil && current.Key == key.Key {
+		cache.Del(id2keyKey(tenant_id, key.UserId))
+	}
+
+	cache.DelMapField(tenantKeysKey(tenant_id), key_id)
+	cache.Del(sessionsKey(key_id))
 
+	return cache.Publish(CONNECTION_KEY_REVOKED_CHANNEL, connectionKeyRevokedEvent{KeyId: key_id})
+}
+
+// clear connection key of the user
+func ClearConnectionKey(tenant_id string, user_id string) error {
+	key, err := cache.Get[Key](id2keyKey(tenant_id, user_id))
 	if err != nil {
 		return err
 	}
 
-	cache.Del(strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key.Key}, ":"))
-	cache.Del(strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id}, ":"))
-	return nil
+	info, err := GetConnectionInfo(key.Key)
+	if err == cache.ErrNotFound {
+		return cache.Del(id2keyKey(tenant_id, user_id))
+	} else if err != nil {
+		return err
+	}
+
+	return RevokeConnectionKey(tenant_id, info.KeyId)
+}
+
+// acquireSession counts a plugin attached with the key, returns false if the key reached its limit
+func acquireSession(info *ConnectionInfo) (bool, error) {
+	if info.MaxSessions <= 0 {
+		return true, nil
+	}
+
+	sessions, err := cache.Increase(sessionsKey(info.KeyId))
+	if err != nil {
+		return false, err
+	}
+
+	if sessions == 1 {
+		// the counter is useless once the key expired
+		if _, err := cache.Expire(sessionsKey(info.KeyId), time.Until(info.ExpiredAt)); err != nil {
+			log.Warn("failed to set expire time of debugging sessions: %s", err.Error())
+		}
+	}
+
+	if sessions > int64(info.MaxSessions) {
+		releaseSession(info)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// releaseSession uncounts a plugin acquired by acquireSession
+func releaseSession(info *ConnectionInfo) {
+	if info.MaxSessions <= 0 {
+		return
+	}
+
+	sessions, err := cache.Decrease(sessionsKey(info.KeyId))
+	if err != nil {
+		log.Error("failed to release debugging session: %s", err.Error())
+		return
+	}
+
+	if sessions <= 0 {
+		// the counter has expired or been cleared by revocation
+		cache.Del(sessionsKey(info.KeyId))
+	}
 }
@@ -1,7 +1,9 @@
 package debugging_runtime
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
@@ -18,44 +20,207 @@ func TestConnectionKey(t *testing.T) {
 	// test connection key
 	key, err := GetConnectionKey(ConnectionInfo{
 		TenantId: "abc",
-	})
+		UserId:   "alice",
+	}, time.Hour)
 
 	if err != nil {
 		t.Errorf("get connection key failed: %v", err)
 		return
 	}
 
-	defer ClearConnectionKey("abc")
+	defer ClearConnectionKey("abc", "alice")
 
-	_, err = uuid.Parse(key)
+	_, err = uuid.Parse(key.Key)
 	if err != nil {
 		t.Errorf("connection key is not a valid uuid: %v", err)
 		return
 	}
 
-	// test connection key with the same tenant id
+	if key.ExpiredAt.Sub(key.CreatedAt) != time.Hour {
+		t.Errorf("connection key should expire in an hour, got %s", key.ExpiredAt.Sub(key.CreatedAt))
+		return
+	}
+
+	// test connection key with the same tenant id and user id
 	key2, err := GetConnectionKey(ConnectionInfo{
 		TenantId: "abc",
-	})
+		UserId:   "alice",
+	}, time.Hour)
+
+	if err != nil {
+		t.Errorf("get connection key failed: %v", err)
+		return
+	}
+
+	if key.Key != key2.Key {
+		t.Errorf("connection key is not the same: %s, %s", key.Key, key2.Key)
+		return
+	}
+
+	// another user in the same tenant gets another key
+	key3, err := GetConnectionKey(ConnectionInfo{
+		TenantId: "abc",
+		UserId:   "bob",
+	}, time.Hour)
 
 	if err != nil {
 		t.Errorf("get connection key failed: %v", err)
 		return
 	}
 
-	if key != key2 {
-		t.Errorf("connection key is not the same: %s, %s", key, key2)
+	defer ClearConnectionKey("abc", "bob")
+
+	if key.Key == key3.Key {
+		t.Errorf("users should not share the connection key")
 		return
 	}
 
-	connectionInfo, err := GetConnectionInfo(key)
+	connectionInfo, err := GetConnectionInfo(key.Key)
 	if err != nil {
 		t.Errorf("get connection info failed: %v", err)
 		return
 	}
 
-	if connectionInfo.TenantId != "abc" {
+	if connectionInfo.TenantId != "abc" || connectionInfo.UserId != "alice" {
 		t.Errorf("connection info is not the same: %v", connectionInfo)
 		return
 	}
 }
+
+func TestRevokeConnectionKey(t *testing.T) {
+	err := cache.InitRedisClient("0.0.0.0:6379", "difyai123456", false)
+	if err != nil {
+		t.Errorf("init redis client failed: %v", err)
+		return
+	}
+	defer cache.Close()
+
+	tenantId := uuid.New().String()
+	key, err := GetConnectionKey(ConnectionInfo{
+		TenantId: tenantId,
+		UserId:   "alice",
+	}, time.Hour)
+	if err != nil {
+		t.Errorf("get connection key failed: %v", err)
+		return
+	}
+
+	keys, err := ListConnectionKeys(tenantId)
+	if err != nil {
+		t.Errorf("list connection keys failed: %v", err)
+		return
+	}
+
+	if len(keys) != 1 || keys[0].KeyId != key.KeyId {
+		t.Errorf("expected the issued key to be listed, got %v", keys)
+		return
+	}
+
+	if err := RevokeConnectionKey(tenantId, key.KeyId); err != nil {
+		t.Errorf("revoke connection key failed: %v", err)
+		return
+	}
+
+	if _, err := GetConnectionInfo(key.Key); err != cache.ErrNotFound {
+		t.Errorf("revoked key should not be accepted, got %v", err)
+		return
+	}
+
+	if err := RevokeConnectionKey(tenantId, key.KeyId); err != ErrConnectionKeyNotFound {
+		t.Errorf("expected ErrConnectionKeyNotFound, got %v", err)
+		return
+	}
+
+	// a new key is issued after revocation
+	key2, err := GetConnectionKey(ConnectionInfo{
+		TenantId: tenantId,
+		UserId:   "alice",
+	}, time.Hour)
+	if err != nil {
+		t.Errorf("get connection key failed: %v", err)
+		return
+	}
+	defer ClearConnectionKey(tenantId, "alice")
+
+	if key2.Key == key.Key {
+		t.Errorf("revoked key should not be issued again")
+		return
+	}
+}
+
+func TestConnectionKeySessions(t *testing.T) {
+	err := cache.InitRedisClient("0.0.0.0:6379", "difyai123456", false)
+	if err != nil {
+		t.Errorf("init redis client failed: %v", err)
+		return
+	}
+	defer cache.Close()
+
+	key, err := GetConnectionKey(ConnectionInfo{
+		TenantId:    uuid.New().String(),
+		UserId:      "alice",
+		MaxSessions: 1,
+	}, time.Hour)
+	if err != nil {
+		t.Errorf("get connection key failed: %v", err)
+		return
+	}
+	defer ClearConnectionKey(key.TenantId, "alice")
+
+	if acquired, err := acquireSession(&key.ConnectionInfo); err != nil || !acquired {
+		t.Errorf("first session should be acquired, got %v, %v", acquired, err)
+		return
+	}
+
+	if acquired, err := acquireSession(&key.ConnectionInfo); err != nil || acquired {
+		t.Errorf("second session should be rejected, got %v, %v", acquired, err)
+		return
+	}
+
+	releaseSession(&key.ConnectionInfo)
+
+	if acquired, err := acquireSession(&key.ConnectionInfo); err != nil || !acquired {
+		t.Errorf("session should be acquired after release, got %v, %v", acquired, err)
+		return
+	}
+	releaseSession(&key.ConnectionInfo)
+}
+
+func TestConnectionKeyConcurrent(t *testing.T) {
+	err := cache.InitRedisClient("0.0.0.0:6379", "difyai123456", false)
+	if err != nil {
+		t.Errorf("init redis client failed: %v", err)
+		return
+	}
+	defer cache.Close()
+
+	tenantId := uuid.New().String()
+	defer ClearConnectionKey(tenantId, "alice")
+
+	keys := make([]*DebuggingKey, 8)
+	wg := sync.WaitGroup{}
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys[i], _ = GetConnectionKey(ConnectionInfo{TenantId: tenantId, UserId: "alice"}, time.Hour)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, key := range keys {
+		if key == nil || key.Key != keys[0].Key {
+			t.Errorf("concurrent requests should get the same key")
+			return
+		}
+	}
+
+	listed, err := ListConnectionKeys(tenantId)
+	if err != nil {
+		t.Errorf("list connection keys failed: %v", err)
+		return
+	}
+	if len(listed) != 1 {
+		t.Errorf("expected 1 key listed, got %d", len(listed))
+	}
+}
@@ -1,8 +1,6 @@
 package debugging_runtime
 
 import (
-	"bytes"
-	"encoding/base64"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -10,6 +8,7 @@ import (
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
@@ -44,13 +43,18 @@ type DifyServer struct {
 	// read new connections
 	response *stream.Stream[plugin_entities.PluginFullDuplexLifetime]
 
-	plugins     map[int]*RemotePluginRuntime
+	plugins     map[pluginConn]*RemotePluginRuntime
 	pluginsLock *sync.RWMutex
 
 	shutdownChan chan bool
 
 	maxConn     int32
 	currentConn int32
+
+	// connections are forwarded by the tls terminator, each of them starts with the identity of the peer
+	peerPreamble bool
+	// the client certificate of a plugin has to be issued to the owner of its debugging key
+	clientCertRequired bool
 }
 
 func (s *DifyServer) OnBoot(c gnet.Engine) (action gnet.Action) {
@@ -61,6 +65,26 @@ func (s *DifyServer) OnBoot(c gnet.Engine) (action gnet.Action) {
 func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 	// new plugin connected
 	c.SetContext(&codec{})
+	s.attach(c)
+
+	// verified
+	verified := true
+	if verified {
+		return nil, gnet.None
+	}
+
+	return nil, gnet.Close
+}
+
+func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
+	// plugin disconnected
+	s.detach(c)
+	return gnet.None
+}
+
+// attach creates the runtime of a newly connected plugin, the connection is closed
+// if the handshake is not completed in 10 seconds
+func (s *DifyServer) attach(c pluginConn) *RemotePluginRuntime {
 	runtime := &RemotePluginRuntime{
 		MediaTransport: basic_runtime.NewMediaTransport(
 			s.mediaManager,
@@ -73,8 +97,7 @@ func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 		sessionMessageClosers:     make(map[string][]func()),
 		sessionMessageClosersLock: &sync.RWMutex{},
 
-		assets:      make(map[string]*bytes.Buffer),
-		assetsBytes: 0,
+		registration: newRegistration(),
 
 		shutdownChan:     make(chan bool),
 		waitLaunchedChan: make(chan error),
@@ -84,7 +107,7 @@ func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 
 	// store plugin runtime
 	s.pluginsLock.Lock()
-	s.plugins[c.Fd()] = runtime
+	s.plugins[c] = runtime
 	s.pluginsLock.Unlock()
 
 	// start a timer to check if handshake is completed in 10 seconds
@@ -95,29 +118,28 @@ func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 		}
 	})
 
-	// verified
-	verified := true
-	if verified {
-		return nil, gnet.None
-	}
-
-	return nil, gnet.Close
+	return runtime
 }
 
-func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
-	// plugin disconnected
+// detach cleans up the runtime of a disconnected plugin
+func (s *DifyServer) detach(c pluginConn) {
 	s.pluginsLock.Lock()
-	plugin := s.plugins[c.Fd()]
-	delete(s.plugins, c.Fd())
+	plugin := s.plugins[c]
+	delete(s.plugins, c)
 	s.pluginsLock.Unlock()
 
 	if plugin == nil {
-		return gnet.None
+		return
 	}
 
 	// close plugin
 	plugin.onDisconnected()
 
+	// release the session of the debugging key
+	if plugin.connectionInfo != nil {
+		releaseSession(plugin.connectionInfo)
+	}
+
 	// uninstall plugin
 	if plugin.assetsTransferred {
 		if _mode != _PLUGIN_RUNTIME_MODE_CI {
@@ -146,8 +168,6 @@ func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
 	plugin.waitLaunchedChanOnce.Do(func() {
 		close(plugin.waitLaunchedChan)
 	})
-
-	return gnet.None
 }
 
 func (s *DifyServer) OnShutdown(c gnet.Engine) {
@@ -163,7 +183,7 @@ func (s *DifyServer) OnTraffic(c gnet.Conn) (action gnet.Action) {
 
 	// get plugin runtime
 	s.pluginsLock.RLock()
-	runtime, ok := s.plugins[c.Fd()]
+	runtime, ok := s.plugins[c]
 	s.pluginsLock.RUnlock()
 	if !ok {
 		return gnet.Close
@@ -195,7 +215,26 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 		}
 	}
 
+	if s.peerPreamble && runtime.peer == nil {
+		peer, err := parser.UnmarshalJsonBytes[peerIdentity](message)
+		if err != nil {
+			closeConn([]byte("handshake failed, invalid peer\n"))
+			runtime.handshakeFailed = true
+			return
+		}
+		runtime.peer = &peer
+		return
+	}
+
 	if !runtime.initialized {
+		// register events are json, events in cbor frames are converted
+		message, err := parser.CborToJson(message)
+		if err != nil {
+			closeConn([]byte("handshake failed, invalid handshake message\n"))
+			runtime.handshakeFailed = true
+			return
+		}
+
 		registerPayload, err := parser.UnmarshalJsonBytes[plugin_entities.RemotePluginRegisterPayload](message)
 		if err != nil {
 			// close connection if handshake failed
@@ -231,62 +270,59 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 				return
 			}
 
-			runtime.tenantId = info.TenantId
-
-			// handshake completed
-			runtime.handshake = true
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_ASSET_CHUNK {
-			if runtime.assetsTransferred {
+			if s.clientCertRequired && !runtime.peer.ownsKey(info) {
+				log.Warn("debugging plugin from %s presented a client certificate not issued to the owner of its key", runtime.peer.RemoteAddr)
+				closeConn([]byte("handshake failed, client certificate is not issued to the owner of the key\n"))
+				runtime.handshakeFailed = true
 				return
 			}
 
-			assetChunk, err := parser.UnmarshalJsonBytes[plugin_entities.RemotePluginRegisterAssetChunk](registerPayload.Data)
+			// count the plugin against sessions of the key
+			acquired, err := acquireSession(info)
 			if err != nil {
-				log.Error("assets register failed, error: %v", err)
-				closeConn([]byte("assets register failed, invalid assets chunk\n"))
+				log.Error("failed to acquire debugging session: %v", err)
+				closeConn([]byte("internal error\n"))
 				return
-			}
-
-			buffer, ok := runtime.assets[assetChunk.Filename]
-			if !ok {
-				runtime.assets[assetChunk.Filename] = &bytes.Buffer{}
-				buffer = runtime.assets[assetChunk.Filename]
-			}
-
-			// allows at most 50MB assets
-			if runtime.assetsBytes+int64(len(assetChunk.Data)) > 50*1024*1024 {
-				closeConn([]byte("assets too large, at most 50MB\n"))
+			} else if !acquired {
+				closeConn([]byte(fmt.Sprintf("handshake failed, at most %d plugins are allowed to attach with the key\n", info.MaxSessions)))
+				runtime.handshakeFailed = true
 				return
 			}
 
-			// decode as base64
-			data, err := base64.StdEncoding.DecodeString(assetChunk.Data)
-			if err != nil {
-				log.Error("assets decode failed, error: %v", err)
-				closeConn([]byte("assets decode failed, invalid assets data\n"))
-				return
+			// acknowledge framed protocol, the plugin keeps sending json lines until it receives the ack
+			if key.ProtocolVersion >= wire_protocol.PROTOCOL_VERSION_FRAMED {
+				version := min(key.ProtocolVersion, wire_protocol.PROTOCOL_VERSION_LATEST)
+				ack, err := wire_protocol.Encode(version, parser.MarshalJsonBytes(plugin_entities.PluginUniversalEvent{
+					Event: plugin_entities.PLUGIN_EVENT_PROTOCOL,
+					Data:  parser.MarshalJsonBytes(plugin_entities.PluginProtocolEvent{Version: version}),
+				}))
+				if err != nil {
+					log.Error("failed to encode protocol ack: %v", err)
+					closeConn([]byte("internal error\n"))
+					return
+				}
+				runtime.conn.Write(ack)
+				runtime.protocolVersion.Store(int32(version))
 			}
 
-			buffer.Write(data)
+			runtime.tenantId = info.TenantId
+			// read by revocation out of the event loop
+			s.pluginsLock.Lock()
+			runtime.connectionInfo = info
+			s.pluginsLock.Unlock()
 
-			// update assets bytes
-			runtime.assetsBytes += int64(len(data))
+			// handshake completed
+			runtime.handshake = true
 		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_END {
-			if !runtime.modelsRegistrationTransferred &&
-				!runtime.endpointsRegistrationTransferred &&
-				!runtime.toolsRegistrationTransferred &&
-				!runtime.agentStrategyRegistrationTransferred {
+			if !runtime.registration.providerTransferred() {
 				closeConn([]byte("no registration transferred, cannot initialize\n"))
 				return
 			}
 
-			files := make(map[string][]byte)
-			for filename, buffer := range runtime.assets {
-				files[filename] = buffer.Bytes()
-			}
+			runtime.Config = runtime.registration.declaration
 
 			// remap assets
-			if err := runtime.RemapAssets(&runtime.Config, files); err != nil {
+			if err := runtime.RemapAssets(&runtime.Config, runtime.registration.files()); err != nil {
 				log.Error("assets remap failed, error: %v", err)
 				closeConn([]byte(fmt.Sprintf("assets remap failed, invalid assets data, cannot remap: %v\n", err)))
 				return
@@ -329,102 +365,17 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 				close(runtime.waitLaunchedChan)
 			})
 
-			// mark initialized
+			// mark initialized, registration is no longer needed
 			runtime.initialized = true
+			runtime.registration = nil
 
 			// publish runtime to watcher
 			s.response.Write(runtime)
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_MANIFEST_DECLARATION {
-			if runtime.registrationTransferred {
-				return
-			}
-
-			// process handle shake if not completed
-			declaration, err := parser.UnmarshalJsonBytes[plugin_entities.PluginDeclaration](registerPayload.Data)
-			if err != nil {
-				// close connection if handshake failed
-				closeConn([]byte(fmt.Sprintf("handshake failed, invalid plugin declaration: %v\n", err)))
-				return
-			}
-
-			runtime.Config = declaration
-
-			// registration transferred
-			runtime.registrationTransferred = true
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_TOOL_DECLARATION {
-			if runtime.toolsRegistrationTransferred {
-				return
-			}
-
-			tools, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.ToolProviderDeclaration](registerPayload.Data)
-			if err != nil {
-				closeConn([]byte(fmt.Sprintf("tools register failed, invalid tools declaration: %v\n", err)))
-				return
-			}
-
-			runtime.toolsRegistrationTransferred = true
-
-			if len(tools) > 0 {
-				declaration := runtime.Config
-				declaration.Tool = &tools[0]
-				runtime.Config = declaration
-			}
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_MODEL_DECLARATION {
-			if runtime.modelsRegistrationTransferred {
-				return
-			}
-
-			models, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.ModelProviderDeclaration](registerPayload.Data)
-			if err != nil {
-				closeConn([]byte(fmt.Sprintf("models register failed, invalid models declaration: %v\n", err)))
-				return
-			}
-
-			runtime.modelsRegistrationTransferred = true
-
-			if len(models) > 0 {
-				declaration := runtime.Config
-				declaration.Model = &models[0]
-				runtime.Config = declaration
-			}
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION {
-			if runtime.endpointsRegistrationTransferred {
-				return
-			}
-
-			endpoints, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.EndpointProviderDeclaration](registerPayload.Data)
-			if err != nil {
-				closeConn([]byte(fmt.Sprintf("endpoints register failed, invalid endpoints declaration: %v\n", err)))
-				return
-			}
-
-			runtime.endpointsRegistrationTransferred = true
-
-			if len(endpoints) > 0 {
-				declaration := runtime.Config
-				declaration.Endpoint = &endpoints[0]
-				runtime.Config = declaration
-			}
-		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_AGENT_STRATEGY_DECLARATION {
-			if runtime.agentStrategyRegistrationTransferred {
-				return
-			}
-
-			agents, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.AgentStrategyProviderDeclaration](registerPayload.Data)
-			if err != nil {
-				closeConn([]byte(fmt.Sprintf("agent strategies register failed, invalid agent strategies declaration: %v\n", err)))
-				return
-			}
-
-			runtime.agentStrategyRegistrationTransferred = true
-
-			if len(agents) > 0 {
-				declaration := runtime.Config
-				declaration.AgentStrategy = &agents[0]
-				runtime.Config = declaration
-			}
+		} else if err := runtime.registration.handle(registerPayload); err != nil {
+			closeConn([]byte(err.Error() + "\n"))
+			return
 		}
-	} else {
+	} else if !s.onReregister(runtime, message) {
 		// continue handle messages if handshake completed
 		runtime.response.Write(message)
 	}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
@@ -13,7 +14,7 @@ import (
 	"github.com/panjf2000/gnet/v2"
 )
 
-func (r *RemotePluginRuntime) Listen(session_id string) *entities.Broadcast[plugin_entities.SessionMessage] {
+func (r *RemotePluginRuntime) Listen(session_id string) (*entities.Broadcast[plugin_entities.SessionMessage], error) {
 	listener := entities.NewBroadcast[plugin_entities.SessionMessage]()
 	listener.OnClose(func() {
 		// execute in new goroutine to avoid deadlock
@@ -40,7 +41,7 @@ func (r *RemotePluginRuntime) Listen(session_id string) *entities.Broadcast[plug
 
 	r.addMessageCallbackHandler(session_id, func(data []byte) {
 		// unmarshal the session message
-		chunk, err := parser.UnmarshalJsonBytes[plugin_entities.SessionMessage](data)
+		chunk, err := plugin_entities.ParseSessionMessage(data)
 		if err != nil {
 			log.Error("unmarshal json failed: %s, failed to parse session message", err.Error())
 			return
@@ -49,11 +50,30 @@ func (r *RemotePluginRuntime) Listen(session_id string) *entities.Broadcast[plug
 		listener.Send(chunk)
 	})
 
-	return listener
+	return listener, nil
 }
 
 func (r *RemotePluginRuntime) Write(session_id string, action access_types.PluginAccessAction, data []byte) {
-	r.conn.AsyncWrite(append(data, '\n'), func(c gnet.Conn, err error) error {
+	message, err := wire_protocol.Encode(r.negotiatedProtocolVersion(), data)
+	if err != nil {
+		log.Error("write to debugging plugin failed: %s", err.Error())
+		return
+	}
+
+	r.conn.AsyncWrite(message, func(c gnet.Conn, err error) error {
 		return nil
 	})
 }
+
+// WireProtocolVersion returns the version negotiated in handshake, all sessions share the connection
+func (r *RemotePluginRuntime) WireProtocolVersion(session_id string) int {
+	return r.negotiatedProtocolVersion()
+}
+
+// negotiatedProtocolVersion returns the version negotiated in handshake
+func (r *RemotePluginRuntime) negotiatedProtocolVersion() int {
+	if version := r.protocolVersion.Load(); version > 0 {
+		return int(version)
+	}
+	return wire_protocol.PROTOCOL_VERSION_JSON_LINE
+}
@@ -1,6 +1,14 @@
 package debugging_runtime
 
-import "github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
 
 func (plugin *RemotePluginRuntime) Register() error {
 	_, installation, err := install_service.InstallPlugin(
@@ -10,6 +18,10 @@ func (plugin *RemotePluginRuntime) Register() error {
 		return err
 	}
 	plugin.installationId = installation.ID
+
+	if identity, err := plugin.Identity(); err == nil {
+		plugin.audit(identity, models.PluginDebuggingAuditActionAttached)
+	}
 	return nil
 }
 
@@ -18,6 +30,9 @@ func (plugin *RemotePluginRuntime) Unregister() error {
 	if err != nil {
 		return err
 	}
+	plugin.audit(identity, models.PluginDebuggingAuditActionDetached)
+	// every connection gets a new checksum, the lines of this one are never read again
+	plugin_logs.Remove(identity.String())
 	return install_service.UninstallPlugin(
 		plugin.tenantId,
 		plugin.installationId,
@@ -25,3 +40,35 @@ func (plugin *RemotePluginRuntime) Unregister() error {
 		plugin.Type(),
 	)
 }
+
+// UpdateDeclaration replaces the declaration of the installed plugin, the identity is kept
+func (plugin *RemotePluginRuntime) UpdateDeclaration(
+	identity plugin_entities.PluginUniqueIdentifier,
+	declaration *plugin_entities.PluginDeclaration,
+) error {
+	if err := curd.UpdateRemotePluginDeclaration(plugin.tenantId, identity, declaration); err != nil {
+		return err
+	}
+	plugin.audit(identity, models.PluginDebuggingAuditActionReregistered)
+	return nil
+}
+
+// audit records which key attached, re-registered or detached the plugin, failures are logged only
+func (plugin *RemotePluginRuntime) audit(
+	identity plugin_entities.PluginUniqueIdentifier,
+	action models.PluginDebuggingAuditAction,
+) {
+	record := models.PluginDebuggingAudit{
+		TenantID:               plugin.tenantId,
+		PluginUniqueIdentifier: identity.String(),
+		Action:                 action,
+	}
+	if plugin.connectionInfo != nil {
+		record.UserID = plugin.connectionInfo.UserId
+		record.KeyID = plugin.connectionInfo.KeyId
+	}
+
+	if err := db.Create(&record); err != nil {
+		log.Error("failed to audit debugging plugin %s %s: %s", identity.String(), action, err.Error())
+	}
+}
@@ -0,0 +1,329 @@
+package debugging_runtime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// allows at most 50MB assets
+const maxAssetsBytes = 50 * 1024 * 1024
+
+// registration collects declarations and assets transferred by the plugin,
+// it's used by the initial registration and each re-registration
+type registration struct {
+	declaration plugin_entities.PluginDeclaration
+
+	assets      map[string]*bytes.Buffer
+	assetsBytes int64
+
+	manifestTransferred        bool
+	toolsTransferred           bool
+	modelsTransferred          bool
+	endpointsTransferred       bool
+	agentStrategiesTransferred bool
+}
+
+func newRegistration() *registration {
+	return &registration{
+		assets: make(map[string]*bytes.Buffer),
+	}
+}
+
+// providerTransferred returns true if any provider has been declared
+func (r *registration) providerTransferred() bool {
+	return r.toolsTransferred ||
+		r.modelsTransferred ||
+		r.endpointsTransferred ||
+		r.agentStrategiesTransferred
+}
+
+func (r *registration) files() map[string][]byte {
+	files := make(map[string][]byte)
+	for filename, buffer := range r.assets {
+		files[filename] = buffer.Bytes()
+	}
+	return files
+}
+
+// handle applies an asset chunk or a declaration, the returned error is sent to the plugin
+func (r *registration) handle(payload plugin_entities.RemotePluginRegisterPayload) error {
+	switch payload.Type {
+	case plugin_entities.REGISTER_EVENT_TYPE_ASSET_CHUNK:
+		assetChunk, err := parser.UnmarshalJsonBytes[plugin_entities.RemotePluginRegisterAssetChunk](payload.Data)
+		if err != nil {
+			log.Error("assets register failed, error: %v", err)
+			return errors.New("assets register failed, invalid assets chunk")
+		}
+
+		buffer, ok := r.assets[assetChunk.Filename]
+		if !ok {
+			buffer = &bytes.Buffer{}
+			r.assets[assetChunk.Filename] = buffer
+		}
+
+		if r.assetsBytes+int64(len(assetChunk.Data)) > maxAssetsBytes {
+			return errors.New("assets too large, at most 50MB")
+		}
+
+		// decode as base64
+		data, err := base64.StdEncoding.DecodeString(assetChunk.Data)
+		if err != nil {
+			log.Error("assets decode failed, error: %v", err)
+			return errors.New("assets decode failed, invalid assets data")
+		}
+
+		buffer.Write(data)
+		r.assetsBytes += int64(len(data))
+	case plugin_entities.REGISTER_EVENT_TYPE_MANIFEST_DECLARATION:
+		if r.manifestTransferred {
+			return nil
+		}
+
+		declaration, err := parser.UnmarshalJsonBytes[plugin_entities.PluginDeclaration](payload.Data)
+		if err != nil {
+			return fmt.Errorf("handshake failed, invalid plugin declaration: %v", err)
+		}
+
+		r.declaration = declaration
+		r.manifestTransferred = true
+	case plugin_entities.REGISTER_EVENT_TYPE_TOOL_DECLARATION:
+		if r.toolsTransferred {
+			return nil
+		}
+
+		tools, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.ToolProviderDeclaration](payload.Data)
+		if err != nil {
+			return fmt.Errorf("tools register failed, invalid tools declaration: %v", err)
+		}
+
+		r.toolsTransferred = true
+		if len(tools) > 0 {
+			r.declaration.Tool = &tools[0]
+		}
+	case plugin_entities.REGISTER_EVENT_TYPE_MODEL_DECLARATION:
+		if r.modelsTransferred {
+			return nil
+		}
+
+		models, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.ModelProviderDeclaration](payload.Data)
+		if err != nil {
+			return fmt.Errorf("models register failed, invalid models declaration: %v", err)
+		}
+
+		r.modelsTransferred = true
+		if len(models) > 0 {
+			r.declaration.Model = &models[0]
+		}
+	case plugin_entities.REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION:
+		if r.endpointsTransferred {
+			return nil
+		}
+
+		endpoints, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.EndpointProviderDeclaration](payload.Data)
+		if err != nil {
+			return fmt.Errorf("endpoints register failed, invalid endpoints declaration: %v", err)
+		}
+
+		r.endpointsTransferred = true
+		if len(endpoints) > 0 {
+			r.declaration.Endpoint = &endpoints[0]
+		}
+	case plugin_entities.REGISTER_EVENT_TYPE_AGENT_STRATEGY_DECLARATION:
+		if r.agentStrategiesTransferred {
+			return nil
+		}
+
+		agents, err := parser.UnmarshalJsonBytes2Slice[plugin_entities.AgentStrategyProviderDeclaration](payload.Data)
+		if err != nil {
+			return fmt.Errorf("agent strategies register failed, invalid agent strategies declaration: %v", err)
+		}
+
+		r.agentStrategiesTransferred = true
+		if len(agents) > 0 {
+			r.declaration.AgentStrategy = &agents[0]
+		}
+	}
+
+	// other events are ignored
+	return nil
+}
+
+// Configuration returns the declaration swapped in by the latest re-registration,
+// Config is kept as registered since the identity is derived from it
+func (r *RemotePluginRuntime) Configuration() *plugin_entities.PluginDeclaration {
+	if declaration := r.declaration.Load(); declaration != nil {
+		return declaration
+	}
+	return &r.Config
+}
+
+// "type" encoded as a cbor text string
+var cborTypeKey = []byte("\x64type")
+
+// hasTopLevelKey scans the json object for the key at its top level without decoding it,
+// keys containing escapes are not recognized
+func hasTopLevelKey(message []byte, key string) bool {
+	depth := 0
+	for i := 0; i < len(message); i++ {
+		switch message[i] {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case '"':
+			start := i + 1
+			for i++; i < len(message) && message[i] != '"'; i++ {
+				if message[i] == '\\' {
+					i++
+				}
+			}
+			if depth != 1 || i >= len(message) {
+				continue
+			}
+
+			// a key is followed by a colon
+			end := i
+			for i+1 < len(message) && (message[i+1] == ' ' || message[i+1] == '\t' ||
+				message[i+1] == '\n' || message[i+1] == '\r') {
+				i++
+			}
+			if i+1 < len(message) && message[i+1] == ':' && string(message[start:end]) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// onReregister handles register events sent after the plugin is initialized,
+// returns false if the message is not a register event
+func (s *DifyServer) onReregister(runtime *RemotePluginRuntime, message []byte) bool {
+	// session events never carry a type, they are told apart without being decoded
+	if parser.IsCborMap(message) {
+		// a register event contains the text "type", cbor frames of most session events do not
+		if !bytes.Contains(message, cborTypeKey) {
+			return false
+		}
+		var envelope struct {
+			Type plugin_entities.RemotePluginRegisterEventType `json:"type"`
+		}
+		if err := cbor.Unmarshal(message, &envelope); err != nil || envelope.Type == "" {
+			return false
+		}
+		// register events are json, events in cbor frames are converted
+		converted, err := parser.CborToJson(message)
+		if err != nil {
+			runtime.replyReregister(fmt.Errorf("invalid register event: %v", err))
+			return true
+		}
+		message = converted
+	} else if !hasTopLevelKey(message, "type") {
+		return false
+	}
+
+	payload, err := parser.UnmarshalJsonBytes[plugin_entities.RemotePluginRegisterPayload](message)
+	if err != nil {
+		runtime.replyReregister(fmt.Errorf("invalid register event: %v", err))
+		return true
+	}
+
+	switch payload.Type {
+	case plugin_entities.REGISTER_EVENT_TYPE_RE_REGISTER:
+		// start over, a pending re-registration is dropped
+		runtime.reregistration = newRegistration()
+	case plugin_entities.REGISTER_EVENT_TYPE_END:
+		reg := runtime.reregistration
+		if reg == nil {
+			runtime.replyReregister(errors.New("no re-registration in progress"))
+			return true
+		}
+		runtime.reregistration = nil
+		runtime.replyReregister(runtime.reregister(reg))
+	default:
+		if runtime.reregistration == nil {
+			runtime.replyReregister(fmt.Errorf("%s event is only accepted after re_register", payload.Type))
+			return true
+		}
+
+		if err := runtime.reregistration.handle(payload); err != nil {
+			runtime.reregistration = nil
+			runtime.replyReregister(err)
+		}
+	}
+
+	return true
+}
+
+// reregister swaps in declarations and assets of a re-registration, the identity is kept
+// so that the installation, endpoints and in-flight sessions are not affected
+func (r *RemotePluginRuntime) reregister(reg *registration) error {
+	if !reg.manifestTransferred {
+		return errors.New("manifest declaration is required to re-register")
+	}
+
+	if !reg.providerTransferred() {
+		return errors.New("no registration transferred, cannot re-register")
+	}
+
+	current := r.Configuration()
+	declaration := reg.declaration
+	if declaration.Author != current.Author ||
+		declaration.Name != current.Name ||
+		declaration.Version != current.Version {
+		return errors.New("author, name and version cannot be changed by re-registration, reconnect instead")
+	}
+
+	if err := r.RemapAssets(&declaration, reg.files()); err != nil {
+		return fmt.Errorf("assets remap failed, invalid assets data, cannot remap: %v", err)
+	}
+
+	declaration.FillInDefaultValues()
+
+	identity, err := r.Identity()
+	if err != nil {
+		return err
+	}
+
+	if err := r.UpdateDeclaration(identity, &declaration); err != nil {
+		log.Error("update declaration of debugging plugin %s failed: %s", identity.String(), err.Error())
+		return fmt.Errorf("re-register failed, cannot update declaration: %v", err)
+	}
+
+	r.declaration.Store(&declaration)
+
+	// caches of all nodes are dropped, the new declaration is read from db
+	if err := helper.InvalidatePluginDeclaration(identity, r.Type()); err != nil {
+		log.Error("invalidate declaration of debugging plugin %s failed: %s", identity.String(), err.Error())
+	}
+
+	log.Info("debugging plugin %s re-registered", identity.String())
+	return nil
+}
+
+// replyReregister tells the plugin whether the re-registration is applied
+func (r *RemotePluginRuntime) replyReregister(err error) {
+	event := plugin_entities.PluginReRegisterEvent{Success: err == nil}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	message, encodeErr := wire_protocol.Encode(r.negotiatedProtocolVersion(), parser.MarshalJsonBytes(plugin_entities.PluginUniversalEvent{
+		Event: plugin_entities.PLUGIN_EVENT_RE_REGISTER,
+		Data:  parser.MarshalJsonBytes(event),
+	}))
+	if encodeErr != nil {
+		log.Error("failed to encode re-register result: %v", encodeErr)
+		return
+	}
+
+	r.conn.Write(message)
+}
@@ -0,0 +1,205 @@
+package debugging_runtime
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/panjf2000/gnet/v2"
+)
+
+type recordedConn struct {
+	buf bytes.Buffer
+}
+
+func (c *recordedConn) Write(buf []byte) (int, error) {
+	return c.buf.Write(buf)
+}
+
+func (c *recordedConn) AsyncWrite(buf []byte, callback gnet.AsyncCallback) error {
+	_, err := c.buf.Write(buf)
+	return err
+}
+
+func (c *recordedConn) Close() error {
+	return nil
+}
+
+// replies decodes re-register results written to the plugin
+func (c *recordedConn) replies(t *testing.T) []plugin_entities.PluginReRegisterEvent {
+	replies := []plugin_entities.PluginReRegisterEvent{}
+	for _, line := range strings.Split(strings.TrimSpace(c.buf.String()), "\n") {
+		event, err := parser.UnmarshalJsonBytes[plugin_entities.PluginUniversalEvent]([]byte(line))
+		if err != nil {
+			t.Fatalf("invalid message written to plugin: %s", line)
+		}
+		if event.Event != plugin_entities.PLUGIN_EVENT_RE_REGISTER {
+			t.Fatalf("unexpected event written to plugin: %s", event.Event)
+		}
+		reply, err := parser.UnmarshalJsonBytes[plugin_entities.PluginReRegisterEvent](event.Data)
+		if err != nil {
+			t.Fatalf("invalid re-register result: %s", line)
+		}
+		replies = append(replies, reply)
+	}
+	c.buf.Reset()
+	return replies
+}
+
+func testRegisterPayload(
+	t plugin_entities.RemotePluginRegisterEventType,
+	data any,
+) plugin_entities.RemotePluginRegisterPayload {
+	return plugin_entities.RemotePluginRegisterPayload{
+		Type: t,
+		Data: parser.MarshalJsonBytes(data),
+	}
+}
+
+func testDeclaration(name string) plugin_entities.PluginDeclaration {
+	return plugin_entities.PluginDeclaration{
+		PluginDeclarationWithoutAdvancedFields: plugin_entities.PluginDeclarationWithoutAdvancedFields{
+			Version:     "1.0.0",
+			Type:        manifest_entities.PluginType,
+			Description: plugin_entities.I18nObject{EnUS: "test"},
+			Author:      "yeuoly",
+			Name:        name,
+			Icon:        "test.svg",
+			Label:       plugin_entities.I18nObject{EnUS: name},
+			CreatedAt:   time.Now(),
+			Resource:    plugin_entities.PluginResourceRequirement{Memory: 1},
+			Plugins:     plugin_entities.PluginExtensions{Tools: []string{"test"}},
+			Meta: plugin_entities.PluginMeta{
+				Version: "0.0.1",
+				Arch:    []constants.Arch{constants.AMD64},
+				Runner: plugin_entities.PluginRunner{
+					Language:   constants.Python,
+					Version:    "3.12",
+					Entrypoint: "main",
+				},
+			},
+		},
+	}
+}
+
+func testEndpoints() []plugin_entities.EndpointProviderDeclaration {
+	return []plugin_entities.EndpointProviderDeclaration{
+		{
+			Settings: []plugin_entities.ProviderConfig{},
+			Endpoints: []plugin_entities.EndpointDeclaration{
+				{Path: "/duck/<app_id>", Method: "GET"},
+			},
+		},
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	reg := newRegistration()
+
+	events := []plugin_entities.RemotePluginRegisterPayload{
+		testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_MANIFEST_DECLARATION, testDeclaration("ci_test")),
+		testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION, testEndpoints()),
+		testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_ASSET_CHUNK, plugin_entities.RemotePluginRegisterAssetChunk{
+			Filename: "test.svg",
+			Data:     "AAAA",
+		}),
+		testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_ASSET_CHUNK, plugin_entities.RemotePluginRegisterAssetChunk{
+			Filename: "test.svg",
+			Data:     "AAAA",
+			End:      true,
+		}),
+	}
+
+	if reg.providerTransferred() {
+		t.Fatalf("no provider should be transferred yet")
+	}
+
+	for _, event := range events {
+		if err := reg.handle(event); err != nil {
+			t.Fatalf("handle %s failed: %v", event.Type, err)
+		}
+	}
+
+	if !reg.manifestTransferred || !reg.providerTransferred() {
+		t.Fatalf("manifest and endpoints should be transferred")
+	}
+
+	if reg.declaration.Name != "ci_test" || reg.declaration.Endpoint == nil {
+		t.Errorf("expected endpoints to be merged into manifest ci_test, got %v", reg.declaration)
+	}
+
+	if len(reg.files()["test.svg"]) != 6 {
+		t.Errorf("expected chunks of test.svg to be joined, got %d bytes", len(reg.files()["test.svg"]))
+	}
+}
+
+func TestReregisterRejected(t *testing.T) {
+	conn := &recordedConn{}
+	runtime := &RemotePluginRuntime{
+		conn:        conn,
+		initialized: true,
+	}
+	runtime.Config = testDeclaration("ci_test")
+	server := &DifyServer{
+		plugins:     make(map[pluginConn]*RemotePluginRuntime),
+		pluginsLock: &sync.RWMutex{},
+	}
+
+	// session events are left to the runtime
+	if server.onReregister(runtime, []byte(`{"session_id":"abc","event":"session","data":{}}`)) {
+		t.Fatalf("session event should not be handled as re-registration")
+	}
+
+	send := func(payload plugin_entities.RemotePluginRegisterPayload) {
+		if !server.onReregister(runtime, parser.MarshalJsonBytes(payload)) {
+			t.Fatalf("%s event should be handled as re-registration", payload.Type)
+		}
+	}
+
+	// declarations are rejected until re_register is sent
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_END, map[string]any{}))
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION, testEndpoints()))
+	replies := conn.replies(t)
+	if len(replies) != 2 || replies[0].Success || replies[1].Success {
+		t.Fatalf("expected 2 failures, got %v", replies)
+	}
+
+	// the identity can not be changed
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_RE_REGISTER, map[string]any{}))
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_MANIFEST_DECLARATION, testDeclaration("renamed")))
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION, testEndpoints()))
+	send(testRegisterPayload(plugin_entities.REGISTER_EVENT_TYPE_END, map[string]any{}))
+	replies = conn.replies(t)
+	if len(replies) != 1 || replies[0].Success || !strings.Contains(replies[0].Error, "cannot be changed") {
+		t.Fatalf("expected renaming to be rejected, got %v", replies)
+	}
+
+	if runtime.Configuration().Name != "ci_test" {
+		t.Errorf("declaration should be kept after a rejected re-registration, got %s", runtime.Configuration().Name)
+	}
+
+	if runtime.reregistration != nil {
+		t.Errorf("re-registration should be dropped once ended")
+	}
+}
+
+func TestHasTopLevelKey(t *testing.T) {
+	for message, expected := range map[string]bool{
+		`{"type":"re_register","data":{}}`:                                true,
+		`{ "data" : {"x": 1}, "type" : "end" }`:                           true,
+		`{"session_id":"abc","event":"session","data":{"type":"text"}}`:   false,
+		`{"session_id":"abc","event":"session","data":[{"type":"text"}]}`: false,
+		`{"session_id":"a\"type\":","event":"session"}`:                   false,
+		`{"session_id":"abc","event":"session","data":{"name":"type"}}`:   false,
+	} {
+		if hasTopLevelKey([]byte(message), "type") != expected {
+			t.Fatalf("unexpected result of %s, expected %v", message, expected)
+		}
+	}
+}
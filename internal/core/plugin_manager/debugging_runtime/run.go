@@ -3,6 +3,7 @@ package debugging_runtime
 import (
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
@@ -80,10 +81,23 @@ func (r *RemotePluginRuntime) StartPlugin() error {
 				r.lastActiveAt = time.Now()
 			},
 			func(err string) {
-				log.Error("plugin %s: %s", r.Configuration().Identity(), err)
+				name := r.Configuration().Identity()
+				log.With(log.FIELD_PLUGIN, name).Error("plugin %s: %s", name, err)
+				plugin_logs.Append(identity.String(), plugin_logs.Entry{
+					Stream:  plugin_logs.STREAM_STDOUT,
+					Level:   "error",
+					Message: err,
+				})
 			},
-			func(message string) {
-				log.Info("plugin %s: %s", r.Configuration().Identity(), message)
+			func(session_id string, event plugin_entities.PluginLogEvent) {
+				name := r.Configuration().Identity()
+				log.With(log.FIELD_PLUGIN, name, log.FIELD_SESSION_ID, session_id).Info("plugin %s: %s", name, event.Message)
+				plugin_logs.Append(identity.String(), plugin_logs.Entry{
+					Stream:    plugin_logs.STREAM_STDOUT,
+					Level:     event.Level,
+					SessionID: session_id,
+					Message:   event.Message,
+				})
 			},
 		)
 	})
@@ -2,17 +2,23 @@ package debugging_runtime
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/panjf2000/gnet/v2"
@@ -22,6 +28,16 @@ import (
 
 type RemotePluginServer struct {
 	server *DifyServer
+
+	// public address and tls settings, the gnet engine listens on a private unix socket if tls is enabled
+	address    string
+	tlsConfig  TLSConfig
+	terminator *tlsTerminator
+	// client certificates of websocket attaches are verified against it
+	clientCAs *x509.CertPool
+
+	// stops watching revoked debugging keys
+	stopWatchingKeys func()
 }
 
 type RemotePluginServerInterface interface {
@@ -30,6 +46,7 @@ type RemotePluginServerInterface interface {
 	Wrap(f func(plugin_entities.PluginFullDuplexLifetime))
 	Stop() error
 	Launch() error
+	ServeWebSocket(w http.ResponseWriter, r *http.Request)
 }
 
 // continue accepting new connections
@@ -61,6 +78,13 @@ func (r *RemotePluginServer) Stop() error {
 		return errors.New("plugin server not started")
 	}
 	r.server.response.Close()
+	if r.stopWatchingKeys != nil {
+		r.stopWatchingKeys()
+	}
+	if r.terminator != nil {
+		r.terminator.Close()
+	}
+	r.server.closeWebSockets()
 	err := r.server.engine.Stop(context.Background())
 
 	if err == gnet_errors.ErrEmptyEngine || err == gnet_errors.ErrEngineInShutdown {
@@ -77,6 +101,16 @@ func (r *RemotePluginServer) Launch() error {
 
 	time.Sleep(time.Millisecond * 100)
 
+	if r.tlsConfig.Enabled {
+		if err := r.startTLSTerminator(); err != nil {
+			return err
+		}
+	}
+
+	if _mode != _PLUGIN_RUNTIME_MODE_CI {
+		r.watchRevokedKeys()
+	}
+
 	err := gnet.Run(
 		r.server, r.server.addr, gnet.WithMulticore(r.server.multicore),
 		gnet.WithNumEventLoop(r.server.numLoops),
@@ -91,6 +125,66 @@ func (r *RemotePluginServer) Launch() error {
 	return err
 }
 
+// startTLSTerminator moves the gnet engine to a unix socket in a private directory
+// and serves tls on the public address in front of it
+func (r *RemotePluginServer) startTLSTerminator() error {
+	config, err := r.tlsConfig.load()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "dify-plugin-debugging-")
+	if err != nil {
+		return err
+	}
+	socket := filepath.Join(dir, "server.sock")
+	r.server.addr = "unix://" + socket
+	r.server.peerPreamble = true
+
+	terminator, err := newTLSTerminator(r.address, config, socket)
+	if err != nil {
+		return err
+	}
+	r.terminator = terminator
+	r.clientCAs = config.ClientCAs
+
+	log.Info("debugging server serves tls on %s", r.address)
+	go terminator.serve()
+	return nil
+}
+
+// watchRevokedKeys disconnects plugins attached with keys revoked on any node
+func (r *RemotePluginServer) watchRevokedKeys() {
+	events, cancel := cache.Subscribe[connectionKeyRevokedEvent](CONNECTION_KEY_REVOKED_CHANNEL)
+	r.stopWatchingKeys = cancel
+
+	routine.Submit(map[string]string{
+		"module":   "debugging_runtime",
+		"function": "watchRevokedKeys",
+	}, func() {
+		for event := range events {
+			r.server.disconnectKey(event.KeyId)
+		}
+	})
+}
+
+// disconnectKey stops plugins attached with the key
+func (s *DifyServer) disconnectKey(keyId string) {
+	plugins := []*RemotePluginRuntime{}
+	s.pluginsLock.RLock()
+	for _, plugin := range s.plugins {
+		if plugin.connectionInfo != nil && plugin.connectionInfo.KeyId == keyId {
+			plugins = append(plugins, plugin)
+		}
+	}
+	s.pluginsLock.RUnlock()
+
+	for _, plugin := range plugins {
+		log.Info("debugging key %s has been revoked, disconnecting plugin attached with it", keyId)
+		plugin.Stop()
+	}
+}
+
 func (s *RemotePluginServer) collectShutdownSignal() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
@@ -122,7 +216,7 @@ func NewRemotePluginServer(config *app.Config, media_transport *media_transport.
 		numLoops:     config.PluginRemoteInstallServerEventLoopNums,
 		response:     response,
 
-		plugins:     make(map[int]*RemotePluginRuntime),
+		plugins:     make(map[pluginConn]*RemotePluginRuntime),
 		pluginsLock: &sync.RWMutex{},
 
 		shutdownChan: make(chan bool),
@@ -131,8 +225,16 @@ func NewRemotePluginServer(config *app.Config, media_transport *media_transport.
 	}
 
 	manager := &RemotePluginServer{
-		server: s,
+		server:  s,
+		address: fmt.Sprintf("%s:%d", config.PluginRemoteInstallingHost, config.PluginRemoteInstallingPort),
+		tlsConfig: TLSConfig{
+			Enabled:      config.PluginRemoteInstallingTLSEnabled != nil && *config.PluginRemoteInstallingTLSEnabled,
+			CertFile:     config.PluginRemoteInstallingTLSCertFile,
+			KeyFile:      config.PluginRemoteInstallingTLSKeyFile,
+			ClientCAFile: config.PluginRemoteInstallingTLSClientCAFile,
+		},
 	}
+	s.clientCertRequired = manager.tlsConfig.clientCertRequired()
 
 	return manager
 }
@@ -96,12 +96,12 @@ func TestAcceptConnection(t *testing.T) {
 	defer cache.Close()
 	key, err := GetConnectionKey(ConnectionInfo{
 		TenantId: tenantId,
-	})
+	}, time.Minute)
 	if err != nil {
 		t.Errorf("failed to get connection key: %s", err.Error())
 		return
 	}
-	defer ClearConnectionKey(tenantId)
+	defer ClearConnectionKey(tenantId, "")
 
 	server, port := preparePluginServer(t)
 	if server == nil {
@@ -189,7 +189,7 @@ func TestAcceptConnection(t *testing.T) {
 	conn.Write(parser.MarshalJsonBytes(plugin_entities.RemotePluginRegisterPayload{
 		Type: plugin_entities.REGISTER_EVENT_TYPE_HAND_SHAKE,
 		Data: parser.MarshalJsonBytes(plugin_entities.RemotePluginRegisterHandshake{
-			Key: key,
+			Key: key.Key,
 		}),
 	})) // transfer connection key
 	conn.Write([]byte("\n\n"))
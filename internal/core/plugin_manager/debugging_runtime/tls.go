@@ -0,0 +1,223 @@
+package debugging_runtime
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+type TLSConfig struct {
+	Enabled  bool
+	CertFile string
+	KeyFile  string
+	// client certificates signed by the CA are required if set
+	ClientCAFile string
+}
+
+// load reads certificates from files
+func (c TLSConfig) load() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load debugging server certificate failed: %s", err.Error())
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		ca, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read debugging client ca failed: %s", err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificate found in debugging client ca")
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// clientCertRequired returns true if plugins have to present client certificates signed by the client ca
+func (c TLSConfig) clientCertRequired() bool {
+	return c.Enabled && c.ClientCAFile != ""
+}
+
+// verifyClientCertificate verifies the client certificate of a tls connection accepted by other listeners
+// against the client ca, returns the verified certificate
+func verifyClientCertificate(state *tls.ConnectionState, roots *x509.CertPool) (*x509.Certificate, error) {
+	if roots == nil {
+		return nil, errors.New("debugging client ca is not loaded")
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil, errors.New("no client certificate presented")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range state.PeerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	if _, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, err
+	}
+
+	return state.PeerCertificates[0], nil
+}
+
+// peerIdentity identifies the peer of a plugin connection, the tls terminator sends it as the first line
+// of each connection forwarded to the gnet engine since the unix socket loses the peer
+type peerIdentity struct {
+	RemoteAddr string `json:"remote_addr"`
+	// common name of the verified client certificate, empty if client certificates are not required
+	CommonName string `json:"common_name"`
+}
+
+// ownsKey returns true if the client certificate is issued to the owner of the debugging key,
+// the user the key is issued to, or the tenant for keys shared by the tenant
+func (p *peerIdentity) ownsKey(info *ConnectionInfo) bool {
+	if p == nil || p.CommonName == "" {
+		return false
+	}
+	if info.UserId != "" {
+		return p.CommonName == info.UserId
+	}
+	return p.CommonName == info.TenantId
+}
+
+// how long a client has to complete the tls handshake
+var tlsHandshakeTimeout = 10 * time.Second
+
+// tlsTerminator accepts tls connections and forwards the decrypted stream to the gnet engine
+// listening on a unix socket, gnet does not support tls by itself
+type tlsTerminator struct {
+	listener net.Listener
+	// unix socket of the gnet engine
+	backend string
+
+	conns     map[net.Conn]struct{}
+	connsLock sync.Mutex
+}
+
+func newTLSTerminator(address string, config *tls.Config, backend string) (*tlsTerminator, error) {
+	listener, err := tls.Listen("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tlsTerminator{
+		listener: listener,
+		backend:  backend,
+		conns:    map[net.Conn]struct{}{},
+	}, nil
+}
+
+// serve blocks until the terminator is closed
+func (t *tlsTerminator) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Warn("accept debugging connection failed: %s", err.Error())
+			continue
+		}
+
+		routine.Submit(map[string]string{
+			"module":   "debugging_runtime",
+			"function": "tlsTerminator",
+		}, func() {
+			t.handle(conn.(*tls.Conn))
+		})
+	}
+}
+
+func (t *tlsTerminator) handle(conn *tls.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := conn.Handshake(); err != nil {
+		log.Warn("tls handshake of debugging connection from %s failed: %s", conn.RemoteAddr(), err.Error())
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	peer := peerIdentity{RemoteAddr: conn.RemoteAddr().String()}
+	if certificates := conn.ConnectionState().PeerCertificates; len(certificates) > 0 {
+		peer.CommonName = certificates[0].Subject.CommonName
+		log.Info("debugging connection from %s authenticated as %s", peer.RemoteAddr, peer.CommonName)
+	}
+
+	backend, err := net.Dial("unix", t.backend)
+	if err != nil {
+		log.Error("connect to debugging server failed: %s", err.Error())
+		return
+	}
+	defer backend.Close()
+
+	if _, err := backend.Write(append(parser.MarshalJsonBytes(peer), '\n')); err != nil {
+		log.Error("forward peer of debugging connection failed: %s", err.Error())
+		return
+	}
+
+	t.track(conn, true)
+	defer t.track(conn, false)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(backend, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, backend)
+		done <- struct{}{}
+	}()
+
+	// either side closed
+	<-done
+}
+
+func (t *tlsTerminator) track(conn net.Conn, add bool) {
+	t.connsLock.Lock()
+	defer t.connsLock.Unlock()
+
+	if add {
+		t.conns[conn] = struct{}{}
+	} else {
+		delete(t.conns, conn)
+	}
+}
+
+// Close stops accepting connections and closes established ones
+func (t *tlsTerminator) Close() error {
+	err := t.listener.Close()
+
+	t.connsLock.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.connsLock.Unlock()
+
+	return err
+}
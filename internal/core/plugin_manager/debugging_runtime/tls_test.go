@@ -0,0 +1,259 @@
+package debugging_runtime
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+	keyPem      []byte
+}
+
+func issueCertificate(t *testing.T, name string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPem:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func writeFile(t *testing.T, dir string, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startTerminator starts a terminator in front of an echo server on a unix socket
+// startTerminator forwards to a backend echoing everything after the peer preamble, preambles are sent to peers
+func startTerminator(t *testing.T, config TLSConfig) (string, <-chan peerIdentity) {
+	routine.InitPool(1024)
+	peers := make(chan peerIdentity, 8)
+
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "server.sock")
+	backend, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				preamble, err := reader.ReadBytes('\n')
+				if err != nil {
+					return
+				}
+				peer, err := parser.UnmarshalJsonBytes[peerIdentity](preamble)
+				if err != nil {
+					return
+				}
+				peers <- peer
+				io.Copy(conn, reader)
+			}()
+		}
+	}()
+
+	tlsConfig, err := config.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terminator, err := newTLSTerminator("127.0.0.1:0", tlsConfig, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { terminator.Close() })
+	go terminator.serve()
+
+	return terminator.listener.Addr().String(), peers
+}
+
+func echo(conn *tls.Conn) error {
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return err
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if string(buf) != "ping" {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func TestTLSTerminator(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCertificate(t, "ca", nil)
+	server := issueCertificate(t, "server", ca)
+
+	address, peers := startTerminator(t, TLSConfig{
+		Enabled:  true,
+		CertFile: writeFile(t, dir, "server.crt", server.pem),
+		KeyFile:  writeFile(t, dir, "server.key", server.keyPem),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	conn, err := tls.Dial("tcp", address, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := echo(conn); err != nil {
+		t.Fatalf("expected traffic to be forwarded: %v", err)
+	}
+
+	peer := <-peers
+	if peer.RemoteAddr != conn.LocalAddr().String() || peer.CommonName != "" {
+		t.Fatalf("expected the address of the peer to be forwarded, got %+v", peer)
+	}
+}
+
+func TestTLSTerminatorClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCertificate(t, "ca", nil)
+	server := issueCertificate(t, "server", ca)
+	developer := issueCertificate(t, "developer", ca)
+	stranger := issueCertificate(t, "stranger", issueCertificate(t, "other ca", nil))
+
+	address, peers := startTerminator(t, TLSConfig{
+		Enabled:      true,
+		CertFile:     writeFile(t, dir, "server.crt", server.pem),
+		KeyFile:      writeFile(t, dir, "server.key", server.keyPem),
+		ClientCAFile: writeFile(t, dir, "ca.crt", ca.pem),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	dial := func(client *testCertificate) error {
+		config := &tls.Config{RootCAs: roots}
+		if client != nil {
+			certificate, err := tls.X509KeyPair(client.pem, client.keyPem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Certificates = []tls.Certificate{certificate}
+		}
+
+		conn, err := tls.Dial("tcp", address, config)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return echo(conn)
+	}
+
+	if err := dial(developer); err != nil {
+		t.Fatalf("registered developer should be accepted: %v", err)
+	}
+	if peer := <-peers; peer.CommonName != "developer" {
+		t.Fatalf("expected the verified identity to be forwarded, got %+v", peer)
+	}
+	if err := dial(nil); err == nil {
+		t.Fatal("connection without client certificate should be rejected")
+	}
+	if err := dial(stranger); err == nil {
+		t.Fatal("client certificate of unknown ca should be rejected")
+	}
+}
+
+func TestPeerOwnsKey(t *testing.T) {
+	userKey := &ConnectionInfo{TenantId: "tenant", UserId: "user"}
+	tenantKey := &ConnectionInfo{TenantId: "tenant"}
+
+	if !(&peerIdentity{CommonName: "user"}).ownsKey(userKey) {
+		t.Fatal("certificate issued to the user should own the key of the user")
+	}
+	if (&peerIdentity{CommonName: "tenant"}).ownsKey(userKey) {
+		t.Fatal("certificate issued to the tenant should not own the key of a user")
+	}
+	if !(&peerIdentity{CommonName: "tenant"}).ownsKey(tenantKey) {
+		t.Fatal("certificate issued to the tenant should own the key shared by the tenant")
+	}
+
+	var unknown *peerIdentity
+	if unknown.ownsKey(tenantKey) || (&peerIdentity{}).ownsKey(tenantKey) {
+		t.Fatal("peer without client certificate should own no key")
+	}
+}
+
+func TestPeerPreamble(t *testing.T) {
+	server := &DifyServer{
+		plugins:      make(map[pluginConn]*RemotePluginRuntime),
+		pluginsLock:  &sync.RWMutex{},
+		peerPreamble: true,
+	}
+	runtime := &RemotePluginRuntime{}
+
+	server.onMessage(runtime, []byte(`{"remote_addr":"10.0.0.1:5003","common_name":"developer"}`))
+	if runtime.handshakeFailed || runtime.peer == nil || runtime.peer.CommonName != "developer" {
+		t.Fatalf("expected the peer to be taken from the first message, got %+v", runtime.peer)
+	}
+}
@@ -1,7 +1,6 @@
 package debugging_runtime
 
 import (
-	"bytes"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,12 +15,21 @@ type pluginRuntimeMode string
 
 const _PLUGIN_RUNTIME_MODE_CI pluginRuntimeMode = "ci"
 
+// pluginConn is the transport a plugin attached through, a tcp connection of the gnet engine
+// or a websocket upgraded by the http server
+type pluginConn interface {
+	// Write is only called while handling messages of the connection
+	Write(buf []byte) (int, error)
+	AsyncWrite(buf []byte, callback gnet.AsyncCallback) error
+	Close() error
+}
+
 type RemotePluginRuntime struct {
 	basic_runtime.MediaTransport
 	plugin_entities.PluginRuntime
 
 	// connection
-	conn   gnet.Conn
+	conn   pluginConn
 	closed int32
 
 	// response entity to accept new events
@@ -41,9 +49,6 @@ type RemotePluginRuntime struct {
 	// heartbeat
 	lastActiveAt time.Time
 
-	assets      map[string]*bytes.Buffer
-	assetsBytes int64
-
 	// hand shake process completed
 	handshake       bool
 	handshakeFailed bool
@@ -51,18 +56,27 @@ type RemotePluginRuntime struct {
 	// initialized, wether registration transferred
 	initialized bool
 
-	// registration transferred
-	registrationTransferred bool
+	// declarations and assets transferred before initialized
+	registration      *registration
+	assetsTransferred bool
 
-	toolsRegistrationTransferred         bool
-	modelsRegistrationTransferred        bool
-	endpointsRegistrationTransferred     bool
-	agentStrategyRegistrationTransferred bool
-	assetsTransferred                    bool
+	// re-registration in progress, declarations and assets are swapped in on the end event
+	reregistration *registration
+	// declaration swapped in by the latest re-registration
+	declaration atomic.Pointer[plugin_entities.PluginDeclaration]
 
 	// tenant id
 	tenantId string
 
+	// the debugging key the plugin attached with
+	connectionInfo *ConnectionInfo
+
+	// where the plugin connected from, nil until the tls terminator forwarded it
+	peer *peerIdentity
+
+	// wire protocol version negotiated in handshake, json lines if 0
+	protocolVersion atomic.Int32
+
 	alive bool
 
 	// checksum
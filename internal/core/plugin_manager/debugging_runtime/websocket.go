@@ -0,0 +1,136 @@
+package debugging_runtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/panjf2000/gnet/v2"
+	"golang.org/x/net/websocket"
+)
+
+// how long a write to a websocket may block
+var wsWriteTimeout = 30 * time.Second
+
+// wsConn carries the same stream of json lines or frames as the tcp connection,
+// messages may span multiple websocket frames and a websocket frame may contain multiple messages
+type wsConn struct {
+	ws        *websocket.Conn
+	writeLock sync.Mutex
+}
+
+func (c *wsConn) Write(buf []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.ws.Write(buf)
+}
+
+// AsyncWrite writes synchronously, the websocket is not driven by an event loop
+func (c *wsConn) AsyncWrite(buf []byte, callback gnet.AsyncCallback) error {
+	_, err := c.Write(buf)
+	if callback != nil {
+		return callback(nil, err)
+	}
+	return err
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
+
+// ServeWebSocket attaches a plugin through a websocket upgraded from the request,
+// it's an alternative to the tcp port for networks only exposing http.
+// once client certificates are required by the debugging server, the request has to carry one
+// signed by the same ca, the http listener does not require them by itself
+func (r *RemotePluginServer) ServeWebSocket(w http.ResponseWriter, req *http.Request) {
+	peer := &peerIdentity{RemoteAddr: req.RemoteAddr}
+	if r.tlsConfig.clientCertRequired() {
+		certificate, err := verifyClientCertificate(req.TLS, r.clientCAs)
+		if err != nil {
+			http.Error(w, "client certificate required: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		peer.CommonName = certificate.Subject.CommonName
+	}
+
+	server := websocket.Server{
+		Handshake: checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			r.server.serveWebSocket(ws, peer)
+		},
+	}
+	server.ServeHTTP(w, req)
+}
+
+// checkWebSocketOrigin rejects cross-site attaches from browsers, plugins authenticate with the debugging key
+// in handshake and usually send no origin, an origin is only accepted if it's the requested host
+func checkWebSocketOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if parsed.Host != req.Host {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+
+	config.Origin = parsed
+	return nil
+}
+
+// serveWebSocket handles messages of the websocket until it's closed
+func (s *DifyServer) serveWebSocket(ws *websocket.Conn, peer *peerIdentity) {
+	conn := &wsConn{ws: ws}
+	runtime := s.attach(conn)
+	runtime.peer = peer
+	defer s.detach(conn)
+	defer conn.Close()
+
+	codec := &codec{}
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := ws.Read(buf)
+		if err != nil {
+			return
+		}
+
+		messages, err := codec.feed(buf[:n])
+		if err != nil {
+			log.Error("invalid message from debugging plugin, closing connection: %s", err.Error())
+			return
+		}
+
+		for _, message := range messages {
+			if len(message) == 0 {
+				continue
+			}
+
+			s.onMessage(runtime, message)
+		}
+	}
+}
+
+// closeWebSockets disconnects plugins attached through websockets, the gnet engine closes its own connections
+func (s *DifyServer) closeWebSockets() {
+	conns := []*wsConn{}
+	s.pluginsLock.RLock()
+	for conn := range s.plugins {
+		if ws, ok := conn.(*wsConn); ok {
+			conns = append(conns, ws)
+		}
+	}
+	s.pluginsLock.RUnlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,128 @@
+package debugging_runtime
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketHandshakeFailed(t *testing.T) {
+	server := &RemotePluginServer{
+		server: &DifyServer{
+			plugins:     make(map[pluginConn]*RemotePluginRuntime),
+			pluginsLock: &sync.RWMutex{},
+		},
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.ServeWebSocket))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/plugin/debugging/ws"
+	ws, err := websocket.Dial(url, "", httpServer.URL)
+	if err != nil {
+		t.Fatalf("dial websocket failed: %v", err)
+	}
+	defer ws.Close()
+
+	// messages split across frames are joined before being handled
+	if _, err := ws.Write([]byte("not a ")); err != nil {
+		t.Fatalf("write websocket failed: %v", err)
+	}
+	if _, err := ws.Write([]byte("handshake\n")); err != nil {
+		t.Fatalf("write websocket failed: %v", err)
+	}
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(ws).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read websocket failed: %v", err)
+	}
+
+	if line != "handshake failed, invalid handshake message\n" {
+		t.Fatalf("unexpected response: %q", line)
+	}
+
+	// the runtime is dropped once the connection is closed
+	for i := 0; i < 50; i++ {
+		server.server.pluginsLock.RLock()
+		attached := len(server.server.plugins)
+		server.server.pluginsLock.RUnlock()
+		if attached == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("runtime is not detached after the websocket closed")
+}
+
+func TestWebSocketRejectsCrossOrigin(t *testing.T) {
+	server := &RemotePluginServer{
+		server: &DifyServer{
+			plugins:     make(map[pluginConn]*RemotePluginRuntime),
+			pluginsLock: &sync.RWMutex{},
+		},
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.ServeWebSocket))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/plugin/debugging/ws"
+	if _, err := websocket.Dial(url, "", "https://evil.example.com"); err == nil {
+		t.Fatal("websocket from another origin should be rejected")
+	}
+}
+
+func TestWebSocketRequiresClientCertificate(t *testing.T) {
+	server := &RemotePluginServer{
+		server: &DifyServer{
+			plugins:     make(map[pluginConn]*RemotePluginRuntime),
+			pluginsLock: &sync.RWMutex{},
+		},
+		tlsConfig: TLSConfig{Enabled: true, ClientCAFile: "ca.pem"},
+	}
+
+	httpServer := httptest.NewServer(http.HandlerFunc(server.ServeWebSocket))
+	defer httpServer.Close()
+
+	// the http listener does not verify client certificates
+	response, err := http.Get(httpServer.URL + "/plugin/debugging/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("websocket without client certificate should be rejected, got %d", response.StatusCode)
+	}
+}
+
+func TestVerifyClientCertificate(t *testing.T) {
+	ca := issueCertificate(t, "ca", nil)
+	other := issueCertificate(t, "other", nil)
+	client := issueCertificate(t, "client", ca)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client.certificate}}
+	certificate, err := verifyClientCertificate(state, roots)
+	if err != nil || certificate.Subject.CommonName != "client" {
+		t.Fatalf("client certificate signed by the ca should be verified: %v", err)
+	}
+
+	state = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other.certificate}}
+	if _, err := verifyClientCertificate(state, roots); err == nil {
+		t.Fatal("certificate not signed by the ca should be rejected")
+	}
+
+	if _, err := verifyClientCertificate(nil, roots); err == nil {
+		t.Fatal("plain http requests should be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+package plugin_manager
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// how often in-flight sessions of a draining plugin are checked
+var drainCheckInterval = 500 * time.Millisecond
+
+// Drain stops routing new sessions to the plugin and stops it once all in-flight sessions on current node
+// finished or the drain timeout exceeded, it returns immediately.
+// draining is canceled if the plugin is launched again before it stops
+func (p *PluginManager) Drain(identity plugin_entities.PluginUniqueIdentifier) {
+	runtime, ok := p.m.Load(identity.String())
+	if !ok {
+		return
+	}
+
+	fullDuplex, ok := runtime.(plugin_entities.PluginFullDuplexLifetime)
+	if !ok {
+		runtime.Stop()
+		return
+	}
+
+	if fullDuplex.Draining() || fullDuplex.Stopped() {
+		return
+	}
+	fullDuplex.SetDraining(true)
+
+	// let other nodes take over new requests
+	for _, handler := range p.pluginDrainHandlers {
+		handler(fullDuplex)
+	}
+
+	log.Info("draining plugin %s, waiting for in-flight sessions to finish", identity.String())
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "Drain",
+	}, func() {
+		inflight := func() int {
+			return len(session_manager.ListByPlugin(identity))
+		}
+
+		remaining, canceled := waitDrained(fullDuplex, inflight, time.Now().Add(p.drainTimeout))
+		if canceled {
+			log.Info("draining plugin %s canceled", identity.String())
+			return
+		}
+
+		if remaining > 0 {
+			log.Warn("drain timeout of plugin %s exceeded, %d sessions are cut off", identity.String(), remaining)
+		} else {
+			log.Info("plugin %s drained", identity.String())
+		}
+
+		fullDuplex.Stop()
+	})
+}
+
+// waitDrained waits until no session is in flight or the deadline exceeded, returns the number of sessions left,
+// canceled is true if the runtime is no longer draining or it has been stopped by others
+func waitDrained(
+	runtime plugin_entities.PluginFullDuplexLifetime,
+	inflight func() int,
+	deadline time.Time,
+) (int, bool) {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+	// the first check happens after an interval, sessions which got the runtime right before
+	// draining started are registered by then
+	for range ticker.C {
+		if !runtime.Draining() || runtime.Stopped() {
+			return 0, true
+		}
+
+		remaining := inflight()
+		if remaining == 0 || time.Now().After(deadline) {
+			return remaining, false
+		}
+	}
+
+	return 0, true
+}
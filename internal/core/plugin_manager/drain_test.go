@@ -0,0 +1,103 @@
+package plugin_manager
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func init() {
+	drainCheckInterval = 10 * time.Millisecond
+}
+
+func TestWaitDrained(t *testing.T) {
+	runtime := getRandomPluginRuntime()
+	runtime.SetDraining(true)
+
+	sessions := int32(2)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&sessions, 0)
+	}()
+
+	remaining, canceled := waitDrained(runtime, func() int {
+		return int(atomic.LoadInt32(&sessions))
+	}, time.Now().Add(time.Second))
+	if canceled || remaining != 0 {
+		t.Fatalf("expected drained, got remaining %d, canceled %v", remaining, canceled)
+	}
+}
+
+func TestWaitDrainedTimeout(t *testing.T) {
+	runtime := getRandomPluginRuntime()
+	runtime.SetDraining(true)
+
+	remaining, canceled := waitDrained(runtime, func() int {
+		return 3
+	}, time.Now().Add(50*time.Millisecond))
+	if canceled || remaining != 3 {
+		t.Fatalf("expected 3 sessions cut off, got remaining %d, canceled %v", remaining, canceled)
+	}
+}
+
+func TestWaitDrainedCanceled(t *testing.T) {
+	runtime := getRandomPluginRuntime()
+	runtime.SetDraining(true)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		runtime.SetDraining(false)
+	}()
+
+	_, canceled := waitDrained(runtime, func() int {
+		return 1
+	}, time.Now().Add(time.Second))
+	if !canceled {
+		t.Fatal("draining should be canceled once the runtime is resumed")
+	}
+}
+
+func TestDrainRejectsNewSessions(t *testing.T) {
+	routine.InitPool(1024)
+
+	runtime := getRandomPluginRuntime()
+	identity := plugin_entities.PluginUniqueIdentifier(
+		"langgenius/test:0.0.1@a0c5e1d1d1f1c0c0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0e0",
+	)
+
+	manager := &PluginManager{
+		platform:     app.PLATFORM_LOCAL,
+		drainTimeout: time.Second,
+	}
+	manager.m.Store(identity.String(), runtime)
+
+	drainNotified := int32(0)
+	manager.AddPluginDrainHandler(func(plugin_entities.PluginLifetime) {
+		atomic.AddInt32(&drainNotified, 1)
+	})
+
+	if _, err := manager.Get(identity); err != nil {
+		t.Fatalf("plugin should be available before draining: %v", err)
+	}
+
+	manager.Drain(identity)
+	if _, err := manager.Get(identity); err == nil {
+		t.Fatal("draining plugin should not accept new sessions")
+	}
+	if atomic.LoadInt32(&drainNotified) != 1 {
+		t.Fatal("drain handlers should be notified once draining started")
+	}
+
+	// no session in flight, the plugin stops right after the first check
+	deadline := time.Now().Add(time.Second)
+	for !runtime.Stopped() {
+		if time.Now().After(deadline) {
+			t.Fatal("drained plugin should be stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -26,12 +26,27 @@ func (p *PluginManager) InstallToLocal(
 		return nil, err
 	}
 
-	runtime, launchedChan, errChan, err := p.launchLocal(plugin_unique_identifier)
+	placed, err := p.placeInstalledLocalPlugin(plugin_unique_identifier)
 	if err != nil {
 		return nil, err
 	}
 
 	response := stream.NewStream[PluginInstallResponse](128)
+	if !placed {
+		// placed onto other nodes, they launch it
+		response.Write(PluginInstallResponse{
+			Event: PluginInstallEventDone,
+			Data:  "Installed",
+		})
+		response.Close()
+		return response, nil
+	}
+
+	runtime, launchedChan, errChan, err := p.launchLocal(plugin_unique_identifier)
+	if err != nil {
+		return nil, err
+	}
+
 	routine.Submit(map[string]string{
 		"module":   "plugin_manager",
 		"function": "InstallToLocal",
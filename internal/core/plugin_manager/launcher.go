@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
@@ -93,6 +94,11 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 			return nil, nil, nil, fmt.Errorf("plugin runtime not found")
 		}
 
+		// installed again while draining, keep it running
+		if lifetime.Draining() {
+			lifetime.SetDraining(false)
+		}
+
 		// returns a closed channel to indicate the plugin is already running, no more waiting is needed
 		c := make(chan bool)
 		close(c)
@@ -132,11 +138,21 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 	localPluginRuntime := local_runtime.NewLocalPluginRuntime(local_runtime.LocalPluginRuntimeConfig{
 		PythonInterpreterPath: p.pythonInterpreterPath,
 		PythonEnvInitTimeout:  p.pythonEnvInitTimeout,
+		PythonEnvCache:        p.pythonEnvCache,
+		GoCompilerPath:        p.goCompilerPath,
+		GoBuildTimeout:        p.goBuildTimeout,
+		NodeExecutablePath:    p.nodeExecutablePath,
+		NodeEnvInitTimeout:    p.nodeEnvInitTimeout,
+		NpmRegistryUrl:        p.npmRegistryUrl,
 		HttpProxy:             p.HttpProxy,
 		HttpsProxy:            p.HttpsProxy,
 		PipMirrorUrl:          p.pipMirrorUrl,
 		PipPreferBinary:       p.pipPreferBinary,
 		PipExtraArgs:          p.pipExtraArgs,
+		MemoryLimitEnabled:    p.memoryLimitEnabled,
+		CgroupRoot:            p.cgroupRoot,
+		Sandbox:               p.sandboxConfig,
+		Workers:               p.workerPoolConfigOf(identity),
 	})
 	localPluginRuntime.PluginRuntime = plugin.runtime
 	localPluginRuntime.BasicChecksum = basic_runtime.BasicChecksum{
@@ -152,6 +168,14 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		return nil, nil, nil, failed(errors.Join(err, fmt.Errorf("remap plugin assets error")).Error())
 	}
 
+	if p.egressProxy != nil {
+		proxyUrl, err := p.egressProxy.Register(identity, &localPluginRuntime.Config)
+		if err != nil {
+			return nil, nil, nil, failed(errors.Join(err, fmt.Errorf("register plugin to egress proxy error")).Error())
+		}
+		localPluginRuntime.EgressProxy = proxyUrl
+	}
+
 	success = true
 
 	p.m.Store(identity.String(), localPluginRuntime)
@@ -170,7 +194,13 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 			if r := recover(); r != nil {
 				log.Error("plugin runtime panic: %v", r)
 			}
+			// drop the lines before the identity is released, a relaunch afterwards starts a new buffer
+			plugin_logs.Remove(identity.String())
 			p.m.Delete(identity.String())
+			if p.egressProxy != nil {
+				// a relaunch may have registered the plugin again, only the token of this runtime is revoked
+				p.egressProxy.Unregister(identity, localPluginRuntime.EgressProxy)
+			}
 		}()
 
 		// add max launching lock to prevent too many plugins launching at the same time
@@ -190,3 +220,13 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 
 	return localPluginRuntime, launchedChan, errChan, nil
 }
+
+// workerPoolConfigOf returns the worker pool of the plugin, overrides take precedence over the defaults
+func (p *PluginManager) workerPoolConfigOf(identity plugin_entities.PluginUniqueIdentifier) local_runtime.WorkerPoolConfig {
+	config := p.workerPoolConfig
+	if workers, ok := p.workersOverride[identity.PluginID()]; ok {
+		config.Min = workers.Min
+		config.Max = workers.Max
+	}
+	return config
+}
@@ -0,0 +1,119 @@
+package local_runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// go plugins are launched as a single binary speaking the same stdio protocol as python plugins,
+// the binary is either prebuilt and shipped in the package as bin/<entrypoint>-<os>-<arch>,
+// or built from the source in the package if the toolchain is available
+
+// goBinaryName returns the path of the binary relative to the plugin root
+func goBinaryName(entrypoint string, goos string, goarch string) string {
+	return path.Join("bin", fmt.Sprintf("%s-%s-%s", entrypoint, goos, goarch))
+}
+
+func (p *LocalPluginRuntime) InitGoEnvironment() error {
+	supported := false
+	for _, arch := range p.Config.Meta.Arch {
+		if string(arch) == runtime.GOARCH {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("plugin does not support arch %s, declared: %v", runtime.GOARCH, p.Config.Meta.Arch)
+	}
+
+	binaryPath, err := filepath.Abs(path.Join(
+		p.State.WorkingPath,
+		goBinaryName(p.Config.Meta.Runner.Entrypoint, runtime.GOOS, runtime.GOARCH),
+	))
+	if err != nil {
+		return fmt.Errorf("failed to find go binary: %s", err)
+	}
+
+	if _, err := os.Stat(binaryPath); err != nil {
+		// no prebuilt binary, build it from source
+		if _, err := os.Stat(path.Join(p.State.WorkingPath, "go.mod")); err != nil {
+			return fmt.Errorf(
+				"failed to find prebuilt binary %s and go.mod to build it",
+				goBinaryName(p.Config.Meta.Runner.Entrypoint, runtime.GOOS, runtime.GOARCH),
+			)
+		}
+
+		if err := p.buildGoBinary(binaryPath); err != nil {
+			return err
+		}
+	}
+
+	// files extracted from the package are not executable
+	if err := os.Chmod(binaryPath, 0755); err != nil {
+		return fmt.Errorf("failed to make go binary executable: %s", err)
+	}
+
+	p.goBinaryPath = binaryPath
+	return nil
+}
+
+func (p *LocalPluginRuntime) buildGoBinary(binaryPath string) error {
+	compiler := p.goCompilerPath
+	if compiler == "" {
+		compiler = "go"
+	}
+
+	timeout := time.Duration(p.goBuildTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := os.MkdirAll(filepath.Dir(binaryPath), 0755); err != nil {
+		return fmt.Errorf("failed to create bin directory: %s", err)
+	}
+
+	// build into a temporary file to avoid a broken binary being picked up as prebuilt
+	tmpPath := binaryPath + ".tmp"
+	defer os.Remove(tmpPath)
+
+	log.Info("building go plugin %s", p.Config.Identity())
+
+	cmd := exec.CommandContext(ctx, compiler, "build", "-trimpath", "-o", tmpPath, ".")
+	cmd.Dir = p.State.WorkingPath
+	cmd.Env = append(
+		os.Environ(),
+		"CGO_ENABLED=0",
+		"GOOS="+runtime.GOOS,
+		"GOARCH="+runtime.GOARCH,
+	)
+	cmd.Env = append(cmd.Env, p.proxyEnv()...)
+
+	output := bytes.NewBuffer(nil)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("go build exceeded %s, output: %s", timeout, output.String())
+		}
+		return fmt.Errorf("failed to build go plugin: %s, output: %s", err, output.String())
+	}
+
+	if err := os.Rename(tmpPath, binaryPath); err != nil {
+		return fmt.Errorf("failed to move go binary: %s", err)
+	}
+
+	return nil
+}
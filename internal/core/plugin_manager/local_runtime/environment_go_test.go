@@ -0,0 +1,91 @@
+package local_runtime
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func newGoRuntime(t *testing.T, arch ...constants.Arch) *LocalPluginRuntime {
+	r := &LocalPluginRuntime{goBuildTimeout: 120}
+	r.State.WorkingPath = t.TempDir()
+	r.Config.Meta = plugin_entities.PluginMeta{
+		Arch: arch,
+		Runner: plugin_entities.PluginRunner{
+			Language:   constants.Go,
+			Entrypoint: "main",
+		},
+	}
+	return r
+}
+
+func TestInitGoEnvironmentPrebuilt(t *testing.T) {
+	r := newGoRuntime(t, constants.Arch(runtime.GOARCH))
+
+	binary := path.Join(r.State.WorkingPath, goBinaryName("main", runtime.GOOS, runtime.GOARCH))
+	if err := os.MkdirAll(path.Dir(binary), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.InitGoEnvironment(); err != nil {
+		t.Fatalf("failed to init go environment: %s", err)
+	}
+
+	if r.goBinaryPath != binary {
+		t.Fatalf("expected binary %s, got %s", binary, r.goBinaryPath)
+	}
+
+	info, err := os.Stat(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0111 == 0 {
+		t.Fatalf("binary should be executable, mode: %s", info.Mode())
+	}
+}
+
+func TestInitGoEnvironmentUnsupportedArch(t *testing.T) {
+	other := constants.AMD64
+	if runtime.GOARCH == string(constants.AMD64) {
+		other = constants.ARM64
+	}
+
+	r := newGoRuntime(t, other)
+	if err := r.InitGoEnvironment(); err == nil {
+		t.Fatal("expected an error for undeclared arch")
+	}
+}
+
+func TestInitGoEnvironmentBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	r := newGoRuntime(t, constants.Arch(runtime.GOARCH))
+	os.WriteFile(path.Join(r.State.WorkingPath, "go.mod"), []byte("module example.com/plugin\n\ngo 1.21\n"), 0644)
+	os.WriteFile(path.Join(r.State.WorkingPath, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	if err := r.InitGoEnvironment(); err != nil {
+		t.Fatalf("failed to build go plugin: %s", err)
+	}
+
+	if _, err := os.Stat(r.goBinaryPath); err != nil {
+		t.Fatalf("binary not found: %s", err)
+	}
+
+	cmd, err := r.getCmd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run built binary: %s", err)
+	}
+}
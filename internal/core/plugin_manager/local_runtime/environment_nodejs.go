@@ -0,0 +1,220 @@
+package local_runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// nodejs plugins are launched by `node <entrypoint>` and speak the same stdio protocol as python plugins,
+// dependencies are installed into node_modules of the working path by npm or pnpm depending on the lockfile,
+// a `build` script is run if the entrypoint is not shipped in the package, e.g. typescript sources only
+
+const (
+	NODE_PACKAGE_MANAGER_NPM  = "npm"
+	NODE_PACKAGE_MANAGER_PNPM = "pnpm"
+)
+
+type nodePackageJson struct {
+	Scripts map[string]string `json:"scripts"`
+}
+
+func (p *LocalPluginRuntime) InitNodeJSEnvironment() error {
+	nodePath := p.nodeExecutablePath
+	if nodePath == "" {
+		nodePath = "node"
+	}
+
+	output, err := exec.Command(nodePath, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("failed to find node: %s", err)
+	}
+
+	if err := checkNodeVersion(strings.TrimSpace(string(output)), p.Config.Meta.Runner.Version); err != nil {
+		return err
+	}
+
+	markerPath := path.Join(p.State.WorkingPath, "node_modules/.dify/plugin.json")
+	if _, err := os.Stat(markerPath); err == nil {
+		p.nodeInterpreterPath = nodePath
+		return nil
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			// an incomplete node_modules should not be picked up by the next launch
+			os.RemoveAll(path.Join(p.State.WorkingPath, "node_modules"))
+		}
+	}()
+
+	packageJsonBytes, err := os.ReadFile(path.Join(p.State.WorkingPath, "package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to find package.json: %s", err)
+	}
+
+	packageJson := nodePackageJson{}
+	if err := json.Unmarshal(packageJsonBytes, &packageJson); err != nil {
+		return fmt.Errorf("failed to parse package.json: %s", err)
+	}
+
+	// dev dependencies like typescript are only required if the plugin has to be built
+	_, hasBuildScript := packageJson.Scripts["build"]
+	needBuild := hasBuildScript && !nodeEntrypointExists(p.State.WorkingPath, p.Config.Meta.Runner.Entrypoint)
+
+	manager := detectNodePackageManager(p.State.WorkingPath)
+	managerPath := nodePackageManagerPath(nodePath, manager)
+
+	timeout := time.Duration(p.nodeEnvInitTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Info("installing nodejs dependencies of %s with %s", p.Config.Identity(), manager)
+	if err := p.runNodeCommand(ctx, nodePath, managerPath, nodeInstallArgs(p.State.WorkingPath, manager, !needBuild, p.npmRegistryUrl)...); err != nil {
+		return fmt.Errorf("failed to install dependencies: %s", err)
+	}
+
+	if needBuild {
+		log.Info("building nodejs plugin %s", p.Config.Identity())
+		if err := p.runNodeCommand(ctx, nodePath, managerPath, "run", "build"); err != nil {
+			return fmt.Errorf("failed to build plugin: %s", err)
+		}
+	}
+
+	if !nodeEntrypointExists(p.State.WorkingPath, p.Config.Meta.Runner.Entrypoint) {
+		return fmt.Errorf("failed to find entrypoint: %s", p.Config.Meta.Runner.Entrypoint)
+	}
+
+	if err := os.MkdirAll(path.Dir(markerPath), 0755); err != nil {
+		return fmt.Errorf("failed to create node_modules: %s", err)
+	}
+	if err := os.WriteFile(markerPath, []byte(`{"timestamp":`+strconv.FormatInt(time.Now().Unix(), 10)+`}`), 0644); err != nil {
+		return fmt.Errorf("failed to write plugin.json: %s", err)
+	}
+
+	p.nodeInterpreterPath = nodePath
+	success = true
+	return nil
+}
+
+func (p *LocalPluginRuntime) runNodeCommand(ctx context.Context, nodePath string, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = p.State.WorkingPath
+	// make sure the package manager and lifecycle scripts use the configured node
+	cmd.Env = append(os.Environ(), "PATH="+filepath.Dir(nodePath)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	cmd.Env = append(cmd.Env, p.proxyEnv()...)
+
+	output := bytes.NewBuffer(nil)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s exceeded the timeout, output: %s", name, output.String())
+		}
+		return fmt.Errorf("%s, output: %s", err, output.String())
+	}
+
+	return nil
+}
+
+// checkNodeVersion checks the installed node, e.g. v20.11.1, against the declared version, e.g. 20 or 20.11,
+// the major version must match and the installed one must not be older
+func checkNodeVersion(installed string, declared string) error {
+	installedVersion, err := version.NewVersion(strings.TrimPrefix(installed, "v"))
+	if err != nil {
+		return fmt.Errorf("failed to parse node version %s: %s", installed, err)
+	}
+
+	declaredVersion, err := version.NewVersion(strings.TrimPrefix(declared, "v"))
+	if err != nil {
+		return fmt.Errorf("failed to parse declared node version %s: %s", declared, err)
+	}
+
+	if installedVersion.Segments()[0] != declaredVersion.Segments()[0] || installedVersion.LessThan(declaredVersion) {
+		return fmt.Errorf("node %s is required, but %s is installed", declared, installed)
+	}
+
+	return nil
+}
+
+// detectNodePackageManager picks the package manager by the lockfile shipped in the package
+func detectNodePackageManager(root string) string {
+	if _, err := os.Stat(path.Join(root, "pnpm-lock.yaml")); err == nil {
+		return NODE_PACKAGE_MANAGER_PNPM
+	}
+	return NODE_PACKAGE_MANAGER_NPM
+}
+
+// nodePackageManagerPath prefers the package manager installed next to node
+func nodePackageManagerPath(nodePath string, manager string) string {
+	if filepath.IsAbs(nodePath) {
+		candidate := filepath.Join(filepath.Dir(nodePath), manager)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return manager
+}
+
+func nodeInstallArgs(root string, manager string, production bool, registry string) []string {
+	args := []string{}
+
+	switch manager {
+	case NODE_PACKAGE_MANAGER_PNPM:
+		args = append(args, "install", "--frozen-lockfile")
+		if production {
+			args = append(args, "--prod")
+		}
+	default:
+		// npm ci requires a lockfile and never changes it
+		if _, err := os.Stat(path.Join(root, "package-lock.json")); err == nil {
+			args = append(args, "ci")
+		} else {
+			args = append(args, "install", "--no-package-lock")
+		}
+		if production {
+			args = append(args, "--omit=dev")
+		}
+		args = append(args, "--no-audit", "--no-fund")
+	}
+
+	if registry != "" {
+		args = append(args, "--registry", registry)
+	}
+
+	return args
+}
+
+// nodeEntrypointExists resolves the entrypoint the same way as `node <entrypoint>` does for files
+func nodeEntrypointExists(root string, entrypoint string) bool {
+	candidates := []string{
+		entrypoint,
+		entrypoint + ".js",
+		entrypoint + ".mjs",
+		entrypoint + ".cjs",
+		path.Join(entrypoint, "index.js"),
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(path.Join(root, candidate)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package local_runtime
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestCheckNodeVersion(t *testing.T) {
+	cases := []struct {
+		installed string
+		declared  string
+		ok        bool
+	}{
+		{"v20.11.1", "20", true},
+		{"v20.11.1", "20.11", true},
+		{"v20.11.1", "20.12", false},
+		{"v22.1.0", "20", false},
+		{"v18.20.0", "20", false},
+		{"v20.11.1", "latest", false},
+	}
+
+	for _, c := range cases {
+		err := checkNodeVersion(c.installed, c.declared)
+		if (err == nil) != c.ok {
+			t.Errorf("checkNodeVersion(%s, %s) = %v, expected ok: %v", c.installed, c.declared, err, c.ok)
+		}
+	}
+}
+
+func TestNodeInstallArgs(t *testing.T) {
+	root := t.TempDir()
+
+	if detectNodePackageManager(root) != NODE_PACKAGE_MANAGER_NPM {
+		t.Fatal("npm should be used without a lockfile")
+	}
+
+	args := strings.Join(nodeInstallArgs(root, NODE_PACKAGE_MANAGER_NPM, true, ""), " ")
+	if !strings.HasPrefix(args, "install") || !strings.Contains(args, "--omit=dev") {
+		t.Fatalf("unexpected npm args: %s", args)
+	}
+
+	os.WriteFile(path.Join(root, "package-lock.json"), []byte("{}"), 0644)
+	args = strings.Join(nodeInstallArgs(root, NODE_PACKAGE_MANAGER_NPM, false, "https://registry.example.com"), " ")
+	if !strings.HasPrefix(args, "ci") || strings.Contains(args, "--omit=dev") ||
+		!strings.HasSuffix(args, "--registry https://registry.example.com") {
+		t.Fatalf("unexpected npm args: %s", args)
+	}
+
+	os.WriteFile(path.Join(root, "pnpm-lock.yaml"), []byte(""), 0644)
+	if detectNodePackageManager(root) != NODE_PACKAGE_MANAGER_PNPM {
+		t.Fatal("pnpm should be used with pnpm-lock.yaml")
+	}
+
+	args = strings.Join(nodeInstallArgs(root, NODE_PACKAGE_MANAGER_PNPM, true, ""), " ")
+	if args != "install --frozen-lockfile --prod" {
+		t.Fatalf("unexpected pnpm args: %s", args)
+	}
+}
+
+func TestInitNodeJSEnvironment(t *testing.T) {
+	output, err := exec.Command("node", "--version").Output()
+	if err != nil {
+		t.Skip("node not found")
+	}
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm not found")
+	}
+
+	major := strings.Split(strings.TrimPrefix(strings.TrimSpace(string(output)), "v"), ".")[0]
+
+	r := &LocalPluginRuntime{nodeExecutablePath: "node", nodeEnvInitTimeout: 120}
+	r.State.WorkingPath = t.TempDir()
+	r.Config.Meta = plugin_entities.PluginMeta{
+		Runner: plugin_entities.PluginRunner{
+			Language:   constants.NodeJS,
+			Version:    major,
+			Entrypoint: "dist/index",
+		},
+	}
+
+	// the entrypoint is produced by the build script
+	os.WriteFile(path.Join(r.State.WorkingPath, "package.json"), []byte(`{
+		"name": "plugin",
+		"scripts": {"build": "mkdir -p dist && echo 'process.exit(0)' > dist/index.js"}
+	}`), 0644)
+
+	if err := r.InitNodeJSEnvironment(); err != nil {
+		t.Fatalf("failed to init nodejs environment: %s", err)
+	}
+
+	if _, err := os.Stat(path.Join(r.State.WorkingPath, "node_modules/.dify/plugin.json")); err != nil {
+		t.Fatalf("environment should be marked as ready: %s", err)
+	}
+
+	cmd, err := r.getCmd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run plugin: %s", err)
+	}
+}
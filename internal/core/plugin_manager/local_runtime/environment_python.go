@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	version "github.com/hashicorp/go-version"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
 )
 
 //go:embed patches/0.0.1b70.ai_model.py.patch
@@ -37,6 +39,14 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 				return fmt.Errorf("failed to find python: %s", err)
 			}
 			p.pythonInterpreterPath = pythonPath
+			// keep the shared environment from being collected while it's still in use,
+			// it's read-only and was patched when it was built
+			if p.pythonEnvCache != nil {
+				if key, ok := p.pythonEnvCache.KeyOf(p.State.WorkingPath); ok {
+					p.pythonEnvCache.Touch(key)
+					return nil
+				}
+			}
 			// PATCH:
 			//  plugin sdk version less than 0.0.1b70 contains a memory leak bug
 			//  to reach a better user experience, we will patch it here using a patched file
@@ -48,6 +58,42 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 		}
 	}
 
+	// wheels vendored in the package are installed without accessing any index
+	wheelsPath, err := p.vendoredWheelsPath()
+	if err != nil {
+		return err
+	}
+
+	// reuse the shared virtual environment if the same dependencies were installed before,
+	// otherwise build it into the cache and link it to the working path
+	cacheKey := ""
+	if p.pythonEnvCache != nil {
+		key, err := p.pythonEnvCacheKey()
+		if err != nil {
+			log.Warn("python env cache disabled for %s: %s", p.Config.Identity(), err)
+		} else {
+			p.pythonEnvCache.Lock(key)
+			defer p.pythonEnvCache.Unlock(key)
+
+			if p.pythonEnvCache.Ready(key) {
+				if err := p.pythonEnvCache.Link(key, p.State.WorkingPath); err != nil {
+					return fmt.Errorf("failed to link cached virtual environment: %s", err)
+				}
+				pythonPath, err := filepath.Abs(path.Join(p.State.WorkingPath, ".venv/bin/python"))
+				if err != nil {
+					return fmt.Errorf("failed to find python: %s", err)
+				}
+				p.pythonInterpreterPath = pythonPath
+				log.Info("reused cached python environment %s for %s", key, p.Config.Identity())
+				return nil
+			}
+
+			// leftovers of a failed build
+			p.pythonEnvCache.Remove(key)
+			cacheKey = key
+		}
+	}
+
 	// execute init command, create a virtual environment
 	success := false
 
@@ -61,7 +107,12 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 
 	uvPath := strings.TrimSpace(string(output))
 
-	cmd = exec.Command(uvPath, "venv", ".venv")
+	venvPath := ".venv"
+	if cacheKey != "" {
+		venvPath = p.pythonEnvCache.VenvPath(cacheKey)
+	}
+
+	cmd = exec.Command(uvPath, "venv", venvPath)
 	cmd.Dir = p.State.WorkingPath
 	b := bytes.NewBuffer(nil)
 	cmd.Stdout = b
@@ -73,14 +124,28 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 		// if init failed, remove the .venv directory
 		if !success {
 			os.RemoveAll(path.Join(p.State.WorkingPath, ".venv"))
+			if cacheKey != "" {
+				p.pythonEnvCache.Remove(cacheKey)
+			}
 		} else {
 			// create dify/plugin.json
 			pluginJsonPath := path.Join(p.State.WorkingPath, ".venv/dify/plugin.json")
 			os.MkdirAll(path.Dir(pluginJsonPath), 0755)
 			os.WriteFile(pluginJsonPath, []byte(`{"timestamp":`+strconv.FormatInt(time.Now().Unix(), 10)+`}`), 0644)
+			if cacheKey != "" {
+				if err := p.pythonEnvCache.Seal(cacheKey); err != nil {
+					log.Warn("failed to make cached python environment %s read-only: %s", cacheKey, err)
+				}
+			}
 		}
 	}()
 
+	if cacheKey != "" {
+		if err := p.pythonEnvCache.Link(cacheKey, p.State.WorkingPath); err != nil {
+			return fmt.Errorf("failed to link cached virtual environment: %s", err)
+		}
+	}
+
 	pythonPath, err := filepath.Abs(path.Join(p.State.WorkingPath, ".venv/bin/python"))
 	if err != nil {
 		return fmt.Errorf("failed to find python: %s", err)
@@ -104,7 +169,9 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 
 	args := []string{"install"}
 
-	if p.pipMirrorUrl != "" {
+	if wheelsPath != "" {
+		args = append(args, "--no-index", "--find-links", wheelsPath)
+	} else if p.pipMirrorUrl != "" {
 		args = append(args, "-i", p.pipMirrorUrl)
 	}
 
@@ -217,6 +284,12 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 	wg.Wait()
 
 	if err := cmd.Wait(); err != nil {
+		if wheelsPath != "" {
+			return fmt.Errorf(
+				"failed to install dependencies from vendored wheels, a wheel for %s may be missing in the package: %s, output: %s",
+				runtime.GOARCH, err, errMsg.String(),
+			)
+		}
 		return fmt.Errorf("failed to install dependencies: %s, output: %s", err, errMsg.String())
 	}
 
@@ -317,11 +390,57 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 		log.Error("failed to patch the plugin sdk: %s", err)
 	}
 
+	// a shared environment is read-only afterwards, python is not able to write the bytecode on imports
+	if cacheKey != "" {
+		compileVenvCmd := exec.CommandContext(ctx, pythonPath, "-m", "compileall", "-q", venvPath)
+		compileVenvCmd.Dir = p.State.WorkingPath
+		if output, err := compileVenvCmd.CombinedOutput(); err != nil {
+			log.Warn("failed to pre-compile cached python environment %s: %s, output: %s", cacheKey, err, string(output))
+		}
+	}
+
 	success = true
 
 	return nil
 }
 
+// vendoredWheelsPath returns the absolute path of wheels vendored for current arch, empty if the package vendors nothing
+func (p *LocalPluginRuntime) vendoredWheelsPath() (string, error) {
+	wheelsRoot := path.Join(p.State.WorkingPath, packager.VENDOR_WHEELS_DIR)
+	if _, err := os.Stat(wheelsRoot); err != nil {
+		return "", nil
+	}
+
+	wheelsPath, err := filepath.Abs(path.Join(wheelsRoot, runtime.GOARCH))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(wheelsPath); err != nil {
+		return "", fmt.Errorf(
+			"the package vendors wheels but not for %s, repackage it with %s declared in meta.arch",
+			runtime.GOARCH, runtime.GOARCH,
+		)
+	}
+
+	return wheelsPath, nil
+}
+
+// pythonEnvCacheKey identifies the environment by requirements.txt and the version of the default interpreter
+func (p *LocalPluginRuntime) pythonEnvCacheKey() (string, error) {
+	requirements, err := os.ReadFile(path.Join(p.State.WorkingPath, "requirements.txt"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read requirements.txt: %s", err)
+	}
+
+	output, err := exec.Command(p.defaultPythonInterpreterPath, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get python version: %s", err)
+	}
+
+	return p.pythonEnvCache.Key(requirements, string(output)), nil
+}
+
 func (p *LocalPluginRuntime) patchPluginSdk(requirementsPath string) error {
 	// get the version of the plugin sdk
 	requirements, err := os.ReadFile(requirementsPath)
@@ -0,0 +1,33 @@
+package local_runtime
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"testing"
+)
+
+func TestVendoredWheelsPath(t *testing.T) {
+	r := &LocalPluginRuntime{}
+	r.State.WorkingPath = t.TempDir()
+
+	wheelsPath, err := r.vendoredWheelsPath()
+	if err != nil || wheelsPath != "" {
+		t.Fatalf("nothing is vendored, got %s, %v", wheelsPath, err)
+	}
+
+	// vendored for other arch only
+	os.MkdirAll(path.Join(r.State.WorkingPath, "wheels", "other"), 0755)
+	if _, err := r.vendoredWheelsPath(); err == nil {
+		t.Fatal("missing wheels for current arch should fail fast")
+	}
+
+	os.MkdirAll(path.Join(r.State.WorkingPath, "wheels", runtime.GOARCH), 0755)
+	wheelsPath, err = r.vendoredWheelsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wheelsPath != path.Join(r.State.WorkingPath, "wheels", runtime.GOARCH) {
+		t.Fatalf("unexpected wheels path: %s", wheelsPath)
+	}
+}
@@ -1,21 +1,35 @@
 package local_runtime
 
 import (
+	"fmt"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
-func (r *LocalPluginRuntime) Listen(session_id string) *entities.Broadcast[plugin_entities.SessionMessage] {
+// Listen binds the session to a worker, all messages of the session are exchanged with the worker
+// fails with ErrPluginUnavailable when no worker is ready, e.g. cold starting or backing off after a crash
+func (r *LocalPluginRuntime) Listen(session_id string) (*entities.Broadcast[plugin_entities.SessionMessage], error) {
+	worker := r.workers.assign(session_id)
+	if worker == nil {
+		return nil, fmt.Errorf(
+			"%w: no worker of plugin %s is ready", plugin_errors.ErrPluginUnavailable, r.Config.Identity(),
+		)
+	}
+
 	listener := entities.NewBroadcast[plugin_entities.SessionMessage]()
+
+	ioIdentity := worker.stdio.GetID()
 	listener.OnClose(func() {
-		removeStdioHandlerListener(r.ioIdentity, session_id)
+		removeStdioHandlerListener(ioIdentity, session_id)
+		r.workers.release(session_id)
 	})
-	setupStdioEventListener(r.ioIdentity, session_id, func(b []byte) {
+	setupStdioEventListener(ioIdentity, session_id, func(b []byte) {
 		// unmarshal the session message
-		data, err := parser.UnmarshalJsonBytes[plugin_entities.SessionMessage](b)
+		data, err := plugin_entities.ParseSessionMessage(b)
 		if err != nil {
 			log.Error("unmarshal json failed: %s, failed to parse session message", err.Error())
 			return
@@ -23,9 +37,17 @@ func (r *LocalPluginRuntime) Listen(session_id string) *entities.Broadcast[plugi
 
 		listener.Send(data)
 	})
-	return listener
+	return listener, nil
 }
 
+// Write sends data to the worker owning the session
 func (r *LocalPluginRuntime) Write(session_id string, action access_types.PluginAccessAction, data []byte) {
-	writeToStdioHandler(r.ioIdentity, append(data, '\n'))
+	worker := r.workers.lookup(session_id)
+	if worker == nil {
+		log.Error("no worker of plugin %s is ready for session %s", r.Config.Identity(), session_id)
+		return
+	}
+	if err := writeToStdioHandler(worker.stdio.GetID(), data); err != nil {
+		log.Error("write to plugin %s failed: %s", r.Config.Identity(), err.Error())
+	}
 }
@@ -0,0 +1,30 @@
+package local_runtime
+
+import (
+	"os"
+	"os/exec"
+)
+
+// memoryLimiter enforces the memory declared in the plugin manifest on a plugin,
+// all workers of the plugin share one limit
+type memoryLimiter interface {
+	// Prepare places the process of the command under the limit before it executes,
+	// it should be called right before the command starts, after the command is sandboxed
+	Prepare(cmd *exec.Cmd) error
+	// Attach watches the started process with the given pid and its descendants
+	Attach(pid int) error
+	// Exited stops watching the process waited with the given state,
+	// returns true if it was killed for exceeding the limit
+	Exited(pid int, state *os.ProcessState) bool
+	// Release releases resources held by the limiter, it should be called after all processes exited
+	Release()
+}
+
+// newMemoryLimiter returns a limiter for the plugin, nil if memory limit is disabled
+func (r *LocalPluginRuntime) newMemoryLimiter() memoryLimiter {
+	if !r.memoryLimitEnabled || r.Config.Resource.Memory <= 0 {
+		return nil
+	}
+
+	return newPlatformMemoryLimiter(r.cgroupRoot, r.Config.Resource.Memory)
+}
@@ -0,0 +1,375 @@
+//go:build linux
+
+package local_runtime
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// cgroup v2 exposes an unified hierarchy, cgroup.controllers only exists on it
+	CGROUP_V2_MOUNT_POINT = "/sys/fs/cgroup"
+
+	// interval to check the resident memory of a plugin when cgroup v2 is not available
+	RSS_MEMORY_CHECK_INTERVAL = time.Second
+)
+
+var (
+	cgroupUnavailableOnce sync.Once
+)
+
+func newPlatformMemoryLimiter(cgroupRoot string, limit int64) memoryLimiter {
+	limiter, err := newCgroupMemoryLimiter(cgroupRoot, limit)
+	if err == nil {
+		return limiter
+	}
+
+	cgroupUnavailableOnce.Do(func() {
+		log.Warn("cgroup v2 is not available, falling back to watching resident memory of plugins: %s", err.Error())
+	})
+
+	return newRssMemoryLimiter(limit)
+}
+
+// cgroupMemoryLimiter puts all processes of the plugin into one cgroup v2 with memory.max set,
+// processes are cloned into the cgroup so the limit applies before the plugin executes,
+// the kernel oom killer kills a process once the plugin exceeds the limit
+type cgroupMemoryLimiter struct {
+	path string
+	fd   int
+
+	mu sync.Mutex
+	// oom kills of the cgroup when each process was attached
+	oomKills map[int]int
+}
+
+func newCgroupMemoryLimiter(cgroupRoot string, limit int64) (*cgroupMemoryLimiter, error) {
+	controllers, err := os.ReadFile(path.Join(CGROUP_V2_MOUNT_POINT, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not mounted: %s", err)
+	}
+
+	if !slices.Contains(strings.Fields(string(controllers)), "memory") {
+		return nil, errors.New("memory controller is not enabled")
+	}
+
+	if err := os.MkdirAll(cgroupRoot, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup root: %s", err)
+	}
+
+	// children of cgroup root need the memory controller to be delegated
+	subtreeControl, err := os.ReadFile(path.Join(cgroupRoot, "cgroup.subtree_control"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtree control: %s", err)
+	}
+
+	if !slices.Contains(strings.Fields(string(subtreeControl)), "memory") {
+		if err := os.WriteFile(path.Join(cgroupRoot, "cgroup.subtree_control"), []byte("+memory"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to enable memory controller: %s", err)
+		}
+	}
+
+	cgroupPath := path.Join(cgroupRoot, uuid.New().String())
+	if err := os.Mkdir(cgroupPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %s", err)
+	}
+
+	limiter := &cgroupMemoryLimiter{path: cgroupPath, fd: -1, oomKills: map[int]int{}}
+
+	if err := os.WriteFile(
+		path.Join(cgroupPath, "memory.max"), []byte(strconv.FormatInt(limit, 10)), 0644,
+	); err != nil {
+		limiter.Release()
+		return nil, fmt.Errorf("failed to set memory.max: %s", err)
+	}
+
+	// disable swap to make sure the limit is a hard one, swap accounting may be disabled by kernel
+	os.WriteFile(path.Join(cgroupPath, "memory.swap.max"), []byte("0"), 0644)
+
+	limiter.fd, err = unix.Open(cgroupPath, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		limiter.Release()
+		return nil, fmt.Errorf("failed to open cgroup: %s", err)
+	}
+
+	return limiter, nil
+}
+
+func (c *cgroupMemoryLimiter) Prepare(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// the process and everything it forks, e.g. the plugin inside the sandbox, start in the cgroup
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = c.fd
+	return nil
+}
+
+func (c *cgroupMemoryLimiter) Attach(pid int) error {
+	// the kernel enforces the limit, only oom kills during the lifetime of the process are tracked
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.oomKills[pid] = c.oomKillCount()
+	return nil
+}
+
+// Exited reports the process as killed for exceeding the limit if it was killed by SIGKILL and
+// the cgroup recorded an oom kill while it was running, workers share the cgroup,
+// so workers exiting otherwise, e.g. retired by autoscaling, are not blamed
+func (c *cgroupMemoryLimiter) Exited(pid int, state *os.ProcessState) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	attached, ok := c.oomKills[pid]
+	delete(c.oomKills, pid)
+	if !ok || !killedBySigkill(state) {
+		return false
+	}
+
+	return c.oomKillCount() > attached
+}
+
+// killedBySigkill returns true if the process was killed by SIGKILL,
+// the sandbox exits with 128 + signal once the plugin inside it is killed
+func killedBySigkill(state *os.ProcessState) bool {
+	if state == nil {
+		return false
+	}
+
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+
+	if status.Signaled() {
+		return status.Signal() == syscall.SIGKILL
+	}
+	return status.Exited() && status.ExitStatus() == 128+int(syscall.SIGKILL)
+}
+
+func (c *cgroupMemoryLimiter) oomKillCount() int {
+	events, err := os.ReadFile(path.Join(c.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(events))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0
+			}
+			return count
+		}
+	}
+
+	return 0
+}
+
+func (c *cgroupMemoryLimiter) Release() {
+	if c.fd >= 0 {
+		unix.Close(c.fd)
+		c.fd = -1
+	}
+
+	// a cgroup could only be removed once all processes in it exited
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		log.Warn("failed to remove cgroup %s: %s", c.path, err.Error())
+	}
+}
+
+// rssMemoryLimiter watches the resident memory of all processes of the plugin together,
+// the largest worker is killed once the sum exceeds the limit, no rlimit is set as RLIMIT_DATA
+// counts reserved heaps of runtimes like V8 and fails allocations long before the memory is resident
+type rssMemoryLimiter struct {
+	limit int64
+
+	mu        sync.Mutex
+	processes map[int]bool
+	exceeded  map[int]bool
+	watching  bool
+	stopChan  chan bool
+	stopOnce  sync.Once
+}
+
+func newRssMemoryLimiter(limit int64) *rssMemoryLimiter {
+	return &rssMemoryLimiter{
+		limit:     limit,
+		processes: map[int]bool{},
+		exceeded:  map[int]bool{},
+		stopChan:  make(chan bool),
+	}
+}
+
+func (l *rssMemoryLimiter) Prepare(cmd *exec.Cmd) error {
+	// processes are watched once started
+	return nil
+}
+
+func (l *rssMemoryLimiter) Attach(pid int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.processes[pid] = true
+	if l.watching {
+		return nil
+	}
+	l.watching = true
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"type":     "local",
+		"function": "WatchMemory",
+	}, func() {
+		ticker := time.NewTicker(RSS_MEMORY_CHECK_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				l.check()
+			case <-l.stopChan:
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// check kills the largest process tree once all processes of the plugin exceed the limit
+func (l *rssMemoryLimiter) check() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.processes) == 0 {
+		return
+	}
+
+	children := processChildren()
+	total := int64(0)
+	largest, largestRss := 0, int64(-1)
+	for pid := range l.processes {
+		rss := int64(0)
+		for _, p := range processTree(pid, children) {
+			// processes may have exited already
+			if r, err := residentMemory(p); err == nil {
+				rss += r
+			}
+		}
+		total += rss
+		if rss > largestRss {
+			largest, largestRss = pid, rss
+		}
+	}
+
+	if total > l.limit {
+		l.exceeded[largest] = true
+		delete(l.processes, largest)
+		for _, p := range processTree(largest, children) {
+			syscall.Kill(p, syscall.SIGKILL)
+		}
+	}
+}
+
+func (l *rssMemoryLimiter) Exited(pid int, state *os.ProcessState) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	exceeded := l.exceeded[pid]
+	delete(l.processes, pid)
+	delete(l.exceeded, pid)
+	return exceeded
+}
+
+func (l *rssMemoryLimiter) Release() {
+	l.stopOnce.Do(func() {
+		close(l.stopChan)
+	})
+}
+
+// processChildren maps pids to their children by scanning /proc
+func processChildren() map[int][]int {
+	children := map[int][]int{}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return children
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			continue
+		}
+		// stat: pid (comm) state ppid ..., comm may contain spaces and parentheses
+		i := bytes.LastIndexByte(stat, ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[i+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	return children
+}
+
+// processTree returns the pid and all its descendants
+func processTree(pid int, children map[int][]int) []int {
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
+
+// residentMemory returns the resident memory of the process in bytes
+func residentMemory(pid int) (int64, error) {
+	statm, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+
+	// statm: size resident shared text lib data dt, in pages
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, errors.New("invalid statm")
+	}
+
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return pages * int64(os.Getpagesize()), nil
+}
@@ -0,0 +1,204 @@
+//go:build linux
+
+package local_runtime
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+func TestRssMemoryLimiterKillsExceededProcess(t *testing.T) {
+	routine.InitPool(1024)
+
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start process: %s", err)
+	}
+
+	// any process exceeds 1 byte
+	limiter := newRssMemoryLimiter(1)
+	defer limiter.Release()
+
+	if err := limiter.Attach(cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("failed to attach limiter: %s", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("process should be killed after exceeding the memory limit")
+	}
+
+	if !limiter.Exited(cmd.Process.Pid, cmd.ProcessState) {
+		t.Fatal("limiter should report the process exceeded the memory limit")
+	}
+}
+
+func TestRssMemoryLimiterKeepsProcessWithinLimit(t *testing.T) {
+	routine.InitPool(1024)
+
+	limiter := newRssMemoryLimiter(1024 * 1024 * 1024)
+	defer limiter.Release()
+
+	cmd := exec.Command("sleep", "2")
+	if err := limiter.Prepare(cmd); err != nil {
+		t.Fatalf("failed to prepare command: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start process: %s", err)
+	}
+
+	if err := limiter.Attach(cmd.Process.Pid); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("failed to attach limiter: %s", err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("process should exit normally: %s", err)
+	}
+
+	if limiter.Exited(cmd.Process.Pid, cmd.ProcessState) {
+		t.Fatal("limiter should not report the process exceeded the memory limit")
+	}
+}
+
+func TestProcessTree(t *testing.T) {
+	// the shell forks sleep instead of executing it as another command follows
+	cmd := exec.Command("sh", "-c", "sleep 5; true")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start process: %s", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	// processes forked by the plugin, e.g. the plugin inside the sandbox, count towards the limit
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if tree := processTree(cmd.Process.Pid, processChildren()); len(tree) > 1 {
+			if tree[0] != cmd.Process.Pid || slices.Contains(tree[1:], cmd.Process.Pid) {
+				t.Fatalf("unexpected process tree: %v", tree)
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("descendants of the process should be found")
+}
+
+func TestRssMemoryLimiterSharedByWorkers(t *testing.T) {
+	routine.InitPool(1024)
+
+	workers := []*exec.Cmd{exec.Command("sleep", "10"), exec.Command("sleep", "10")}
+	for _, cmd := range workers {
+		if err := cmd.Start(); err != nil {
+			t.Skipf("failed to start process: %s", err)
+		}
+		defer func(cmd *exec.Cmd) {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}(cmd)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// each worker stays within the limit, both together exceed it
+	total := int64(0)
+	for _, cmd := range workers {
+		rss, err := residentMemory(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += rss
+	}
+
+	limiter := newRssMemoryLimiter(total - 1)
+	defer limiter.Release()
+	for _, cmd := range workers {
+		if err := limiter.Attach(cmd.Process.Pid); err != nil {
+			t.Fatalf("failed to attach limiter: %s", err)
+		}
+	}
+
+	time.Sleep(3 * RSS_MEMORY_CHECK_INTERVAL)
+
+	killed := 0
+	for _, cmd := range workers {
+		if limiter.Exited(cmd.Process.Pid, cmd.ProcessState) {
+			killed++
+		}
+	}
+	if killed != 1 {
+		t.Fatalf("exactly one worker should be killed to get back within the limit, got %d", killed)
+	}
+}
+
+func TestCgroupMemoryLimiterBlamesKilledWorkerOnly(t *testing.T) {
+	dir := t.TempDir()
+	setOomKills := func(count int) {
+		events := fmt.Sprintf("low 0\nhigh 0\nmax 1\noom %d\noom_kill %d\n", count, count)
+		if err := os.WriteFile(path.Join(dir, "memory.events"), []byte(events), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setOomKills(0)
+
+	// workers share the cgroup, not created here as cgroup v2 may be unavailable
+	limiter := &cgroupMemoryLimiter{path: dir, fd: -1, oomKills: map[int]int{}}
+
+	killed := exec.Command("sleep", "10")
+	retired := exec.Command("sh", "-c", "exit 0")
+	for _, cmd := range []*exec.Cmd{killed, retired} {
+		if err := cmd.Start(); err != nil {
+			t.Skipf("failed to start process: %s", err)
+		}
+		limiter.Attach(cmd.Process.Pid)
+	}
+
+	// the oom killer kills the first worker, the other one exits by itself afterwards
+	setOomKills(1)
+	killed.Process.Signal(syscall.SIGKILL)
+	killed.Wait()
+	retired.Wait()
+
+	if limiter.Exited(retired.Process.Pid, retired.ProcessState) {
+		t.Fatal("worker exiting by itself should not be reported as killed for exceeding the limit")
+	}
+	if !limiter.Exited(killed.Process.Pid, killed.ProcessState) {
+		t.Fatal("worker killed by the oom killer should be reported as exceeding the limit")
+	}
+}
+
+func TestKilledBySigkill(t *testing.T) {
+	for script, expected := range map[string]bool{
+		"kill -9 $$": true,
+		// the sandbox reports a killed plugin as 128 + signal
+		"exit 137": true,
+		"exit 1":   false,
+		"kill $$":  false,
+	} {
+		cmd := exec.Command("sh", "-c", script)
+		if err := cmd.Start(); err != nil {
+			t.Skipf("failed to start process: %s", err)
+		}
+		cmd.Wait()
+		if killedBySigkill(cmd.ProcessState) != expected {
+			t.Fatalf("expected %v for %q, got %v", expected, script, !expected)
+		}
+	}
+}
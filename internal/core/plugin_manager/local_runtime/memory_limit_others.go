@@ -0,0 +1,20 @@
+//go:build !linux
+
+package local_runtime
+
+import (
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+var (
+	memoryLimitUnsupportedOnce sync.Once
+)
+
+func newPlatformMemoryLimiter(cgroupRoot string, limit int64) memoryLimiter {
+	memoryLimitUnsupportedOnce.Do(func() {
+		log.Warn("memory limit of plugins is only supported on linux, skipped")
+	})
+	return nil
+}
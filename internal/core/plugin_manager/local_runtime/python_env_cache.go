@@ -0,0 +1,201 @@
+package local_runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/lock"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// PythonEnvCache shares virtual environments between plugins with identical dependencies,
+// an environment is keyed by the normalized requirements.txt and the python version,
+// plugins link their .venv to the shared one instead of installing everything again,
+// an environment is made read-only once installed so that no plugin is able to alter it for the others
+//
+// layout:
+//
+//	<root>/<key>/.venv         the virtual environment
+//	<root>/<key>/last_used     touched every time a plugin links to it
+type PythonEnvCache struct {
+	root string
+	lock *lock.GranularityLock
+}
+
+func NewPythonEnvCache(root string) (*PythonEnvCache, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	return &PythonEnvCache{
+		root: root,
+		lock: lock.NewGranularityLock(),
+	}, nil
+}
+
+// Key returns the cache key of the dependencies, comments, blank lines and the order of requirements are ignored
+func (c *PythonEnvCache) Key(requirements []byte, pythonVersion string) string {
+	lines := []string{}
+	for _, line := range strings.Split(string(requirements), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.ToLower(strings.Join(strings.Fields(line), " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+
+	hash := sha256.New()
+	hash.Write([]byte(strings.TrimSpace(pythonVersion)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// VenvPath returns the absolute path of the virtual environment of the key
+func (c *PythonEnvCache) VenvPath(key string) string {
+	return path.Join(c.root, key, ".venv")
+}
+
+// Ready checks if the virtual environment of the key is completely installed
+func (c *PythonEnvCache) Ready(key string) bool {
+	_, err := os.Stat(path.Join(c.VenvPath(key), "dify/plugin.json"))
+	return err == nil
+}
+
+// Lock prevents the environment from being built concurrently or collected while in use
+func (c *PythonEnvCache) Lock(key string) {
+	c.lock.Lock(key)
+}
+
+func (c *PythonEnvCache) Unlock(key string) {
+	c.lock.Unlock(key)
+}
+
+// Touch records that the environment is in use, it's protected from GC for a grace period
+func (c *PythonEnvCache) Touch(key string) {
+	lastUsed := path.Join(c.root, key, "last_used")
+	now := time.Now()
+	if err := os.Chtimes(lastUsed, now, now); err != nil {
+		os.WriteFile(lastUsed, nil, 0644)
+	}
+}
+
+// Seal makes the installed environment of the key read-only, caller should hold the lock
+func (c *PythonEnvCache) Seal(key string) error {
+	return filepath.Walk(c.VenvPath(key), func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// the mode of a symlink is the one of its target
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return os.Chmod(name, info.Mode().Perm()&^0222)
+	})
+}
+
+// Remove drops the environment of the key, caller should hold the lock
+func (c *PythonEnvCache) Remove(key string) {
+	// entries of a sealed environment can't be unlinked until the directories are writable again
+	filepath.Walk(path.Join(c.root, key), func(name string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(name, info.Mode().Perm()|0700)
+		}
+		return nil
+	})
+	os.RemoveAll(path.Join(c.root, key))
+}
+
+// Link points <workingPath>/.venv to the environment of the key
+func (c *PythonEnvCache) Link(key string, workingPath string) error {
+	link := path.Join(workingPath, ".venv")
+	os.RemoveAll(link)
+	if err := os.Symlink(c.VenvPath(key), link); err != nil {
+		return err
+	}
+	c.Touch(key)
+	return nil
+}
+
+// KeyOf returns the key of the environment <workingPath>/.venv links to, false if it's not a cached one
+func (c *PythonEnvCache) KeyOf(workingPath string) (string, bool) {
+	target, err := os.Readlink(path.Join(workingPath, ".venv"))
+	if err != nil {
+		return "", false
+	}
+
+	target = filepath.Clean(target)
+	key := filepath.Base(filepath.Dir(target))
+	if filepath.Base(target) != ".venv" || filepath.Dir(filepath.Dir(target)) != c.root {
+		return "", false
+	}
+	return key, true
+}
+
+// GC removes environments which are not linked from any plugin under workingRoot
+// and have not been used within grace, returns the number of removed environments
+//
+// plugins are extracted to <workingRoot>/<author>/<name>:<version>@<checksum>,
+// both this layout and a flat one are scanned for links
+func (c *PythonEnvCache) GC(workingRoot string, grace time.Duration) int {
+	referenced := map[string]bool{}
+	for _, pattern := range []string{"*", "*/*"} {
+		workingPaths, err := filepath.Glob(path.Join(workingRoot, pattern))
+		if err != nil {
+			continue
+		}
+		for _, workingPath := range workingPaths {
+			if key, ok := c.KeyOf(workingPath); ok {
+				referenced[key] = true
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		log.Error("failed to read python env cache: %s", err)
+		return 0
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		key := entry.Name()
+		if referenced[key] {
+			continue
+		}
+
+		c.Lock(key)
+		lastUsed := time.Time{}
+		if info, err := os.Stat(path.Join(c.root, key, "last_used")); err == nil {
+			lastUsed = info.ModTime()
+		} else if info, err := entry.Info(); err == nil {
+			lastUsed = info.ModTime()
+		}
+
+		if time.Since(lastUsed) > grace {
+			c.Remove(key)
+			removed++
+		}
+		c.Unlock(key)
+	}
+
+	return removed
+}
@@ -0,0 +1,120 @@
+package local_runtime
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestPythonEnvCacheKey(t *testing.T) {
+	cache, err := NewPythonEnvCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := cache.Key([]byte("dify_plugin==0.0.1b70\nrequests==2.31.0\n"), "Python 3.12.1")
+	b := cache.Key([]byte("# deps\nrequests==2.31.0   # http\n\nDify_Plugin==0.0.1b70\n"), "Python 3.12.1\n")
+	if a != b {
+		t.Fatalf("equivalent requirements should share a key: %s != %s", a, b)
+	}
+
+	if a == cache.Key([]byte("dify_plugin==0.0.1b70\nrequests==2.31.0\n"), "Python 3.11.9") {
+		t.Fatal("python version should be part of the key")
+	}
+
+	if a == cache.Key([]byte("dify_plugin==0.0.1b71\nrequests==2.31.0\n"), "Python 3.12.1") {
+		t.Fatal("requirements should be part of the key")
+	}
+}
+
+func prepareCachedVenv(t *testing.T, cache *PythonEnvCache, key string) {
+	if err := os.MkdirAll(path.Join(cache.VenvPath(key), "dify"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(cache.VenvPath(key), "dify/plugin.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPythonEnvCacheGC(t *testing.T) {
+	cache, err := NewPythonEnvCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workingRoot := t.TempDir()
+
+	for _, key := range []string{"linked", "recent", "stale"} {
+		prepareCachedVenv(t, cache, key)
+	}
+
+	// same layout as the launcher, <author>/<name>:<version>@<checksum>
+	pluginPath := path.Join(workingRoot, "langgenius", "test:0.0.1@checksum")
+	os.MkdirAll(pluginPath, 0755)
+	if err := cache.Link("linked", pluginPath); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.Ready("linked") {
+		t.Fatal("linked environment should be ready")
+	}
+	if _, err := os.Stat(path.Join(pluginPath, ".venv/dify/plugin.json")); err != nil {
+		t.Fatalf(".venv should point to the cached environment: %s", err)
+	}
+	if key, ok := cache.KeyOf(pluginPath); !ok || key != "linked" {
+		t.Fatalf("expected the link to be resolved to linked, got %s, %v", key, ok)
+	}
+
+	cache.Touch("recent")
+
+	// stale one was used long ago
+	cache.Touch("stale")
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(path.Join(cache.root, "stale", "last_used"), old, old)
+
+	// linked one is kept even if unused for long
+	os.Chtimes(path.Join(cache.root, "linked", "last_used"), old, old)
+
+	if removed := cache.GC(workingRoot, 24*time.Hour); removed != 1 {
+		t.Fatalf("expected 1 environment removed, got %d", removed)
+	}
+
+	if !cache.Ready("linked") || !cache.Ready("recent") {
+		t.Fatal("linked and recently used environments should be kept")
+	}
+	if cache.Ready("stale") {
+		t.Fatal("stale environment should be removed")
+	}
+
+	// once the plugin is removed, the environment is collectable
+	os.RemoveAll(pluginPath)
+	if removed := cache.GC(workingRoot, 24*time.Hour); removed != 1 {
+		t.Fatalf("expected the unlinked environment removed, got %d", removed)
+	}
+}
+
+func TestPythonEnvCacheSeal(t *testing.T) {
+	cache, err := NewPythonEnvCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prepareCachedVenv(t, cache, "sealed")
+	if err := cache.Seal("sealed"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"", "dify", "dify/plugin.json"} {
+		info, err := os.Stat(path.Join(cache.VenvPath("sealed"), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm()&0222 != 0 {
+			t.Fatalf("%s should be read-only, got %s", name, info.Mode())
+		}
+	}
+
+	cache.Remove("sealed")
+	if _, err := os.Stat(path.Join(cache.root, "sealed")); !os.IsNotExist(err) {
+		t.Fatalf("sealed environment should be removed, got %v", err)
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"os"
 	"os/exec"
 	"sync"
+	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
@@ -16,10 +19,6 @@ import (
 
 // gc performs garbage collection for the LocalPluginRuntime
 func (r *LocalPluginRuntime) gc() {
-	if r.ioIdentity != "" {
-		removeStdioHandler(r.ioIdentity)
-	}
-
 	if r.waitChan != nil {
 		close(r.waitChan)
 		r.waitChan = nil
@@ -31,25 +30,62 @@ func (r *LocalPluginRuntime) Type() plugin_entities.PluginRuntimeType {
 	return plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
 }
 
+// proxyEnv returns the proxy settings passed to build tools
+func (r *LocalPluginRuntime) proxyEnv() []string {
+	env := []string{}
+	if r.HttpsProxy != "" {
+		env = append(env, fmt.Sprintf("HTTPS_PROXY=%s", r.HttpsProxy))
+	}
+	if r.HttpProxy != "" {
+		env = append(env, fmt.Sprintf("HTTP_PROXY=%s", r.HttpProxy))
+	}
+	return env
+}
+
+// pluginProxies returns the proxies the plugin process goes through
+func (r *LocalPluginRuntime) pluginProxies() (string, string) {
+	if r.EgressProxy != "" {
+		return r.EgressProxy, r.EgressProxy
+	}
+	return r.HttpProxy, r.HttpsProxy
+}
+
+// pluginProxyEnv returns the proxy settings passed to the plugin process, lowercase variables are set
+// as well since some clients prefer them over the uppercase ones inherited from the daemon
+func (r *LocalPluginRuntime) pluginProxyEnv() []string {
+	if r.EgressProxy == "" {
+		return r.proxyEnv()
+	}
+
+	return []string{
+		"HTTP_PROXY=" + r.EgressProxy,
+		"HTTPS_PROXY=" + r.EgressProxy,
+		"http_proxy=" + r.EgressProxy,
+		"https_proxy=" + r.EgressProxy,
+	}
+}
+
 // getCmd prepares the exec.Cmd for the plugin based on its language
 func (r *LocalPluginRuntime) getCmd() (*exec.Cmd, error) {
-	if r.Config.Meta.Runner.Language == constants.Python {
-		cmd := exec.Command(r.pythonInterpreterPath, "-m", r.Config.Meta.Runner.Entrypoint)
-		cmd.Dir = r.State.WorkingPath
-		cmd.Env = cmd.Environ()
-		if r.HttpsProxy != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("HTTPS_PROXY=%s", r.HttpsProxy))
-		}
-		if r.HttpProxy != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("HTTP_PROXY=%s", r.HttpProxy))
-		}
-		return cmd, nil
+	var cmd *exec.Cmd
+	switch r.Config.Meta.Runner.Language {
+	case constants.Python:
+		cmd = exec.Command(r.pythonInterpreterPath, "-m", r.Config.Meta.Runner.Entrypoint)
+	case constants.Go:
+		cmd = exec.Command(r.goBinaryPath)
+	case constants.NodeJS:
+		cmd = exec.Command(r.nodeInterpreterPath, r.Config.Meta.Runner.Entrypoint)
+	default:
+		return nil, fmt.Errorf("unsupported language: %s", r.Config.Meta.Runner.Language)
 	}
 
-	return nil, fmt.Errorf("unsupported language: %s", r.Config.Meta.Runner.Language)
+	cmd.Dir = r.State.WorkingPath
+	cmd.Env = append(cmd.Environ(), r.pluginProxyEnv()...)
+	return cmd, nil
 }
 
-// StartPlugin starts the plugin and manages its lifecycle
+// StartPlugin starts the workers of the plugin and supervises them until the plugin is stopped
+// or all workers exited
 func (r *LocalPluginRuntime) StartPlugin() error {
 	defer log.Info("plugin %s stopped", r.Config.Identity())
 	defer func() {
@@ -72,17 +108,171 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 
 	// reset wait chan
 	r.waitChan = make(chan bool)
-	// reset wait launched chan
+	defer r.gc()
+
+	// workers share the memory declared in manifest, released once all of them exited
+	r.memoryLimiter = r.newMemoryLimiter()
+	if r.memoryLimiter != nil {
+		defer r.memoryLimiter.Release()
+	}
+
+	exited := make(chan error)
+	running := 0
+	spawn := func() {
+		worker := r.workers.add()
+		running++
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "runWorker",
+		}, func() {
+			err := r.runWorker(worker)
+			r.workers.remove(worker)
+			exited <- err
+		})
+	}
+
+	// respawn crashed workers and follow the load
+	scale := func() {
+		spawns, retired := r.workers.autoscale(time.Now())
+		for i := 0; i < spawns; i++ {
+			spawn()
+		}
+		for _, worker := range retired {
+			log.Info("stopping idle worker %d of plugin %s", worker.id, r.Config.Identity())
+			worker.stdio.Stop()
+		}
+	}
+
+	r.workers.resume()
+	scale()
+
+	ticker := time.NewTicker(workerCheckInterval)
+	defer ticker.Stop()
+
+	var lastErr error
+	quarantined := false
+	// a suspended or quarantined plugin keeps supervising without any worker until it's stopped
+	for running > 0 || (!r.Stopped() && (r.workers.isSuspended() || quarantined)) {
+		select {
+		case err := <-exited:
+			running--
+			if err != nil {
+				lastErr = err
+				// the lifecycle takes over once all workers exited
+				if running > 0 && !quarantined && !r.Stopped() {
+					quarantined = r.workerCrashed(err)
+				}
+			}
+		case <-r.wakeChan:
+			if !quarantined {
+				scale()
+			}
+		case <-ticker.C:
+			if r.Stopped() {
+				continue
+			}
+
+			if quarantined {
+				if r.Quarantined() {
+					continue
+				}
+				log.Info("plugin %s released from quarantine, starting workers", r.Config.Identity())
+				r.crashLoop.Reset()
+				quarantined = false
+				scale()
+				continue
+			}
+
+			if retired, ok := r.workers.suspend(time.Now()); ok {
+				log.Info("plugin %s is idle, stopping all workers until it's requested", r.Config.Identity())
+				r.SetIdle()
+				for _, worker := range retired {
+					worker.stdio.Stop()
+				}
+				continue
+			}
+
+			scale()
+		}
+	}
 
-	// start plugin
+	return lastErr
+}
+
+// SetCrashLoopTracker shares the crash loop tracker of the lifecycle with the workers
+func (r *LocalPluginRuntime) SetCrashLoopTracker(tracker CrashLoopTracker) {
+	r.crashLoop = tracker
+}
+
+// workerCrashed backs off or quarantines the plugin after a worker crashed while others keep running,
+// returns true if the plugin is quarantined
+func (r *LocalPluginRuntime) workerCrashed(err error) bool {
+	r.AddExitError(err.Error())
+	if r.crashLoop == nil {
+		return false
+	}
+
+	delay, quarantine := r.crashLoop.Crashed(time.Now())
+	if quarantine {
+		log.Error("workers of plugin %s crashed repeatedly, quarantined until released", r.Config.Identity())
+		r.SetQuarantined()
+		for _, worker := range r.workers.retireAll() {
+			if worker.stdio != nil {
+				worker.stdio.Stop()
+			}
+		}
+		return true
+	}
+
+	log.Info("restarting crashed worker of plugin %s in %s", r.Config.Identity(), delay)
+	r.workers.backoff(time.Now().Add(delay))
+	r.AddRestarts()
+	return false
+}
+
+// pluginEnv returns the environment variables set by the daemon for local plugins,
+// the wire protocol version tells the plugin it may send frames
+func pluginEnv() []string {
+	return []string{
+		"INSTALL_METHOD=local",
+		"PATH=" + os.Getenv("PATH"),
+		fmt.Sprintf("WIRE_PROTOCOL_VERSION=%d", wire_protocol.PROTOCOL_VERSION_LATEST),
+	}
+}
+
+// runWorker starts a process of the plugin and blocks until it exits, returns why the process exited
+func (r *LocalPluginRuntime) runWorker(worker *pluginWorker) (exitErr error) {
 	e, err := r.getCmd()
 	if err != nil {
 		return err
 	}
 
 	e.Dir = r.State.WorkingPath
-	// add env INSTALL_METHOD=local
-	e.Env = append(e.Environ(), "INSTALL_METHOD=local", "PATH="+os.Getenv("PATH"))
+	e.Env = append(e.Environ(), pluginEnv()...)
+
+	// isolate the plugin from the host if required
+	sandbox, err := r.newSandbox()
+	if err != nil {
+		return err
+	}
+	if sandbox != nil {
+		if err := sandbox.Wrap(e); err != nil {
+			sandbox.Release()
+			return fmt.Errorf("sandbox plugin failed: %s", err.Error())
+		}
+		// release after the plugin process has been waited
+		defer sandbox.Release()
+	}
+
+	// place the process under the memory limit before it executes, inside the sandbox the pid
+	// of the command is the sandbox itself, the plugin forked by it inherits the limit
+	limiter := r.memoryLimiter
+	if limiter != nil {
+		if err := limiter.Prepare(e); err != nil {
+			return fmt.Errorf("limit memory of plugin failed: %s", err.Error())
+		}
+	}
 
 	// get writer
 	stdin, err := e.StdinPipe()
@@ -109,12 +299,22 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 		return fmt.Errorf("start plugin failed: %s", err.Error())
 	}
 
+	if limiter != nil {
+		if err := limiter.Attach(e.Process.Pid); err != nil {
+			log.Warn("failed to watch memory of plugin %s: %s", r.Config.Identity(), err.Error())
+		}
+	}
+
 	var stdio *stdioHolder
 
 	defer func() {
 		// wait for plugin to exit
 		originalErr := e.Wait()
-		if originalErr != nil {
+		exceeded := limiter != nil && limiter.Exited(e.Process.Pid, e.ProcessState)
+		if r.workers.isRetiring(worker) {
+			// stopped by autoscaling
+			exitErr = nil
+		} else if originalErr != nil {
 			// get stdio
 			var err error
 			if stdio != nil {
@@ -127,26 +327,43 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 			} else {
 				err = originalErr
 			}
+			if exceeded {
+				err = errors.Join(plugin_errors.ErrPluginMemoryExceeded, err)
+				r.Error(fmt.Sprintf(
+					"%s, limit is %d bytes, plugin will be restarted",
+					plugin_errors.ErrPluginMemoryExceeded.Error(), r.Config.Resource.Memory,
+				))
+			}
 			if err != nil {
-				log.Error("plugin %s exited with error: %s", r.Config.Identity(), err.Error())
+				log.Error("plugin %s worker %d exited with error: %s", r.Config.Identity(), worker.id, err.Error())
 			} else {
-				log.Error("plugin %s exited with unknown error", r.Config.Identity())
+				log.Error("plugin %s worker %d exited with unknown error", r.Config.Identity(), worker.id)
 			}
+
+			// the exit status and the stderr tell more than the stdio errors
+			exitErr = err
 		}
 
-		r.gc()
+		if stdio != nil {
+			removeStdioHandler(stdio.GetID())
+		}
 	}()
 
 	// ensure the plugin process is killed after the plugin exits
 	defer e.Process.Kill()
 
-	log.Info("plugin %s started", r.Config.Identity())
+	log.Info("plugin %s worker %d started", r.Config.Identity(), worker.id)
 
 	// setup stdio
 	stdio = registerStdioHandler(r.Config.Identity(), stdin, stdout, stderr)
-	r.ioIdentity = stdio.GetID()
 	defer stdio.Stop()
 
+	// the runtime may have been stopped or quarantined while the process was starting
+	if r.Stopped() || r.workers.isRetiring(worker) {
+		return nil
+	}
+	r.workers.started(worker, stdio)
+
 	wg := sync.WaitGroup{}
 	wg.Add(2)
 
@@ -191,6 +408,34 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	return nil
 }
 
+// Wake starts workers of a plugin suspended for idling, returns false if it's not suspended
+func (r *LocalPluginRuntime) Wake() bool {
+	if !r.workers.resume() {
+		return false
+	}
+
+	r.SetLaunching()
+	select {
+	case r.wakeChan <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// ColdStarting returns true if the plugin is suspended or waking up from suspension
+func (r *LocalPluginRuntime) ColdStarting() bool {
+	return r.workers.coldStarting()
+}
+
+// WireProtocolVersion returns the wire protocol version spoken by the worker owning the session
+func (r *LocalPluginRuntime) WireProtocolVersion(session_id string) int {
+	worker := r.workers.lookup(session_id)
+	if worker == nil {
+		return wire_protocol.PROTOCOL_VERSION_JSON_LINE
+	}
+	return worker.stdio.protocolVersion()
+}
+
 // Wait returns a channel that will be closed when the plugin stops
 func (r *LocalPluginRuntime) Wait() (<-chan bool, error) {
 	if r.waitChan == nil {
@@ -222,9 +467,8 @@ func (r *LocalPluginRuntime) Stop() {
 	// inherit from PluginRuntime
 	r.PluginRuntime.Stop()
 
-	// get stdio
-	stdio := getStdioHandler(r.ioIdentity)
-	if stdio != nil {
+	// stop all workers
+	for _, stdio := range r.workers.stdios() {
 		stdio.Stop()
 	}
 }
@@ -0,0 +1,99 @@
+package local_runtime
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// no isolation, plugins run as the daemon user
+	SANDBOX_NONE = "none"
+	// bubblewrap, only system libraries, the interpreter and the working path are visible, all namespaces unshared
+	SANDBOX_BWRAP = "bwrap"
+
+	// no network at all
+	SANDBOX_NETWORK_NONE = "none"
+	// network is only reachable through the configured http(s) proxy
+	SANDBOX_NETWORK_PROXY = "proxy"
+	// network of the host is shared, not isolated
+	SANDBOX_NETWORK_HOST = "host"
+)
+
+// environment variables set by the daemon itself, always passed into the sandbox
+var sandboxRequiredEnv = []string{"INSTALL_METHOD", "WIRE_PROTOCOL_VERSION"}
+
+type SandboxConfig struct {
+	// none or bwrap
+	Mode string
+	// only sandbox plugins without a verified signature
+	UnverifiedOnly bool
+	// path of the bwrap binary
+	BwrapPath string
+	// path of socat used to expose the proxy inside the sandbox
+	SocatPath string
+	// none, proxy or host
+	Network string
+	// compiled seccomp bpf program passed to bwrap, no seccomp filter if empty
+	SeccompProfile string
+	// names of environment variables passed into the sandbox
+	EnvAllowlist []string
+}
+
+// sandbox isolates a plugin process from the host
+type sandbox interface {
+	// Wrap rewrites the command to run inside the sandbox, it should be called right before the command starts
+	Wrap(cmd *exec.Cmd) error
+	// Release releases resources held by the sandbox, it should be called after the process exited
+	Release()
+}
+
+// newSandbox returns the sandbox for the plugin, nil if the plugin runs without isolation
+func (r *LocalPluginRuntime) newSandbox() (sandbox, error) {
+	config := r.sandboxConfig
+	if config.Mode == "" || config.Mode == SANDBOX_NONE {
+		return nil, nil
+	}
+
+	if config.UnverifiedOnly && r.State.Verified {
+		return nil, nil
+	}
+
+	switch config.Mode {
+	case SANDBOX_BWRAP:
+		httpProxy, httpsProxy := r.pluginProxies()
+		return newPlatformBwrapSandbox(config, r.State.WorkingPath, httpProxy, httpsProxy)
+	}
+
+	return nil, fmt.Errorf("unknown sandbox: %s", config.Mode)
+}
+
+// filterEnv keeps the allowed variables of env, the later one wins if a variable is set more than once
+func filterEnv(env []string, allowlist []string) []string {
+	allowed := map[string]bool{}
+	for _, name := range allowlist {
+		allowed[strings.TrimSpace(name)] = true
+	}
+	for _, name := range sandboxRequiredEnv {
+		allowed[name] = true
+	}
+
+	values := map[string]string{}
+	order := []string{}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !allowed[name] {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			order = append(order, name)
+		}
+		values[name] = kv
+	}
+
+	result := make([]string, 0, len(order))
+	for _, name := range order {
+		result = append(result, values[name])
+	}
+	return result
+}
@@ -0,0 +1,371 @@
+//go:build linux
+
+package local_runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	// where proxy sockets are mounted inside the sandbox
+	SANDBOX_PROXY_SOCKET_DIR = "/run/dify"
+	// ports the proxies listen on inside the sandbox, forwarded to the configured proxies
+	SANDBOX_HTTP_PROXY_PORT  = 3128
+	SANDBOX_HTTPS_PROXY_PORT = 3129
+)
+
+// system paths mounted read-only into the sandbox, the rest of the host filesystem is invisible,
+// configurations of /etc are limited to what name resolution, tls and dynamic linking require
+var sandboxSystemPaths = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64",
+	"/etc/alternatives", "/etc/ssl", "/etc/pki", "/etc/ca-certificates",
+	"/etc/ld.so.cache", "/etc/ld.so.conf", "/etc/ld.so.conf.d",
+	"/etc/resolv.conf", "/etc/hosts", "/etc/nsswitch.conf", "/etc/localtime",
+}
+
+// bwrapSandbox runs the plugin with bubblewrap, only system libraries, the interpreter and the working path
+// are mounted, only the working path and /tmp are writable, all namespaces are unshared,
+// network is unshared too and proxies are exposed on the loopback of the sandbox through unix sockets
+type bwrapSandbox struct {
+	config      SandboxConfig
+	workingPath string
+	httpProxy   string
+	httpsProxy  string
+
+	socketDir string
+	bridges   []*proxyBridge
+	seccomp   *os.File
+}
+
+func newPlatformBwrapSandbox(config SandboxConfig, workingPath string, httpProxy string, httpsProxy string) (sandbox, error) {
+	bwrapPath, err := exec.LookPath(config.BwrapPath)
+	if err != nil {
+		// fail closed, a plugin required to be sandboxed never runs without it
+		return nil, fmt.Errorf("bwrap is required to sandbox the plugin but not found: %s", err)
+	}
+	config.BwrapPath = bwrapPath
+
+	workingPath, err = filepath.Abs(workingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Network == SANDBOX_NETWORK_PROXY && httpProxy == "" && httpsProxy == "" {
+		log.Warn("sandbox network is proxy but no proxy configured, plugins have no network access")
+	}
+
+	return &bwrapSandbox{
+		config:      config,
+		workingPath: workingPath,
+		httpProxy:   httpProxy,
+		httpsProxy:  httpsProxy,
+	}, nil
+}
+
+func (s *bwrapSandbox) Wrap(cmd *exec.Cmd) error {
+	env := filterEnv(cmd.Env, s.config.EnvAllowlist)
+	env = append(env, "HOME=/tmp")
+
+	// the interpreter and the environments linked into the working path, e.g. a shared python venv
+	readOnly := append(runtimePaths(cmd.Path), linkedPaths(s.workingPath)...)
+
+	args := []string{}
+	if s.config.SeccompProfile != "" {
+		seccomp, err := os.Open(s.config.SeccompProfile)
+		if err != nil {
+			return fmt.Errorf("failed to open seccomp profile: %s", err)
+		}
+		s.seccomp = seccomp
+		// extra files start from fd 3
+		cmd.ExtraFiles = append(cmd.ExtraFiles, seccomp)
+		args = append(args, "--seccomp", strconv.Itoa(2+len(cmd.ExtraFiles)))
+	}
+
+	// the sandbox does not search PATH of the daemon, use the resolved path
+	command := append([]string{}, cmd.Args...)
+	if cmd.Path != "" {
+		command[0] = cmd.Path
+	}
+
+	if s.config.Network == SANDBOX_NETWORK_PROXY {
+		proxies := map[int]string{}
+		if s.httpProxy != "" {
+			proxies[SANDBOX_HTTP_PROXY_PORT] = s.httpProxy
+			env = append(env, sandboxProxyEnv("http", SANDBOX_HTTP_PROXY_PORT, s.httpProxy)...)
+		}
+		if s.httpsProxy != "" {
+			proxies[SANDBOX_HTTPS_PROXY_PORT] = s.httpsProxy
+			env = append(env, sandboxProxyEnv("https", SANDBOX_HTTPS_PROXY_PORT, s.httpsProxy)...)
+		}
+
+		if len(proxies) > 0 {
+			socatPath, err := exec.LookPath(s.config.SocatPath)
+			if err != nil {
+				return fmt.Errorf("socat is required to expose the proxy inside the sandbox but not found: %s", err)
+			}
+
+			// unix socket paths are limited to 108 bytes, working path may be too long
+			s.socketDir, err = os.MkdirTemp("", "dify-sandbox-")
+			if err != nil {
+				return err
+			}
+			args = append(args, "--bind", s.socketDir, SANDBOX_PROXY_SOCKET_DIR)
+
+			ports := []int{}
+			for port, proxy := range proxies {
+				bridge, err := startProxyBridge(path.Join(s.socketDir, strconv.Itoa(port)+".sock"), proxy)
+				if err != nil {
+					return err
+				}
+				s.bridges = append(s.bridges, bridge)
+				ports = append(ports, port)
+			}
+
+			command = append([]string{"/bin/sh", "-c", sandboxProxyScript(socatPath, ports), "plugin"}, command...)
+			readOnly = append(readOnly, runtimePaths(socatPath)...)
+		}
+	}
+
+	args = append(bwrapArgs(s.workingPath, s.config.Network, readOnly), args...)
+
+	cmd.Path = s.config.BwrapPath
+	cmd.Args = append(append([]string{s.config.BwrapPath}, args...), append([]string{"--"}, command...)...)
+	cmd.Env = env
+	return nil
+}
+
+func (s *bwrapSandbox) Release() {
+	for _, bridge := range s.bridges {
+		bridge.Close()
+	}
+	s.bridges = nil
+
+	if s.socketDir != "" {
+		os.RemoveAll(s.socketDir)
+		s.socketDir = ""
+	}
+
+	if s.seccomp != nil {
+		s.seccomp.Close()
+		s.seccomp = nil
+	}
+}
+
+// bwrapArgs mounts the system paths and readOnly read-only and the working path writable,
+// nothing else of the host is visible to the plugin
+func bwrapArgs(workingPath string, network string, readOnly []string) []string {
+	args := []string{}
+	for _, p := range sandboxSystemPaths {
+		// merged /usr systems link /bin, /lib and so on into /usr
+		if target, err := os.Readlink(p); err == nil {
+			args = append(args, "--symlink", target, p)
+		} else {
+			args = append(args, "--ro-bind-try", p, p)
+		}
+	}
+	mounted := map[string]bool{}
+	for _, p := range readOnly {
+		// the working path is mounted writable below, never expose anything above it
+		if mounted[p] || p == workingPath || strings.HasPrefix(p, workingPath+"/") {
+			continue
+		}
+		if strings.HasPrefix(workingPath, p+"/") {
+			log.Warn("%s contains the working path %s, not mounted into the sandbox", p, workingPath)
+			continue
+		}
+		mounted[p] = true
+		args = append(args, "--ro-bind", p, p)
+	}
+
+	args = append(args,
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--tmpfs", "/run",
+		"--bind", workingPath, workingPath,
+		"--chdir", workingPath,
+	)
+	args = append(args,
+		"--unshare-user",
+		"--unshare-ipc",
+		"--unshare-pid",
+		"--unshare-uts",
+		"--unshare-cgroup-try",
+		"--die-with-parent",
+		"--new-session",
+	)
+
+	if network != SANDBOX_NETWORK_HOST {
+		args = append(args, "--unshare-net")
+	}
+
+	return args
+}
+
+// runtimePaths returns the installation of an executable outside the system paths,
+// e.g. /opt/node for /opt/node/bin/node, the executable and its libraries are mounted read-only
+func runtimePaths(executable string) []string {
+	if executable == "" || !filepath.IsAbs(executable) {
+		return nil
+	}
+	resolved, err := filepath.EvalSymlinks(executable)
+	if err != nil || underSystemPaths(resolved) {
+		return nil
+	}
+
+	// <prefix>/bin/<executable>
+	dir := filepath.Dir(resolved)
+	if filepath.Base(dir) == "bin" {
+		dir = filepath.Dir(dir)
+	}
+	if dir == "/" {
+		return []string{resolved}
+	}
+	return []string{dir}
+}
+
+// linkedPaths returns targets of top-level symlinks in the working path pointing outside of it,
+// they are environments shared between plugins, e.g. .venv linked into the python env cache
+func linkedPaths(workingPath string) []string {
+	entries, err := os.ReadDir(workingPath)
+	if err != nil {
+		return nil
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := filepath.EvalSymlinks(filepath.Join(workingPath, entry.Name()))
+		if err != nil || target == workingPath || strings.HasPrefix(target, workingPath+"/") {
+			continue
+		}
+		paths = append(paths, target)
+		// a virtual environment links its interpreter to the base installation
+		paths = append(paths, runtimePaths(filepath.Join(target, "bin", "python"))...)
+	}
+	return paths
+}
+
+func underSystemPaths(p string) bool {
+	for _, system := range sandboxSystemPaths {
+		if p == system || strings.HasPrefix(p, system+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxProxyEnv points the plugin to the forwarded port, credentials of the original proxy are kept
+func sandboxProxyEnv(scheme string, port int, original string) []string {
+	u := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+	if parsed, err := url.Parse(original); err == nil {
+		u.User = parsed.User
+	}
+	proxy := u.String()
+	if scheme == "https" {
+		return []string{"HTTPS_PROXY=" + proxy, "https_proxy=" + proxy}
+	}
+	return []string{"HTTP_PROXY=" + proxy, "http_proxy=" + proxy}
+}
+
+// sandboxProxyScript forwards the loopback ports to the proxy sockets,
+// it waits until the ports are listening before executing the plugin
+func sandboxProxyScript(socatPath string, ports []int) string {
+	script := ""
+	for _, port := range ports {
+		script += fmt.Sprintf(
+			"%s TCP-LISTEN:%d,bind=127.0.0.1,fork,reuseaddr UNIX-CONNECT:%s/%d.sock &\n",
+			socatPath, port, SANDBOX_PROXY_SOCKET_DIR, port,
+		)
+	}
+	for _, port := range ports {
+		script += fmt.Sprintf(
+			"i=0; while ! grep -q ':%04X ' /proc/net/tcp && [ $i -lt 100 ]; do sleep 0.02; i=$((i+1)); done\n",
+			port,
+		)
+	}
+	return script + `exec "$@"`
+}
+
+// proxyBridge accepts connections from the sandbox on a unix socket and forwards them to the proxy
+type proxyBridge struct {
+	listener net.Listener
+	target   string
+	once     sync.Once
+}
+
+func startProxyBridge(socketPath string, proxy string) (*proxyBridge, error) {
+	target := proxy
+	if u, err := url.Parse(proxy); err == nil && u.Host != "" {
+		target = u.Host
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on proxy socket: %s", err)
+	}
+
+	bridge := &proxyBridge{listener: listener, target: target}
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"type":     "local",
+		"function": "proxyBridge",
+	}, bridge.serve)
+
+	return bridge, nil
+}
+
+func (b *proxyBridge) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Error("proxy bridge stopped: %s", err.Error())
+			}
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			upstream, err := net.Dial("tcp", b.target)
+			if err != nil {
+				log.Warn("failed to connect to proxy %s: %s", b.target, err.Error())
+				return
+			}
+			defer upstream.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(upstream, conn)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+func (b *proxyBridge) Close() {
+	b.once.Do(func() {
+		b.listener.Close()
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package local_runtime
+
+import "fmt"
+
+func newPlatformBwrapSandbox(config SandboxConfig, workingPath string, httpProxy string, httpsProxy string) (sandbox, error) {
+	// fail closed, a plugin required to be sandboxed never runs without it
+	return nil, fmt.Errorf("sandbox %s is only supported on linux", config.Mode)
+}
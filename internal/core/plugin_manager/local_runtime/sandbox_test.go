@@ -0,0 +1,49 @@
+package local_runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"LANG=C",
+		"INSTALL_METHOD=local",
+		"PATH=/opt/bin:/usr/bin",
+	}
+
+	filtered := filterEnv(env, []string{"PATH", " LANG"})
+	expected := []string{"PATH=/opt/bin:/usr/bin", "LANG=C", "INSTALL_METHOD=local"}
+	if !reflect.DeepEqual(filtered, expected) {
+		t.Fatalf("expected %v, got %v", expected, filtered)
+	}
+}
+
+func TestNewSandboxSelection(t *testing.T) {
+	runtime := NewLocalPluginRuntime(LocalPluginRuntimeConfig{
+		Sandbox: SandboxConfig{Mode: SANDBOX_NONE},
+	})
+	if sandbox, err := runtime.newSandbox(); err != nil || sandbox != nil {
+		t.Fatalf("no sandbox expected when disabled, got %v, %v", sandbox, err)
+	}
+
+	runtime.sandboxConfig = SandboxConfig{Mode: SANDBOX_BWRAP, UnverifiedOnly: true}
+	runtime.State.Verified = true
+	if sandbox, err := runtime.newSandbox(); err != nil || sandbox != nil {
+		t.Fatalf("verified plugin should not be sandboxed, got %v, %v", sandbox, err)
+	}
+
+	// the sandbox fails closed when bwrap is not available
+	runtime.State.Verified = false
+	runtime.sandboxConfig.BwrapPath = "/nonexistent/bwrap"
+	if _, err := runtime.newSandbox(); err == nil {
+		t.Fatal("unverified plugin should not run without the sandbox")
+	}
+
+	runtime.sandboxConfig.Mode = "unknown"
+	if _, err := runtime.newSandbox(); err == nil {
+		t.Fatal("unknown sandbox should be rejected")
+	}
+}
@@ -1,14 +1,17 @@
 package local_runtime
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
@@ -34,6 +37,9 @@ type stdioHolder struct {
 	errMessage              string
 	lastErrMessageUpdatedAt time.Time
 
+	// captures stderr lines into the plugin's log buffer
+	stderrLines *plugin_logs.LineWriter
+
 	// waiting controller channel to notify the exit signal to the Wait() function
 	waitingControllerChan       chan bool
 	waitingControllerChanClosed bool
@@ -41,6 +47,17 @@ type stdioHolder struct {
 
 	// the last time the plugin sent a heartbeat
 	lastActiveAt time.Time
+
+	// the plugin sent a frame, it speaks the framed protocol
+	framed atomic.Bool
+}
+
+// protocolVersion returns the wire protocol version to write to the plugin
+func (s *stdioHolder) protocolVersion() int {
+	if s.framed.Load() {
+		return wire_protocol.PROTOCOL_VERSION_FRAMED
+	}
+	return wire_protocol.PROTOCOL_VERSION_JSON_LINE
 }
 
 func (s *stdioHolder) Error() error {
@@ -78,63 +95,97 @@ func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 	s.lastActiveAt = time.Now()
 	defer s.Stop()
 
-	scanner := bufio.NewScanner(s.reader)
+	decoder := &wire_protocol.Decoder{}
+	logger := log.With(log.FIELD_PLUGIN, s.pluginUniqueIdentifier)
 
-	// TODO: set a reasonable buffer size or use a reader, this is a temporary solution
-	scanner.Buffer(make([]byte, 1024), 5*1024*1024)
+	buf := make([]byte, 64*1024)
+	for {
+		n, readErr := s.reader.Read(buf)
+		if n > 0 {
+			messages, err := decoder.Feed(buf[:n])
+			for _, data := range messages {
+				if len(data) == 0 {
+					continue
+				}
 
-	for scanner.Scan() {
-		data := scanner.Bytes()
+				// update the last active time on each time the plugin sends data
+				s.lastActiveAt = time.Now()
 
-		if len(data) == 0 {
-			continue
-		}
+				s.handleMessage(data, logger, notify_heartbeat)
+			}
 
-		// update the last active time on each time the plugin sends data
-		s.lastActiveAt = time.Now()
+			// the plugin sends frames only if it speaks version 2, requests to it are framed from now on
+			if decoder.Framed() {
+				s.framed.Store(true)
+			}
 
-		plugin_entities.ParsePluginUniversalEvent(
-			data,
-			"",
-			func(session_id string, data []byte) {
-				for _, listener := range listeners {
-					listener(s.id, data)
-				}
-				// FIX: avoid deadlock to plugin invoke
-				s.l.Lock()
-				tasks := []func(){}
-				for listener_session_id, listener := range s.listener {
-					// copy the listener to avoid reference issue
-					listener := listener
-					if listener_session_id == session_id {
-						tasks = append(tasks, func() {
-							listener(data)
-						})
-					}
-				}
-				s.l.Unlock()
-				for _, t := range tasks {
-					t()
-				}
-			},
-			func() {
-				// notify launched
-				notify_heartbeat()
-			},
-			func(err string) {
-				log.Error("plugin %s: %s", s.pluginUniqueIdentifier, err)
-			},
-			func(message string) {
-				log.Info("plugin %s: %s", s.pluginUniqueIdentifier, message)
-			},
-		)
-	}
+			if errors.Is(err, wire_protocol.ErrMessageTooLarge) {
+				log.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
+				return
+			} else if err != nil {
+				logger.Error("plugin %s: %s", s.pluginUniqueIdentifier, err.Error())
+			}
+		}
 
-	if err := scanner.Err(); err != nil {
-		log.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
+		if readErr != nil {
+			if readErr != io.EOF && !errors.Is(readErr, os.ErrClosed) {
+				log.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, readErr)
+			}
+			return
+		}
 	}
 }
 
+// handleMessage parses a message from stdout and triggers corresponding listeners
+func (s *stdioHolder) handleMessage(data []byte, logger *log.Logger, notify_heartbeat func()) {
+	plugin_entities.ParsePluginUniversalEvent(
+		data,
+		"",
+		func(session_id string, data []byte) {
+			for _, listener := range listeners {
+				listener(s.id, data)
+			}
+			// FIX: avoid deadlock to plugin invoke
+			s.l.Lock()
+			tasks := []func(){}
+			for listener_session_id, listener := range s.listener {
+				// copy the listener to avoid reference issue
+				listener := listener
+				if listener_session_id == session_id {
+					tasks = append(tasks, func() {
+						listener(data)
+					})
+				}
+			}
+			s.l.Unlock()
+			for _, t := range tasks {
+				t()
+			}
+		},
+		func() {
+			// notify launched
+			notify_heartbeat()
+		},
+		func(err string) {
+			logger.Error("plugin %s: %s", s.pluginUniqueIdentifier, err)
+			plugin_logs.Append(s.pluginUniqueIdentifier, plugin_logs.Entry{
+				Stream:  plugin_logs.STREAM_STDOUT,
+				Level:   "error",
+				Message: err,
+			})
+		},
+		func(session_id string, event plugin_entities.PluginLogEvent) {
+			logger.With(log.FIELD_SESSION_ID, session_id).Info("plugin %s: %s", s.pluginUniqueIdentifier, event.Message)
+			plugin_logs.Append(s.pluginUniqueIdentifier, plugin_logs.Entry{
+				Stream:    plugin_logs.STREAM_STDOUT,
+				Level:     event.Level,
+				SessionID: session_id,
+				Message:   event.Message,
+			})
+		},
+	)
+}
+
 // WriteError writes the error message to the stdio holder
 // it will keep the last 1024 bytes of the error message
 func (s *stdioHolder) WriteError(msg string) {
@@ -155,6 +206,8 @@ func (s *stdioHolder) WriteError(msg string) {
 // StartStderr starts to read the stderr of the plugin
 // it will write the error message to the stdio holder
 func (s *stdioHolder) StartStderr() {
+	defer s.stderrLines.Flush()
+
 	for {
 		buf := make([]byte, 1024)
 		n, err := s.errReader.Read(buf)
@@ -162,11 +215,13 @@ func (s *stdioHolder) StartStderr() {
 			break
 		} else if err != nil {
 			s.WriteError(fmt.Sprintf("%s\n", buf[:n]))
+			s.stderrLines.Write(buf[:n])
 			break
 		}
 
 		if n > 0 {
 			s.WriteError(fmt.Sprintf("%s\n", buf[:n]))
+			s.stderrLines.Write(buf[:n])
 		}
 	}
 }
@@ -5,6 +5,8 @@ import (
 	"sync"
 
 	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
 )
 
 func registerStdioHandler(
@@ -18,6 +20,7 @@ func registerStdioHandler(
 		writer:                 writer,
 		reader:                 reader,
 		errReader:              err_reader,
+		stderrLines:            plugin_logs.NewLineWriter(pluginUniqueIdentifier, plugin_logs.STREAM_STDERR),
 		id:                     id,
 		l:                      &sync.Mutex{},
 
@@ -88,11 +91,16 @@ func OnGlobalEvent(listener func(string, []byte)) {
 	listeners[uuid.New().String()] = listener
 }
 
+// writeToStdioHandler writes the json message in the protocol the plugin speaks
 func writeToStdioHandler(id string, data []byte) error {
 	if v, ok := stdio_holder.Load(id); ok {
 		if holder, ok := v.(*stdioHolder); ok {
-			_, err := holder.writer.Write(data)
+			message, err := wire_protocol.Encode(holder.protocolVersion(), data)
+			if err != nil {
+				return err
+			}
 
+			_, err = holder.writer.Write(message)
 			return err
 		}
 	}
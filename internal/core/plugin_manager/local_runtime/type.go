@@ -11,8 +11,14 @@ type LocalPluginRuntime struct {
 	basic_runtime.BasicChecksum
 	plugin_entities.PluginRuntime
 
-	waitChan   chan bool
-	ioIdentity string
+	waitChan chan bool
+
+	// processes of the plugin, sessions are dispatched to them
+	workers *workerPool
+	// notifies the supervisor to start workers of a suspended plugin
+	wakeChan chan struct{}
+	// tells how crashed workers are restarted, nil respawns them on the next check
+	crashLoop CrashLoopTracker
 
 	// python interpreter path, currently only support python
 	pythonInterpreterPath string
@@ -20,10 +26,28 @@ type LocalPluginRuntime struct {
 	// python env init timeout
 	pythonEnvInitTimeout int
 
+	// shared virtual environments, nil if disabled
+	pythonEnvCache *PythonEnvCache
+
 	// to create a new python virtual environment, we need a default python interpreter
 	// by using its venv module
 	defaultPythonInterpreterPath string
 
+	// go binary to launch, either prebuilt in the package or built from source
+	goBinaryPath string
+	// go toolchain used to build the binary
+	goCompilerPath string
+	// go build timeout in seconds
+	goBuildTimeout int
+
+	// node executable used to install dependencies of and launch nodejs plugins
+	nodeExecutablePath string
+	// node executable resolved after the environment is ready
+	nodeInterpreterPath string
+	// nodejs dependencies install timeout in seconds
+	nodeEnvInitTimeout int
+	npmRegistryUrl     string
+
 	pipMirrorUrl    string
 	pipPreferBinary bool
 	pipVerbose      bool
@@ -33,6 +57,19 @@ type LocalPluginRuntime struct {
 	HttpProxy  string
 	HttpsProxy string
 
+	// url of the built-in egress proxy, replaces HttpProxy and HttpsProxy for the plugin process
+	// but not for installing dependencies
+	EgressProxy string
+
+	// enforce the memory declared in plugin manifest
+	memoryLimitEnabled bool
+	cgroupRoot         string
+	// limit shared by all workers, set while the plugin is started
+	memoryLimiter memoryLimiter
+
+	// isolate plugin processes from the host
+	sandboxConfig SandboxConfig
+
 	waitChanLock    sync.Mutex
 	waitStartedChan []chan bool
 	waitStoppedChan []chan bool
@@ -43,23 +80,44 @@ type LocalPluginRuntime struct {
 type LocalPluginRuntimeConfig struct {
 	PythonInterpreterPath string
 	PythonEnvInitTimeout  int
+	PythonEnvCache        *PythonEnvCache
+	GoCompilerPath        string
+	GoBuildTimeout        int
+	NodeExecutablePath    string
+	NodeEnvInitTimeout    int
+	NpmRegistryUrl        string
 	HttpProxy             string
 	HttpsProxy            string
 	PipMirrorUrl          string
 	PipPreferBinary       bool
 	PipVerbose            bool
 	PipExtraArgs          string
+	MemoryLimitEnabled    bool
+	CgroupRoot            string
+	Sandbox               SandboxConfig
+	Workers               WorkerPoolConfig
 }
 
 func NewLocalPluginRuntime(config LocalPluginRuntimeConfig) *LocalPluginRuntime {
 	return &LocalPluginRuntime{
 		defaultPythonInterpreterPath: config.PythonInterpreterPath,
 		pythonEnvInitTimeout:         config.PythonEnvInitTimeout,
+		pythonEnvCache:               config.PythonEnvCache,
+		goCompilerPath:               config.GoCompilerPath,
+		goBuildTimeout:               config.GoBuildTimeout,
+		nodeExecutablePath:           config.NodeExecutablePath,
+		nodeEnvInitTimeout:           config.NodeEnvInitTimeout,
+		npmRegistryUrl:               config.NpmRegistryUrl,
 		HttpProxy:                    config.HttpProxy,
 		HttpsProxy:                   config.HttpsProxy,
 		pipMirrorUrl:                 config.PipMirrorUrl,
 		pipPreferBinary:              config.PipPreferBinary,
 		pipVerbose:                   config.PipVerbose,
 		pipExtraArgs:                 config.PipExtraArgs,
+		memoryLimitEnabled:           config.MemoryLimitEnabled,
+		cgroupRoot:                   config.CgroupRoot,
+		sandboxConfig:                config.Sandbox,
+		workers:                      newWorkerPool(config.Workers),
+		wakeChan:                     make(chan struct{}, 1),
 	}
 }
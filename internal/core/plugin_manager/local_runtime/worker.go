@@ -0,0 +1,337 @@
+package local_runtime
+
+import (
+	"sync"
+	"time"
+)
+
+// how often workers are respawned and scaled
+var workerCheckInterval = time.Second
+
+// CrashLoopTracker is shared with the lifecycle of the plugin, so that crashed workers are
+// backed off and quarantined the same way as the whole plugin
+type CrashLoopTracker interface {
+	// Crashed records a crash, returns the delay before restarting and whether to quarantine
+	Crashed(now time.Time) (time.Duration, bool)
+	// Reset forgets all crashes once the plugin is released from quarantine
+	Reset()
+}
+
+type WorkerPoolConfig struct {
+	// workers always running, at least 1
+	Min int
+	// upper bound of workers when scaling up, no autoscaling if equals to Min
+	Max int
+	// in-flight sessions per worker which triggers scaling up
+	ScaleUpSessions int
+	// how long an extra worker stays idle before it's stopped
+	IdleTimeout time.Duration
+	// how long the plugin receives no session before all workers are stopped, 0 keeps them running,
+	// workers are started again on demand
+	SuspendAfter time.Duration
+}
+
+func (c WorkerPoolConfig) normalize() WorkerPoolConfig {
+	if c.Min < 1 {
+		c.Min = 1
+	}
+	if c.Max < c.Min {
+		c.Max = c.Min
+	}
+	if c.ScaleUpSessions < 1 {
+		c.ScaleUpSessions = 1
+	}
+	return c
+}
+
+// pluginWorker is a process of the plugin, all workers of a runtime share the working path
+type pluginWorker struct {
+	id int
+	// nil until the process started
+	stdio *stdioHolder
+	// in-flight sessions dispatched to the worker
+	sessions int
+	// retiring workers get no more sessions and are stopped
+	retiring  bool
+	idleSince time.Time
+}
+
+// workerPool dispatches sessions to workers, a session sticks to the worker it's assigned to
+// so that requests and backwards invocation responses of the session go to the same process
+type workerPool struct {
+	config WorkerPoolConfig
+
+	lock     sync.Mutex
+	workers  []*pluginWorker
+	sessions map[string]*pluginWorker
+	nextID   int
+
+	// all workers are stopped for idling
+	suspended bool
+	// woken up from suspension, no worker has started yet
+	waking bool
+	// last time a session was assigned or released
+	lastActiveAt time.Time
+	// no worker is started before it after a worker crashed
+	backoffUntil time.Time
+}
+
+func newWorkerPool(config WorkerPoolConfig) *workerPool {
+	return &workerPool{
+		config:       config.normalize(),
+		sessions:     map[string]*pluginWorker{},
+		lastActiveAt: time.Now(),
+	}
+}
+
+// add registers a new worker which is not yet started
+func (p *workerPool) add() *pluginWorker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.nextID++
+	worker := &pluginWorker{id: p.nextID, idleSince: time.Now()}
+	p.workers = append(p.workers, worker)
+	return worker
+}
+
+// started marks the worker ready to serve sessions
+func (p *workerPool) started(worker *pluginWorker, stdio *stdioHolder) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	worker.stdio = stdio
+	p.waking = false
+}
+
+// remove drops an exited worker, sessions on it are lost
+func (p *workerPool) remove(worker *pluginWorker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, w := range p.workers {
+		if w == worker {
+			p.workers = append(p.workers[:i], p.workers[i+1:]...)
+			break
+		}
+	}
+
+	for sessionID, w := range p.sessions {
+		if w == worker {
+			delete(p.sessions, sessionID)
+		}
+	}
+}
+
+// stdios returns the stdio of all started workers
+func (p *workerPool) stdios() []*stdioHolder {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stdios := []*stdioHolder{}
+	for _, worker := range p.workers {
+		if worker.stdio != nil {
+			stdios = append(stdios, worker.stdio)
+		}
+	}
+	return stdios
+}
+
+// isRetiring returns true if the worker is stopped by autoscaling
+func (p *workerPool) isRetiring(worker *pluginWorker) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return worker.retiring
+}
+
+// leastLoaded returns the ready worker with the fewest sessions, caller should hold the lock
+func (p *workerPool) leastLoaded() *pluginWorker {
+	var result *pluginWorker
+	for _, worker := range p.workers {
+		if worker.stdio == nil || worker.retiring {
+			continue
+		}
+		if result == nil || worker.sessions < result.sessions {
+			result = worker
+		}
+	}
+	return result
+}
+
+// assign binds the session to a worker, nil if no worker is ready
+func (p *workerPool) assign(sessionID string) *pluginWorker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if worker, ok := p.sessions[sessionID]; ok {
+		return worker
+	}
+
+	worker := p.leastLoaded()
+	if worker == nil {
+		return nil
+	}
+
+	worker.sessions++
+	p.sessions[sessionID] = worker
+	p.lastActiveAt = time.Now()
+	return worker
+}
+
+// lookup returns the worker owning the session, or the least loaded one for a session never assigned
+func (p *workerPool) lookup(sessionID string) *pluginWorker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if worker, ok := p.sessions[sessionID]; ok {
+		return worker
+	}
+	return p.leastLoaded()
+}
+
+// release unbinds the finished session
+func (p *workerPool) release(sessionID string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	worker, ok := p.sessions[sessionID]
+	if !ok {
+		return
+	}
+
+	delete(p.sessions, sessionID)
+	p.lastActiveAt = time.Now()
+	worker.sessions--
+	if worker.sessions == 0 {
+		worker.idleSince = time.Now()
+	}
+}
+
+// autoscale returns how many workers to start and the idle ones to stop,
+// workers not yet started count as capacity to avoid starting too many at once
+func (p *workerPool) autoscale(now time.Time) (int, []*pluginWorker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.suspended {
+		return 0, nil
+	}
+
+	active := 0
+	sessions := 0
+	for _, worker := range p.workers {
+		if worker.retiring {
+			continue
+		}
+		active++
+		sessions += worker.sessions
+	}
+
+	if !now.Before(p.backoffUntil) {
+		if active < p.config.Min {
+			return p.config.Min - active, nil
+		}
+
+		if active < p.config.Max && sessions >= active*p.config.ScaleUpSessions {
+			return 1, nil
+		}
+	}
+
+	// stop the newest idle workers first, the oldest ones are kept
+	retired := []*pluginWorker{}
+	for i := len(p.workers) - 1; i >= 0 && active > p.config.Min; i-- {
+		worker := p.workers[i]
+		if worker.retiring || worker.stdio == nil || worker.sessions > 0 {
+			continue
+		}
+		if now.Sub(worker.idleSince) < p.config.IdleTimeout {
+			continue
+		}
+
+		worker.retiring = true
+		retired = append(retired, worker)
+		active--
+	}
+
+	return 0, retired
+}
+
+// backoff delays starting workers until the given time, idle workers are still stopped
+func (p *workerPool) backoff(until time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.backoffUntil = until
+}
+
+// retireAll returns all workers to stop, used once the plugin is quarantined
+func (p *workerPool) retireAll() []*pluginWorker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	retired := []*pluginWorker{}
+	for _, worker := range p.workers {
+		if !worker.retiring {
+			worker.retiring = true
+			retired = append(retired, worker)
+		}
+	}
+	return retired
+}
+
+// suspend stops all workers once no session arrived for SuspendAfter, returns the workers to stop
+func (p *workerPool) suspend(now time.Time) ([]*pluginWorker, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.config.SuspendAfter <= 0 || p.suspended || len(p.sessions) > 0 {
+		return nil, false
+	}
+	if now.Sub(p.lastActiveAt) < p.config.SuspendAfter {
+		return nil, false
+	}
+
+	retired := []*pluginWorker{}
+	for _, worker := range p.workers {
+		// wait for starting workers
+		if worker.stdio == nil && !worker.retiring {
+			return nil, false
+		}
+		if !worker.retiring {
+			retired = append(retired, worker)
+		}
+	}
+
+	for _, worker := range retired {
+		worker.retiring = true
+	}
+	p.suspended = true
+	p.waking = false
+	return retired, true
+}
+
+// resume lets the pool start workers again, returns false if it's not suspended
+func (p *workerPool) resume() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.lastActiveAt = time.Now()
+	if !p.suspended {
+		return false
+	}
+
+	p.suspended = false
+	p.waking = true
+	return true
+}
+
+// coldStarting returns true if the pool is suspended or no worker has started since it's resumed
+func (p *workerPool) coldStarting() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.suspended || p.waking
+}
+
+func (p *workerPool) isSuspended() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.suspended
+}
@@ -0,0 +1,189 @@
+package local_runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
+)
+
+func startedWorker(pool *workerPool) *pluginWorker {
+	worker := pool.add()
+	pool.started(worker, &stdioHolder{})
+	return worker
+}
+
+func TestWorkerPoolAffinity(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{Min: 2, Max: 2})
+	startedWorker(pool)
+	startedWorker(pool)
+
+	a := pool.assign("a")
+	b := pool.assign("b")
+	if a == b {
+		t.Fatal("sessions should be spread over workers")
+	}
+
+	// responses of backwards invocations go to the worker owning the session
+	if pool.lookup("a") != a || pool.assign("a") != a {
+		t.Fatal("session should stick to its worker")
+	}
+	if a.sessions != 1 {
+		t.Fatalf("assigning a session twice should not count twice, got %d", a.sessions)
+	}
+
+	pool.release("a")
+	if a.sessions != 0 {
+		t.Fatalf("expected 0 sessions after released, got %d", a.sessions)
+	}
+
+	// sessions on an exited worker are dropped
+	pool.remove(b)
+	if _, ok := pool.sessions["b"]; ok {
+		t.Fatal("session of removed worker should be dropped")
+	}
+	if w := pool.assign("c"); w == nil || w == b {
+		t.Fatal("session should be assigned to a remaining worker")
+	}
+}
+
+func TestWorkerPoolNotReady(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{})
+	pool.add()
+
+	if pool.assign("a") != nil {
+		t.Fatal("no worker should be assigned before started")
+	}
+
+	// sessions fail fast instead of waiting for a worker until timeout
+	runtime := &LocalPluginRuntime{workers: pool}
+	if _, err := runtime.Listen("a"); !errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		t.Fatalf("expected ErrPluginUnavailable, got %v", err)
+	}
+}
+
+func TestWorkerPoolAutoscale(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{Min: 1, Max: 3, ScaleUpSessions: 2, IdleTimeout: time.Minute})
+
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 1 {
+		t.Fatalf("expected to start the min workers, got %d", spawns)
+	}
+
+	startedWorker(pool)
+	pool.assign("a")
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 0 {
+		t.Fatalf("expected no scaling below the threshold, got %d", spawns)
+	}
+
+	pool.assign("b")
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 1 {
+		t.Fatalf("expected to scale up, got %d", spawns)
+	}
+
+	// starting workers count as capacity
+	extra := pool.add()
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 0 {
+		t.Fatalf("expected no scaling while a worker is starting, got %d", spawns)
+	}
+	pool.started(extra, &stdioHolder{})
+
+	pool.release("a")
+	pool.release("b")
+	if _, retired := pool.autoscale(time.Now()); len(retired) != 0 {
+		t.Fatal("workers should not be stopped before the idle timeout")
+	}
+
+	_, retired := pool.autoscale(time.Now().Add(2 * time.Minute))
+	if len(retired) != 1 || retired[0] != extra {
+		t.Fatalf("expected the newest idle worker to be stopped, got %v", retired)
+	}
+
+	if pool.assign("c") == extra {
+		t.Fatal("retiring worker should get no sessions")
+	}
+}
+
+func TestWorkerPoolSuspend(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{Min: 1, Max: 2, SuspendAfter: time.Minute})
+	worker := startedWorker(pool)
+
+	if _, ok := pool.suspend(time.Now()); ok {
+		t.Fatal("pool should not be suspended before idling long enough")
+	}
+
+	pool.assign("a")
+	if _, ok := pool.suspend(time.Now().Add(2 * time.Minute)); ok {
+		t.Fatal("pool should not be suspended with sessions in flight")
+	}
+	pool.release("a")
+
+	retired, ok := pool.suspend(time.Now().Add(2 * time.Minute))
+	if !ok || len(retired) != 1 || retired[0] != worker {
+		t.Fatalf("expected all workers to be stopped, got %v", retired)
+	}
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 0 {
+		t.Fatalf("suspended pool should not start workers, got %d", spawns)
+	}
+	pool.remove(worker)
+
+	if !pool.resume() || !pool.coldStarting() {
+		t.Fatal("suspended pool should be waking up once resumed")
+	}
+	if spawns, _ := pool.autoscale(time.Now()); spawns != 1 {
+		t.Fatalf("expected the min workers to be started, got %d", spawns)
+	}
+
+	startedWorker(pool)
+	if pool.coldStarting() {
+		t.Fatal("pool should be ready once a worker started")
+	}
+}
+
+func TestWorkerPoolBackoff(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{Min: 2, Max: 2})
+	crashed := startedWorker(pool)
+	startedWorker(pool)
+
+	// a crashed worker is respawned only after the backoff
+	pool.remove(crashed)
+	now := time.Now()
+	pool.backoff(now.Add(time.Minute))
+	if spawns, _ := pool.autoscale(now); spawns != 0 {
+		t.Fatalf("expected no worker to be started during the backoff, got %d", spawns)
+	}
+	if spawns, _ := pool.autoscale(now.Add(2 * time.Minute)); spawns != 1 {
+		t.Fatalf("expected the crashed worker to be respawned, got %d", spawns)
+	}
+
+	// quarantined plugins stop all workers
+	retired := pool.retireAll()
+	if len(retired) != 1 || pool.assign("a") != nil {
+		t.Fatalf("expected all workers to be stopped, got %v", retired)
+	}
+}
+
+func TestWireProtocolVersionPerSession(t *testing.T) {
+	pool := newWorkerPool(WorkerPoolConfig{Min: 2, Max: 2})
+	framed := pool.add()
+	framedStdio := &stdioHolder{}
+	framedStdio.framed.Store(true)
+	pool.started(framed, framedStdio)
+	startedWorker(pool)
+
+	runtime := &LocalPluginRuntime{workers: pool}
+	a := pool.assign("a")
+	pool.assign("b")
+
+	// each session follows the worker owning it instead of the lowest version of the pool
+	for session, worker := range map[string]*pluginWorker{"a": a, "b": pool.lookup("b")} {
+		expected := wire_protocol.PROTOCOL_VERSION_JSON_LINE
+		if worker == framed {
+			expected = wire_protocol.PROTOCOL_VERSION_FRAMED
+		}
+		if version := runtime.WireProtocolVersion(session); version != expected {
+			t.Fatalf("session %s expected version %d, got %d", session, expected, version)
+		}
+	}
+}
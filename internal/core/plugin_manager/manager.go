@@ -4,11 +4,17 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/real"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/egress_proxy"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
@@ -47,6 +53,9 @@ type PluginManager struct {
 	// register plugin
 	pluginRegisters []func(lifetime plugin_entities.PluginLifetime) error
 
+	// notified once a plugin starts draining
+	pluginDrainHandlers []func(lifetime plugin_entities.PluginLifetime)
+
 	// localPluginLaunchingLock is a lock to launch local plugins
 	localPluginLaunchingLock *lock.GranularityLock
 
@@ -59,10 +68,35 @@ type PluginManager struct {
 	// python env init timeout
 	pythonEnvInitTimeout int
 
+	// shared python virtual environments, nil if disabled
+	pythonEnvCache        *local_runtime.PythonEnvCache
+	pythonEnvCacheGCGrace time.Duration
+
+	// go toolchain and build timeout for go plugins
+	goCompilerPath string
+	goBuildTimeout int
+
+	// node runtime settings for nodejs plugins
+	nodeExecutablePath string
+	nodeEnvInitTimeout int
+	npmRegistryUrl     string
+
 	// proxy settings
 	HttpProxy  string
 	HttpsProxy string
 
+	// how long a plugin being stopped waits for in-flight sessions
+	drainTimeout time.Duration
+
+	// restart backoff and quarantine of crashing plugins
+	crashLoopPolicy CrashLoopPolicy
+
+	// how long a request waits for an idle local plugin to start
+	coldStartTimeout time.Duration
+
+	// built-in forward proxy which enforces network permissions of local plugins
+	egressProxy *egress_proxy.Proxy
+
 	// pip mirror url
 	pipMirrorUrl string
 
@@ -75,12 +109,34 @@ type PluginManager struct {
 	// pip extra args
 	pipExtraArgs string
 
+	// isolate local plugin processes from the host
+	sandboxConfig local_runtime.SandboxConfig
+
+	// worker processes of each local plugin, overrides are keyed by plugin id
+	workerPoolConfig local_runtime.WorkerPoolConfig
+	workersOverride  map[string]app.PluginLocalWorkers
+
+	// enforce the memory declared in plugin manifest
+	memoryLimitEnabled bool
+
+	// where the cgroups of local plugins are created
+	cgroupRoot string
+
 	// remote plugin server
 	remotePluginServer debugging_runtime.RemotePluginServerInterface
 
 	// max launching lock to prevent too many plugins launching at the same time
 	maxLaunchingLock chan bool
 
+	// localPluginScheduler decides whether a local plugin should run on current node
+	localPluginScheduler func(identity plugin_entities.PluginUniqueIdentifier) bool
+
+	// localPluginPlacer places a newly installed local plugin, returns whether it should run on current node
+	localPluginPlacer func(identity plugin_entities.PluginUniqueIdentifier) (bool, error)
+
+	// localPluginSyncLock avoids syncing local plugins concurrently
+	localPluginSyncLock sync.Mutex
+
 	// platform, local or serverless
 	platform app.PlatformType
 }
@@ -111,6 +167,11 @@ func InitGlobalManager(oss oss.OSS, configuration *app.Config) *PluginManager {
 		maxLaunchingLock:         make(chan bool, 2), // by default, we allow 2 plugins launching at the same time
 		pythonInterpreterPath:    configuration.PythonInterpreterPath,
 		pythonEnvInitTimeout:     configuration.PythonEnvInitTimeout,
+		goCompilerPath:           configuration.GoCompilerPath,
+		goBuildTimeout:           configuration.GoBuildTimeout,
+		nodeExecutablePath:       configuration.NodeExecutablePath,
+		nodeEnvInitTimeout:       configuration.NodeEnvInitTimeout,
+		npmRegistryUrl:           configuration.NpmRegistryUrl,
 		platform:                 configuration.Platform,
 		HttpProxy:                configuration.HttpProxy,
 		HttpsProxy:               configuration.HttpsProxy,
@@ -118,6 +179,52 @@ func InitGlobalManager(oss oss.OSS, configuration *app.Config) *PluginManager {
 		pipPreferBinary:          *configuration.PipPreferBinary,
 		pipVerbose:               *configuration.PipVerbose,
 		pipExtraArgs:             configuration.PipExtraArgs,
+		memoryLimitEnabled:       *configuration.PluginMemoryLimitEnabled,
+		cgroupRoot:               configuration.PluginCgroupRoot,
+		pythonEnvCacheGCGrace:    time.Duration(configuration.PythonEnvCacheGCGrace) * time.Second,
+		drainTimeout:             time.Duration(configuration.PluginDrainTimeout) * time.Second,
+		crashLoopPolicy: CrashLoopPolicy{
+			BackoffBase: time.Duration(configuration.PluginRestartBackoffBase) * time.Second,
+			BackoffMax:  time.Duration(configuration.PluginRestartBackoffMax) * time.Second,
+			Window:      time.Duration(configuration.PluginCrashLoopWindow) * time.Second,
+		},
+		sandboxConfig: local_runtime.SandboxConfig{
+			Mode:           configuration.PluginSandbox,
+			UnverifiedOnly: *configuration.PluginSandboxUnverifiedOnly,
+			BwrapPath:      configuration.PluginSandboxBwrapPath,
+			SocatPath:      configuration.PluginSandboxSocatPath,
+			Network:        configuration.PluginSandboxNetwork,
+			SeccompProfile: configuration.PluginSandboxSeccompProfile,
+			EnvAllowlist:   strings.Split(configuration.PluginSandboxEnvAllowlist, ","),
+		},
+		workerPoolConfig: local_runtime.WorkerPoolConfig{
+			Min:             configuration.PluginLocalWorkers,
+			Max:             configuration.PluginLocalMaxWorkers,
+			ScaleUpSessions: configuration.PluginLocalWorkerScaleUpSessions,
+			IdleTimeout:     time.Duration(configuration.PluginLocalWorkerIdleTimeout) * time.Second,
+			SuspendAfter:    time.Duration(configuration.PluginLocalIdleTimeout) * time.Second,
+		},
+		coldStartTimeout: time.Duration(configuration.PluginLocalColdStartTimeout) * time.Second,
+	}
+
+	if *configuration.PluginCrashLoopQuarantineEnabled {
+		manager.crashLoopPolicy.Threshold = configuration.PluginCrashLoopThreshold
+	}
+
+	// validated already
+	manager.workersOverride, _ = configuration.PluginLocalWorkersOverrideMap()
+
+	if configuration.Platform == app.PLATFORM_LOCAL && *configuration.PluginEgressProxyEnabled {
+		manager.egressProxy = egress_proxy.NewProxy(configuration.PluginEgressProxyAddress)
+	}
+
+	if configuration.Platform == app.PLATFORM_LOCAL && *configuration.PythonEnvCacheEnabled {
+		pythonEnvCache, err := local_runtime.NewPythonEnvCache(configuration.PythonEnvCachePath)
+		if err != nil {
+			log.Error("init python env cache failed, environments will not be shared: %s", err.Error())
+		} else {
+			manager.pythonEnvCache = pythonEnvCache
+		}
 	}
 
 	return manager
@@ -133,6 +240,17 @@ func (p *PluginManager) Get(
 	if identity.RemoteLike() || p.platform == app.PLATFORM_LOCAL {
 		// check if it's a debugging plugin or a local plugin
 		if v, ok := p.m.Load(identity.String()); ok {
+			if fullDuplex, ok := v.(plugin_entities.PluginFullDuplexLifetime); ok {
+				// a draining plugin finishes in-flight sessions only
+				if fullDuplex.Draining() {
+					return nil, fmt.Errorf("%w: plugin is draining", plugin_errors.ErrPluginUnavailable)
+				}
+				if fullDuplex.Quarantined() {
+					return nil, fmt.Errorf(
+						"%w: plugin is quarantined after crashing repeatedly", plugin_errors.ErrPluginUnavailable,
+					)
+				}
+			}
 			return v, nil
 		}
 		return nil, errors.New("plugin not found")
@@ -147,6 +265,21 @@ func (p *PluginManager) Get(
 	}
 }
 
+// Running returns true if the plugin has a runtime on current node, even if it's draining or quarantined
+func (p *PluginManager) Running(identity plugin_entities.PluginUniqueIdentifier) bool {
+	return p.m.Exists(identity.String())
+}
+
+// Runtimes returns all plugin runtimes running on current node
+func (p *PluginManager) Runtimes() []plugin_entities.PluginLifetime {
+	runtimes := make([]plugin_entities.PluginLifetime, 0, p.m.Len())
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		runtimes = append(runtimes, value)
+		return true
+	})
+	return runtimes
+}
+
 func (p *PluginManager) GetAsset(id string) ([]byte, error) {
 	return p.mediaBucket.Get(id)
 }
@@ -171,6 +304,16 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 	}
 	p.backwardsInvocation = invocation
 
+	// start egress proxy before any plugin launches
+	if p.egressProxy != nil {
+		if err := p.egressProxy.Start(); err != nil {
+			log.Panic("%s", err.Error())
+		}
+	}
+
+	// release quarantined plugins on demand of any node
+	p.watchQuarantineReleases()
+
 	// start local watcher
 	if configuration.Platform == app.PLATFORM_LOCAL {
 		p.startLocalWatcher()
@@ -3,5 +3,8 @@ package plugin_errors
 import "errors"
 
 var (
-	ErrPluginNotActive = errors.New("plugin is not active, does not respond to heartbeat in 20 seconds")
+	ErrPluginNotActive      = errors.New("plugin is not active, does not respond to heartbeat in 20 seconds")
+	ErrPluginMemoryExceeded = errors.New("OOM: exceeded declared memory")
+	// the plugin is running on current node but does not accept new sessions
+	ErrPluginUnavailable = errors.New("plugin is unavailable")
 )
@@ -1,10 +1,12 @@
 package plugin_manager
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
@@ -13,6 +15,11 @@ func (p *PluginManager) AddPluginRegisterHandler(handler func(r plugin_entities.
 	p.pluginRegisters = append(p.pluginRegisters, handler)
 }
 
+// AddPluginDrainHandler adds a handler called once a plugin starts draining
+func (p *PluginManager) AddPluginDrainHandler(handler func(r plugin_entities.PluginLifetime)) {
+	p.pluginDrainHandlers = append(p.pluginDrainHandlers, handler)
+}
+
 func (p *PluginManager) fullDuplexLifecycle(
 	r plugin_entities.PluginFullDuplexLifetime,
 	launchedChan chan bool,
@@ -94,9 +101,17 @@ func (p *PluginManager) fullDuplexLifecycle(
 
 	// init environment successfully
 	// once succeed, we consider the plugin is installed successfully
+	crashLoop := newCrashLoopDetector(p.crashLoopPolicy)
+	// crashes of single workers count as well
+	if pool, ok := r.(interface {
+		SetCrashLoopTracker(local_runtime.CrashLoopTracker)
+	}); ok {
+		pool.SetCrashLoopTracker(crashLoop)
+	}
 	for !r.Stopped() {
 		// start plugin
-		if err := r.StartPlugin(); err != nil {
+		err := r.StartPlugin()
+		if err != nil {
 			if r.Stopped() {
 				// plugin has been stopped, exit
 				break
@@ -104,13 +119,34 @@ func (p *PluginManager) fullDuplexLifecycle(
 		}
 
 		// wait for plugin to stop normally
-		c, err := r.Wait()
-		if err == nil {
+		c, waitErr := r.Wait()
+		if waitErr == nil {
 			<-c
 		}
 
-		// restart plugin in 5s
-		time.Sleep(5 * time.Second)
+		if r.Stopped() {
+			break
+		}
+
+		// the plugin is not expected to exit by itself
+		if err == nil {
+			err = errors.New("plugin exited unexpectedly")
+		}
+		r.AddExitError(err.Error())
+
+		delay, quarantine := crashLoop.Crashed(time.Now())
+		if quarantine {
+			log.Error(
+				"plugin %s crashed %d times in %s, quarantined until released",
+				configuration.Identity(), p.crashLoopPolicy.Threshold, p.crashLoopPolicy.Window,
+			)
+			r.SetQuarantined()
+			waitReleased(r)
+			crashLoop.Reset()
+		} else {
+			log.Info("restarting plugin %s in %s", configuration.Identity(), delay)
+			time.Sleep(delay)
+		}
 
 		// add restart times
 		r.AddRestarts()
@@ -20,7 +20,7 @@ func Init(config *app.Config) {
 	var err error
 	baseurl, err = url.Parse(*config.DifyPluginServerlessConnectorURL)
 	if err != nil {
-		log.Panic("Failed to parse serverless connector url", err)
+		log.Panic("Failed to parse serverless connector url: %s", err)
 	}
 
 	client = &http.Client{
@@ -36,7 +36,7 @@ func Init(config *app.Config) {
 	SERVERLESS_CONNECTOR_API_KEY = *config.DifyPluginServerlessConnectorAPIKey
 
 	if err := Ping(); err != nil {
-		log.Panic("Failed to ping serverless connector", err)
+		log.Panic("Failed to ping serverless connector: %s", err)
 	}
 
 	log.Info("Serverless connector initialized")
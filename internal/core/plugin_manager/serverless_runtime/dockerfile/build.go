@@ -24,6 +24,10 @@ func GenerateDockerfile(configuration *plugin_entities.PluginDeclaration) (strin
 	switch configuration.Meta.Runner.Language {
 	case constants.Python:
 		return handleTemplate(configuration, pythonTemplates[configuration.Meta.Runner.Version])
+	case constants.Go:
+		return handleTemplate(configuration, goTemplates[configuration.Meta.Runner.Version])
+	case constants.NodeJS:
+		return handleTemplate(configuration, nodejsTemplates[configuration.Meta.Runner.Version])
 	}
 
 	return "", fmt.Errorf("unsupported language: %s", configuration.Meta.Runner.Language)
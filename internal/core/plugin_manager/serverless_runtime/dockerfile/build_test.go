@@ -44,3 +44,48 @@ func TestGenerateDockerfileWithInvalidPluginDeclaration(t *testing.T) {
 		t.Fatalf("Expected error, got nil")
 	}
 }
+
+func TestGenerateGoDockerfile(t *testing.T) {
+	pluginDeclaration := preparePluginDeclaration()
+	pluginDeclaration.Meta.Runner = plugin_entities.PluginRunner{
+		Language:   constants.Go,
+		Version:    "1.22",
+		Entrypoint: "main",
+	}
+
+	dockerfile, err := GenerateDockerfile(pluginDeclaration)
+	if err != nil {
+		t.Fatalf("Error generating Dockerfile: %v", err)
+	}
+
+	if !strings.Contains(dockerfile, "golang:1.22") || !strings.Contains(dockerfile, "bin/main-linux-amd64") {
+		t.Fatalf("unexpected Dockerfile: %s", dockerfile)
+	}
+
+	if strings.Contains(dockerfile, "{{") {
+		t.Fatalf("unreplaced placeholder in Dockerfile: %s", dockerfile)
+	}
+}
+
+func TestGenerateNodeJSDockerfile(t *testing.T) {
+	pluginDeclaration := preparePluginDeclaration()
+	pluginDeclaration.Meta.Runner = plugin_entities.PluginRunner{
+		Language:   constants.NodeJS,
+		Version:    "20",
+		Entrypoint: "dist/index.js",
+	}
+
+	dockerfile, err := GenerateDockerfile(pluginDeclaration)
+	if err != nil {
+		t.Fatalf("Error generating Dockerfile: %v", err)
+	}
+
+	if !strings.Contains(dockerfile, "node:20-slim") || !strings.Contains(dockerfile, `"dist/index.js"`) {
+		t.Fatalf("unexpected Dockerfile: %s", dockerfile)
+	}
+
+	pluginDeclaration.Meta.Runner.Version = "16"
+	if _, err := GenerateDockerfile(pluginDeclaration); err == nil {
+		t.Fatalf("Expected error for unsupported node version, got nil")
+	}
+}
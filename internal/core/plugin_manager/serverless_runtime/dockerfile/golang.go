@@ -0,0 +1,24 @@
+package dockerfile
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	goTemplates = map[string]func(configuration *plugin_entities.PluginDeclaration) (string, error){
+		"1.22": GenerateGoDockerfile,
+		"1.23": GenerateGoDockerfile,
+	}
+)
+
+//go:embed golang.dockerfile
+var goDockerfileTmpl string
+
+// GenerateGoDockerfile generates a dockerfile for go plugins, the declared version picks the builder image
+func GenerateGoDockerfile(configuration *plugin_entities.PluginDeclaration) (string, error) {
+	dockerfile := strings.Replace(goDockerfileTmpl, "{{entrypoint}}", configuration.Meta.Runner.Entrypoint, -1)
+	return strings.Replace(dockerfile, "{{version}}", configuration.Meta.Runner.Version, -1), nil
+}
@@ -0,0 +1,25 @@
+package dockerfile
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	nodejsTemplates = map[string]func(configuration *plugin_entities.PluginDeclaration) (string, error){
+		"18": GenerateNodeJSDockerfile,
+		"20": GenerateNodeJSDockerfile,
+		"22": GenerateNodeJSDockerfile,
+	}
+)
+
+//go:embed nodejs.dockerfile
+var nodejsDockerfileTmpl string
+
+// GenerateNodeJSDockerfile generates a dockerfile for nodejs plugins, the declared major version picks the image
+func GenerateNodeJSDockerfile(configuration *plugin_entities.PluginDeclaration) (string, error) {
+	dockerfile := strings.Replace(nodejsDockerfileTmpl, "{{entrypoint}}", configuration.Meta.Runner.Entrypoint, -1)
+	return strings.Replace(dockerfile, "{{version}}", configuration.Meta.Runner.Version, -1), nil
+}
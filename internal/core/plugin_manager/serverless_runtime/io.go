@@ -17,11 +17,11 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
-func (r *AWSPluginRuntime) Listen(sessionId string) *entities.Broadcast[plugin_entities.SessionMessage] {
+func (r *AWSPluginRuntime) Listen(sessionId string) (*entities.Broadcast[plugin_entities.SessionMessage], error) {
 	l := entities.NewBroadcast[plugin_entities.SessionMessage]()
 	// store the listener
 	r.listeners.Store(sessionId, l)
-	return l
+	return l, nil
 }
 
 // For AWS Lambda, write is equivalent to http request, it's not a normal stream like stdio and tcp
@@ -130,7 +130,7 @@ func (r *AWSPluginRuntime) Write(sessionId string, action access_types.PluginAcc
 						}),
 					})
 				},
-				func(message string) {},
+				func(string, plugin_entities.PluginLogEvent) {},
 			)
 		}
 
@@ -5,17 +5,11 @@ import (
 )
 
 // UninstallFromLocal uninstalls a plugin from local storage
-// once deleted, local runtime will automatically shutdown and exit after several time
+// once deleted, local runtime stops accepting new sessions and exits after in-flight sessions finished
 func (p *PluginManager) UninstallFromLocal(identity plugin_entities.PluginUniqueIdentifier) error {
 	if err := p.installedBucket.Delete(identity); err != nil {
 		return err
 	}
-	// send shutdown runtime
-	runtime, ok := p.m.Load(identity.String())
-	if !ok {
-		// no runtime to shutdown, already uninstalled
-		return nil
-	}
-	runtime.Stop()
+	p.Drain(identity)
 	return nil
 }
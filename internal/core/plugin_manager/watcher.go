@@ -1,6 +1,8 @@
 package plugin_manager
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
@@ -14,12 +16,73 @@ import (
 func (p *PluginManager) startLocalWatcher() {
 	go func() {
 		log.Info("start to handle new plugins in path: %s", p.pluginStoragePath)
-		p.handleNewLocalPlugins()
+		p.SyncLocalPlugins()
 		for range time.NewTicker(time.Second * 30).C {
-			p.handleNewLocalPlugins()
-			p.removeUninstalledLocalPlugins()
+			p.SyncLocalPlugins()
 		}
 	}()
+
+	if p.pythonEnvCache != nil {
+		go func() {
+			for range time.NewTicker(time.Hour).C {
+				p.collectPythonEnvs()
+			}
+		}()
+	}
+}
+
+// collectPythonEnvs removes shared python environments no longer linked by any local plugin
+func (p *PluginManager) collectPythonEnvs() {
+	if removed := p.pythonEnvCache.GC(p.workingDirectory, p.pythonEnvCacheGCGrace); removed > 0 {
+		log.Info("removed %d unused python environments", removed)
+	}
+}
+
+// SetLocalPluginScheduler sets a scheduler to decide whether a local plugin should run on current node,
+// all installed plugins run on current node if no scheduler is set
+func (p *PluginManager) SetLocalPluginScheduler(scheduler func(identity plugin_entities.PluginUniqueIdentifier) bool) {
+	p.localPluginScheduler = scheduler
+}
+
+func (p *PluginManager) isLocalPluginScheduledHere(identity plugin_entities.PluginUniqueIdentifier) bool {
+	if p.localPluginScheduler == nil {
+		return true
+	}
+	return p.localPluginScheduler(identity)
+}
+
+// SetLocalPluginPlacer sets a placer called once a local plugin is installed,
+// it decides whether the plugin is launched on current node, the plugin is always launched if no placer is set
+func (p *PluginManager) SetLocalPluginPlacer(placer func(identity plugin_entities.PluginUniqueIdentifier) (bool, error)) {
+	p.localPluginPlacer = placer
+}
+
+func (p *PluginManager) placeInstalledLocalPlugin(identity plugin_entities.PluginUniqueIdentifier) (bool, error) {
+	if p.localPluginPlacer == nil {
+		return true, nil
+	}
+	return p.localPluginPlacer(identity)
+}
+
+// InstalledLocalPlugins lists all plugins installed in local storage
+func (p *PluginManager) InstalledLocalPlugins() ([]plugin_entities.PluginUniqueIdentifier, error) {
+	if p.platform != app.PLATFORM_LOCAL {
+		return nil, nil
+	}
+	return p.installedBucket.List()
+}
+
+// SyncLocalPlugins launches plugins scheduled to current node and stops the ones no longer scheduled or installed
+func (p *PluginManager) SyncLocalPlugins() {
+	if p.platform != app.PLATFORM_LOCAL {
+		return
+	}
+
+	p.localPluginSyncLock.Lock()
+	defer p.localPluginSyncLock.Unlock()
+
+	p.handleNewLocalPlugins()
+	p.removeUninstalledLocalPlugins()
 }
 
 func (p *PluginManager) initRemotePluginServer(config *app.Config) {
@@ -66,6 +129,16 @@ func (p *PluginManager) startRemoteWatcher(config *app.Config) {
 	}
 }
 
+// ServeDebuggingWebSocket attaches a remote debugging plugin through a websocket
+func (p *PluginManager) ServeDebuggingWebSocket(w http.ResponseWriter, r *http.Request) error {
+	if p.remotePluginServer == nil {
+		return errors.New("remote debugging is not enabled")
+	}
+
+	p.remotePluginServer.ServeWebSocket(w, r)
+	return nil
+}
+
 func (p *PluginManager) handleNewLocalPlugins() {
 	// walk through all plugins
 	plugins, err := p.installedBucket.List()
@@ -75,6 +148,11 @@ func (p *PluginManager) handleNewLocalPlugins() {
 	}
 
 	for _, plugin := range plugins {
+		// skip plugins scheduled to other nodes
+		if !p.isLocalPluginScheduledHere(plugin) {
+			continue
+		}
+
 		_, launchedChan, errChan, err := p.launchLocal(plugin)
 		if err != nil {
 			log.Error("launch local plugin failed: %s", err.Error())
@@ -90,7 +168,7 @@ func (p *PluginManager) handleNewLocalPlugins() {
 	}
 }
 
-// an async function to remove uninstalled local plugins
+// an async function to remove uninstalled local plugins and the ones scheduled to other nodes
 func (p *PluginManager) removeUninstalledLocalPlugins() {
 	// read all local plugin runtimes
 	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
@@ -114,7 +192,12 @@ func (p *PluginManager) removeUninstalledLocalPlugins() {
 		}
 
 		if !exists {
-			runtime.Stop()
+			p.Drain(pluginUniqueIdentifier)
+		} else if !p.isLocalPluginScheduledHere(pluginUniqueIdentifier) {
+			if !runtime.Draining() {
+				log.Info("plugin %s is no longer scheduled to current node, stopping it", pluginUniqueIdentifier.String())
+			}
+			p.Drain(pluginUniqueIdentifier)
 		}
 
 		return true
@@ -1,6 +1,7 @@
 package plugin_manager
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
@@ -44,8 +45,8 @@ func (r *fakePlugin) Wait() (<-chan bool, error) {
 	return nil, nil
 }
 
-func (r *fakePlugin) Listen(string) *entities.Broadcast[plugin_entities.SessionMessage] {
-	return nil
+func (r *fakePlugin) Listen(string) (*entities.Broadcast[plugin_entities.SessionMessage], error) {
+	return nil, nil
 }
 
 func (r *fakePlugin) Write(string, access_types.PluginAccessAction, []byte) {
@@ -103,6 +104,9 @@ func (f *fakeRemotePluginServer) Stop() error {
 	return nil
 }
 
+func (f *fakeRemotePluginServer) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+}
+
 func (f *fakeRemotePluginServer) Wrap(fn func(plugin_entities.PluginFullDuplexLifetime)) {
 	fn(getRandomPluginRuntime())
 }
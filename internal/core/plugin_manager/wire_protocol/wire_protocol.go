@@ -0,0 +1,167 @@
+// Package wire_protocol encodes and decodes messages exchanged with plugins over stdio and debugging connections.
+//
+// Version 1 delimits json messages by newlines, binary data is base64 encoded inside.
+// Version 2 sends length prefixed frames:
+//
+//	| length of kind and payload, uint32 big endian | kind, 1 byte | payload |
+//
+// A frame is smaller than 16MB, so its first byte is always 0 which never starts a json line,
+// decoders accept both in the same stream and plugins only speaking version 1 keep working.
+package wire_protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+const (
+	PROTOCOL_VERSION_JSON_LINE = 1
+	PROTOCOL_VERSION_FRAMED    = 2
+	// the highest version the daemon speaks
+	PROTOCOL_VERSION_LATEST = PROTOCOL_VERSION_FRAMED
+
+	// limit of a frame and a json line, the first byte of frame length is always 0
+	MAX_MESSAGE_SIZE = 1<<24 - 1
+
+	frameLengthSize = 4
+)
+
+type FrameKind byte
+
+const (
+	// payload is a json message, the same as a line of version 1
+	FRAME_KIND_JSON FrameKind = 0x01
+	// payload is a cbor map, byte strings carry binary data such as blobs without base64,
+	// it's passed on as cbor and decoded by the receiver of the message
+	FRAME_KIND_CBOR FrameKind = 0x02
+)
+
+var (
+	ErrMessageTooLarge  = errors.New("message is too large")
+	ErrUnknownFrameKind = errors.New("unknown frame kind")
+	ErrEmptyFrame       = errors.New("frame without kind")
+	ErrCborNotMap       = errors.New("cbor frame is not a map")
+)
+
+// EncodeFrame wraps the payload into a frame
+func EncodeFrame(kind FrameKind, payload []byte) ([]byte, error) {
+	length := len(payload) + 1
+	if length > MAX_MESSAGE_SIZE {
+		return nil, ErrMessageTooLarge
+	}
+
+	frame := make([]byte, frameLengthSize+length)
+	binary.BigEndian.PutUint32(frame, uint32(length))
+	frame[frameLengthSize] = byte(kind)
+	copy(frame[frameLengthSize+1:], payload)
+	return frame, nil
+}
+
+// Encode encodes a json message for a peer speaking the version
+func Encode(version int, message []byte) ([]byte, error) {
+	if version >= PROTOCOL_VERSION_FRAMED {
+		return EncodeFrame(FRAME_KIND_JSON, message)
+	}
+
+	if len(message)+1 > MAX_MESSAGE_SIZE {
+		return nil, ErrMessageTooLarge
+	}
+	return append(message, '\n'), nil
+}
+
+// Decoder splits a stream into json messages, json lines and frames are accepted at the same time,
+// the zero value is ready to use
+type Decoder struct {
+	buf bytes.Buffer
+	// bytes of the buffer known to contain no newline
+	scanned int
+	framed  bool
+}
+
+// Framed returns true once a frame has been decoded, the peer speaks version 2
+func (d *Decoder) Framed() bool {
+	return d.framed
+}
+
+// Feed appends data read from the stream and returns complete messages, empty lines included,
+// messages are json or cbor maps, parser.IsCborMap tells them apart.
+// Undecodable frames are skipped and the first error is returned along with other messages,
+// the stream should be closed if the error is ErrMessageTooLarge
+func (d *Decoder) Feed(data []byte) ([][]byte, error) {
+	d.buf.Write(data)
+
+	messages := [][]byte{}
+	var firstErr error
+
+	for d.buf.Len() > 0 {
+		pending := d.buf.Bytes()
+
+		if pending[0] == 0 {
+			if len(pending) < frameLengthSize {
+				break
+			}
+
+			// at most MAX_MESSAGE_SIZE since the first byte is 0
+			length := int(binary.BigEndian.Uint32(pending))
+			if len(pending) < frameLengthSize+length {
+				break
+			}
+
+			frame := d.buf.Next(frameLengthSize + length)[frameLengthSize:]
+			d.framed = true
+			d.scanned = 0
+
+			message, err := decodeFrame(frame)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			messages = append(messages, message)
+			continue
+		}
+
+		index := bytes.IndexByte(pending[d.scanned:], '\n')
+		if index < 0 {
+			if len(pending) > MAX_MESSAGE_SIZE {
+				return messages, ErrMessageTooLarge
+			}
+			d.scanned = len(pending)
+			break
+		}
+
+		line := d.buf.Next(d.scanned + index + 1)
+		d.scanned = 0
+		messages = append(messages, bytes.Clone(line[:len(line)-1]))
+	}
+
+	return messages, firstErr
+}
+
+func decodeFrame(frame []byte) ([]byte, error) {
+	if len(frame) == 0 {
+		return nil, ErrEmptyFrame
+	}
+
+	payload := frame[1:]
+	switch FrameKind(frame[0]) {
+	case FRAME_KIND_JSON:
+		return bytes.Clone(payload), nil
+	case FRAME_KIND_CBOR:
+		if err := cbor.Wellformed(payload); err != nil {
+			return nil, fmt.Errorf("decode cbor frame failed: %w", err)
+		}
+		if !parser.IsCborMap(payload) {
+			return nil, ErrCborNotMap
+		}
+		return bytes.Clone(payload), nil
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownFrameKind, frame[0])
+	}
+}
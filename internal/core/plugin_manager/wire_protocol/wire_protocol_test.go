@@ -0,0 +1,157 @@
+package wire_protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+func TestDecoderMixedLinesAndFrames(t *testing.T) {
+	frame, err := EncodeFrame(FRAME_KIND_JSON, []byte(`{"event":"heartbeat"}`))
+	if err != nil {
+		t.Fatalf("encode frame failed: %v", err)
+	}
+
+	stream := append([]byte("{\"event\":\"log\"}\n"), frame...)
+	stream = append(stream, []byte("{\"event\":\"error\"}\n")...)
+
+	// feed byte by byte to cover partial lines and frames
+	decoder := &Decoder{}
+	messages := []string{}
+	for i := range stream {
+		decoded, err := decoder.Feed(stream[i : i+1])
+		if err != nil {
+			t.Fatalf("feed failed: %v", err)
+		}
+		for _, message := range decoded {
+			messages = append(messages, string(message))
+		}
+	}
+
+	expected := []string{`{"event":"log"}`, `{"event":"heartbeat"}`, `{"event":"error"}`}
+	if len(messages) != len(expected) {
+		t.Fatalf("expected %d messages, got %v", len(expected), messages)
+	}
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], messages[i])
+		}
+	}
+
+	if !decoder.Framed() {
+		t.Errorf("decoder should be framed after a frame is decoded")
+	}
+}
+
+func cborFrame(t *testing.T, message any) []byte {
+	payload, err := cbor.Marshal(message)
+	if err != nil {
+		t.Fatalf("marshal cbor failed: %v", err)
+	}
+
+	frame, err := EncodeFrame(FRAME_KIND_CBOR, payload)
+	if err != nil {
+		t.Fatalf("encode frame failed: %v", err)
+	}
+	return frame
+}
+
+func TestDecoderCborFrame(t *testing.T) {
+	blob := []byte{0x00, 0xff, '\n', 0x01}
+	frame := cborFrame(t, map[string]any{
+		"session_id": "abc",
+		"event":      "session",
+		"data": map[string]any{
+			"type": "stream",
+			"data": map[string]any{
+				"type":    "blob_chunk",
+				"message": map[string]any{"id": "file", "total_length": 4, "blob": blob, "end": false},
+			},
+		},
+	})
+
+	messages, err := (&Decoder{}).Feed(frame)
+	if err != nil {
+		t.Fatalf("feed failed: %v", err)
+	}
+	if len(messages) != 1 || !parser.IsCborMap(messages[0]) {
+		t.Fatalf("expected 1 cbor message, got %v", messages)
+	}
+
+	// the blob stays binary from the frame to the decoded chunk
+	var sessionData []byte
+	plugin_entities.ParsePluginUniversalEvent(messages[0], "", func(sessionId string, data []byte) {
+		if sessionId != "abc" {
+			t.Errorf("expected session abc, got %s", sessionId)
+		}
+		sessionData = data
+	}, func() {}, func(err string) {
+		t.Errorf("unexpected error: %s", err)
+	}, func(string, plugin_entities.PluginLogEvent) {})
+
+	message, err := plugin_entities.ParseSessionMessage(sessionData)
+	if err != nil {
+		t.Fatalf("parse session message failed: %v", err)
+	}
+	if message.Type != plugin_entities.SESSION_MESSAGE_TYPE_STREAM {
+		t.Fatalf("expected stream message, got %s", message.Type)
+	}
+
+	chunk, err := parser.UnmarshalJsonOrCborBytes[agent_entities.AgentStrategyResponseChunk](message.Data)
+	if err != nil {
+		t.Fatalf("decode chunk failed: %v", err)
+	}
+	if chunk.Type != tool_entities.ToolResponseChunkTypeBlobChunk {
+		t.Fatalf("expected blob chunk, got %s", chunk.Type)
+	}
+	if decoded, ok := chunk.Message["blob"].([]byte); !ok || !bytes.Equal(decoded, blob) {
+		t.Errorf("blob should be kept as bytes, got %#v", chunk.Message["blob"])
+	}
+}
+
+func TestDecoderCborFrameNotMap(t *testing.T) {
+	messages, err := (&Decoder{}).Feed(cborFrame(t, []string{"not", "a", "map"}))
+	if !errors.Is(err, ErrCborNotMap) || len(messages) != 0 {
+		t.Errorf("expected ErrCborNotMap, got %v, %v", messages, err)
+	}
+}
+
+func TestDecoderInvalidFrames(t *testing.T) {
+	unknown, _ := EncodeFrame(FrameKind(0x7f), []byte("?"))
+	valid, _ := EncodeFrame(FRAME_KIND_JSON, []byte(`{}`))
+
+	messages, err := (&Decoder{}).Feed(append(unknown, valid...))
+	if !errors.Is(err, ErrUnknownFrameKind) {
+		t.Errorf("expected ErrUnknownFrameKind, got %v", err)
+	}
+	if len(messages) != 1 || string(messages[0]) != `{}` {
+		t.Errorf("frames after the unknown one should be decoded, got %v", messages)
+	}
+
+	line := bytes.Repeat([]byte("a"), MAX_MESSAGE_SIZE+1)
+	if _, err := (&Decoder{}).Feed(line); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	line, err := Encode(PROTOCOL_VERSION_JSON_LINE, []byte(`{}`))
+	if err != nil || string(line) != "{}\n" {
+		t.Errorf("expected a json line, got %q, %v", line, err)
+	}
+
+	frame, err := Encode(PROTOCOL_VERSION_FRAMED, []byte(`{}`))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if frame[0] != 0 || binary.BigEndian.Uint32(frame) != 3 || FrameKind(frame[4]) != FRAME_KIND_JSON {
+		t.Errorf("unexpected frame %v", frame)
+	}
+}
@@ -0,0 +1,102 @@
+package rate_limiter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+type Dimension string
+
+const (
+	DIMENSION_TENANT   Dimension = "tenant"
+	DIMENSION_PLUGIN   Dimension = "plugin"
+	DIMENSION_ACTION   Dimension = "action"
+	DIMENSION_ENDPOINT Dimension = "endpoint"
+)
+
+const (
+	RATE_LIMIT_BUCKET_PREFIX = "rate_limit:bucket"
+	RATE_LIMIT_USAGE_PREFIX  = "rate_limit:usage"
+	// usage counters are kept for a week
+	RATE_LIMIT_USAGE_TTL = 7 * 24 * time.Hour
+	// usage counters are grouped by utc day
+	RATE_LIMIT_USAGE_DATE_FORMAT = "2006-01-02"
+)
+
+// Subject is what a request is accounted to, a request may be accounted to several subjects
+type Subject struct {
+	Dimension Dimension
+	ID        string
+}
+
+func (s Subject) String() string {
+	return string(s.Dimension) + ":" + s.ID
+}
+
+// Decision is the result of a rate limit check
+type Decision struct {
+	Allowed bool
+	// the subject which has exhausted its bucket, only set if not allowed
+	LimitedBy  *Subject
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	rules map[Dimension]app.RateLimitRule
+}
+
+func NewLimiter(rules *app.RateLimitRules) *Limiter {
+	return &Limiter{
+		rules: map[Dimension]app.RateLimitRule{
+			DIMENSION_TENANT:   rules.Tenant,
+			DIMENSION_PLUGIN:   rules.Plugin,
+			DIMENSION_ACTION:   rules.Action,
+			DIMENSION_ENDPOINT: rules.Endpoint,
+		},
+	}
+}
+
+// bucketKey returns the bucket of the subject, plugins and actions are shared by all tenants,
+// each tenant has its own buckets of them so that one tenant never exhausts them for others
+func bucketKey(tenantId string, subject Subject) string {
+	switch subject.Dimension {
+	case DIMENSION_PLUGIN, DIMENSION_ACTION:
+		return strings.Join([]string{RATE_LIMIT_BUCKET_PREFIX, tenantId, subject.String()}, ":")
+	default:
+		return strings.Join([]string{RATE_LIMIT_BUCKET_PREFIX, subject.String()}, ":")
+	}
+}
+
+// Allow takes a token from every limited subject, usages are accounted to the tenant
+func (l *Limiter) Allow(tenantId string, subjects ...Subject) (*Decision, error) {
+	buckets := make([]cache.TokenBucket, 0, len(subjects))
+	limited := make([]Subject, 0, len(subjects))
+	for _, subject := range subjects {
+		limit := l.rules[subject.Dimension].Limit(subject.ID)
+		if limit == nil {
+			continue
+		}
+
+		buckets = append(buckets, cache.TokenBucket{
+			Key:   bucketKey(tenantId, subject),
+			Rate:  limit.Rate,
+			Burst: limit.Burst,
+		})
+		limited = append(limited, subject)
+	}
+
+	rejected, wait, err := cache.TakeTokens(buckets, 1, usageOf(tenantId, subjects))
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &Decision{Allowed: rejected == -1, RetryAfter: wait}
+	if !decision.Allowed {
+		decision.LimitedBy = &limited[rejected]
+	}
+
+	return decision, nil
+}
@@ -0,0 +1,67 @@
+package rate_limiter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+type Usage struct {
+	Allowed int64 `json:"allowed"`
+	Limited int64 `json:"limited"`
+}
+
+func usageKey(tenantId string, date time.Time) string {
+	return strings.Join([]string{
+		RATE_LIMIT_USAGE_PREFIX,
+		tenantId,
+		date.UTC().Format(RATE_LIMIT_USAGE_DATE_FORMAT),
+	}, ":")
+}
+
+// usageOf counts the outcome of a request to every subject, in the same round trip as the rate limit check
+func usageOf(tenantId string, subjects []Subject) *cache.TokenUsage {
+	fields := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		fields = append(fields, subject.String())
+	}
+
+	return &cache.TokenUsage{
+		Key:    usageKey(tenantId, time.Now()),
+		Fields: fields,
+		TTL:    RATE_LIMIT_USAGE_TTL,
+	}
+}
+
+// GetUsage returns usage counters of the tenant on the utc day of date, keyed by subject
+func GetUsage(tenantId string, date time.Time) (map[string]*Usage, error) {
+	fields, err := cache.GetMap[int64](usageKey(tenantId, date))
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	result := map[string]*Usage{}
+	for field, count := range fields {
+		separator := strings.LastIndex(field, ":")
+		if separator <= 0 {
+			continue
+		}
+
+		subject, outcome := field[:separator], field[separator+1:]
+		usage, ok := result[subject]
+		if !ok {
+			usage = &Usage{}
+			result[subject] = usage
+		}
+
+		switch outcome {
+		case cache.TOKEN_USAGE_ALLOWED:
+			usage.Allowed = count
+		case cache.TOKEN_USAGE_LIMITED:
+			usage.Limited = count
+		}
+	}
+
+	return result, nil
+}
@@ -1,6 +1,7 @@
 package session_manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -12,7 +13,10 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -39,6 +43,9 @@ type Session struct {
 	MessageID      *string `json:"message_id"`
 	AppID          *string `json:"app_id"`
 	EndpointID     *string `json:"endpoint_id"`
+
+	// w3c trace context of the invocation, sent to the plugin and used by backwards invocations
+	TraceContext map[string]string `json:"trace_context"`
 }
 
 func sessionKey(id string) string {
@@ -59,6 +66,7 @@ type NewSessionPayload struct {
 	MessageID              *string                                `json:"message_id"`
 	AppID                  *string                                `json:"app_id"`
 	EndpointID             *string                                `json:"endpoint_id"`
+	TraceContext           map[string]string                      `json:"trace_context"`
 }
 
 func NewSession(payload NewSessionPayload) *Session {
@@ -76,6 +84,7 @@ func NewSession(payload NewSessionPayload) *Session {
 		MessageID:              payload.MessageID,
 		AppID:                  payload.AppID,
 		EndpointID:             payload.EndpointID,
+		TraceContext:           payload.TraceContext,
 	}
 
 	session_lock.Lock()
@@ -84,7 +93,7 @@ func NewSession(payload NewSessionPayload) *Session {
 
 	if !payload.IgnoreCache {
 		if err := cache.Store(sessionKey(s.ID), s, time.Minute*30); err != nil {
-			log.Error("set session info to cache failed, %s", err)
+			s.Logger().Error("set session info to cache failed, %s", err)
 		}
 	}
 
@@ -131,6 +140,27 @@ func DeleteSession(payload DeleteSessionPayload) {
 	}
 }
 
+// Count returns the number of in-flight sessions of current node
+func Count() int {
+	session_lock.RLock()
+	defer session_lock.RUnlock()
+	return len(sessions)
+}
+
+// ListByPlugin returns the in-flight sessions of the plugin on current node
+func ListByPlugin(identifier plugin_entities.PluginUniqueIdentifier) []*Session {
+	session_lock.RLock()
+	defer session_lock.RUnlock()
+
+	result := []*Session{}
+	for _, session := range sessions {
+		if session.PluginUniqueIdentifier == identifier {
+			result = append(result, session)
+		}
+	}
+	return result
+}
+
 type CloseSessionPayload struct {
 	IgnoreCache bool `json:"ignore_cache"`
 }
@@ -158,6 +188,26 @@ func (s *Session) BackwardsInvocation() dify_invocation.BackwardsInvocation {
 	return s.backwardsInvocation
 }
 
+// Logger returns a logger which attaches tenant, plugin and session to every log
+func (s *Session) Logger() *log.Logger {
+	return log.With(
+		log.FIELD_TENANT_ID, s.TenantID,
+		log.FIELD_PLUGIN, s.PluginUniqueIdentifier.String(),
+		log.FIELD_SESSION_ID, s.ID,
+	)
+}
+
+// Context returns a context carrying the span of the session
+func (s *Session) Context() context.Context {
+	return tracing.FromCarrier(s.TraceContext)
+}
+
+// BindTraceContext makes the span in ctx the parent of what happens next in the session,
+// it only affects current node, the cached session keeps the context it was created with
+func (s *Session) BindTraceContext(ctx context.Context) {
+	s.TraceContext = tracing.Carrier(ctx)
+}
+
 type PLUGIN_IN_STREAM_EVENT string
 
 const (
@@ -172,6 +222,7 @@ func (s *Session) Message(event PLUGIN_IN_STREAM_EVENT, data any) []byte {
 		"message_id":      s.MessageID,
 		"app_id":          s.AppID,
 		"endpoint_id":     s.EndpointID,
+		"trace_context":   s.TraceContext,
 		"event":           event,
 		"data":            data,
 	})
@@ -181,6 +232,17 @@ func (s *Session) Write(event PLUGIN_IN_STREAM_EVENT, action access_types.Plugin
 	if s.runtime == nil {
 		return errors.New("runtime not bound")
 	}
+
+	_, span := tracing.Start(
+		s.Context(),
+		"plugin.write",
+		trace.SpanKindProducer,
+		attribute.String("session.id", s.ID),
+		attribute.String("plugin.event", string(event)),
+		attribute.String("plugin.runtime_type", string(s.runtime.Type())),
+	)
+	defer span.End()
+
 	s.runtime.Write(s.ID, action, s.Message(event, data))
 	return nil
 }
@@ -116,3 +116,17 @@ func UpdateCache[T any](p *UpdateCachePayload[T]) error {
 
 	return nil
 }
+
+// InvalidateCache drops the cached value of each key without touching the database
+func InvalidateCache[T any](keys ...[]KeyValuePair) error {
+	var t T
+	typename := reflect.TypeOf(t).String()
+
+	for _, pairs := range keys {
+		if err := cache.Del(joinCacheKey(typename, pairs)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -88,6 +88,9 @@ func autoMigrate() error {
 		models.InstallTask{},
 		models.TenantStorage{},
 		models.AgentStrategyInstallation{},
+		models.ApiCredential{},
+		models.PluginNetworkApproval{},
+		models.PluginDebuggingAudit{},
 	)
 
 	if err != nil {
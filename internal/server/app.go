@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limiter"
 )
 
 type App struct {
@@ -17,4 +18,7 @@ type App struct {
 	// aws transaction handler
 	// accept aws transaction request and forward to the plugin daemon
 	awsTransactionHandler *transaction.AWSTransactionHandler
+
+	// rate limiter, nil if rate limiting is disabled
+	rateLimiter *rate_limiter.Limiter
 }
@@ -7,4 +7,6 @@ const (
 	CONTEXT_KEY_PLUGIN_INSTALLATION      = "plugin_installation"
 	CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER = "plugin_unique_identifier"
 	CONTEXT_KEY_CLUSTER_ID               = "cluster_id"
+	CONTEXT_KEY_API_CREDENTIAL           = "api_credential"
+	CONTEXT_KEY_REDIRECTED_REQUEST       = "redirected_request"
 )
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func CreateApiCredential(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		Name      string                      `json:"name" validate:"required,max=127"`
+		Tenants   []string                    `json:"tenants" validate:"required,min=1"`
+		Scopes    []models.ApiCredentialScope `json:"scopes" validate:"required,min=1"`
+		ExpiredAt *time.Time                  `json:"expired_at" validate:"omitempty"`
+	}) {
+		ctx.JSON(200, service.CreateApiCredential(
+			request.Name, request.Tenants, request.Scopes, request.ExpiredAt,
+		))
+	})
+}
+
+func ListApiCredentials(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		Page     int `form:"page" validate:"required"`
+		PageSize int `form:"page_size" validate:"required,max=100"`
+	}) {
+		ctx.JSON(200, service.ListApiCredentials(request.Page, request.PageSize))
+	})
+}
+
+func RotateApiCredential(config *app.Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		BindRequest(ctx, func(request struct {
+			CredentialID string `json:"credential_id" validate:"required"`
+		}) {
+			ctx.JSON(200, service.RotateApiCredential(
+				request.CredentialID,
+				time.Duration(*config.ApiCredentialRotationGracePeriod)*time.Second,
+			))
+		})
+	}
+}
+
+func RevokeApiCredential(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		CredentialID string `json:"credential_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.RevokeApiCredential(request.CredentialID))
+	})
+}
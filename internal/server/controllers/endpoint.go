@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -14,6 +17,8 @@ func SetupEndpoint(ctx *gin.Context) {
 			UserID                 string                                 `json:"user_id" validate:"required"`
 			Settings               map[string]any                         `json:"settings" validate:"omitempty"`
 			Name                   string                                 `json:"name" validate:"required"`
+			ExpiredAt              *time.Time                             `json:"expired_at" validate:"omitempty"`
+			Auth                   *models.EndpointAuth                   `json:"auth" validate:"omitempty"`
 		},
 	) {
 		tenantId := request.TenantID
@@ -24,6 +29,7 @@ func SetupEndpoint(ctx *gin.Context) {
 
 		ctx.JSON(200, service.SetupEndpoint(
 			tenantId, userId, pluginUniqueIdentifier, name, settings,
+			request.ExpiredAt, request.Auth,
 		))
 	})
 }
@@ -72,11 +78,14 @@ func RemoveEndpoint(ctx *gin.Context) {
 
 func UpdateEndpoint(ctx *gin.Context) {
 	BindRequest(ctx, func(request struct {
-		EndpointID string         `json:"endpoint_id" validate:"required"`
-		TenantID   string         `uri:"tenant_id" validate:"required"`
-		UserID     string         `json:"user_id" validate:"required"`
-		Settings   map[string]any `json:"settings" validate:"omitempty"`
-		Name       string         `json:"name" validate:"required"`
+		EndpointID string               `json:"endpoint_id" validate:"required"`
+		TenantID   string               `uri:"tenant_id" validate:"required"`
+		UserID     string               `json:"user_id" validate:"required"`
+		Settings   map[string]any       `json:"settings" validate:"omitempty"`
+		Name       string               `json:"name" validate:"required"`
+		ExpiredAt  *time.Time           `json:"expired_at" validate:"omitempty"`
+		Auth       *models.EndpointAuth `json:"auth" validate:"omitempty"`
+		ClearAuth  bool                 `json:"clear_auth"`
 	}) {
 		tenantId := request.TenantID
 		userId := request.UserID
@@ -84,7 +93,10 @@ func UpdateEndpoint(ctx *gin.Context) {
 		settings := request.Settings
 		name := request.Name
 
-		ctx.JSON(200, service.UpdateEndpoint(endpointId, tenantId, userId, name, settings))
+		ctx.JSON(200, service.UpdateEndpoint(
+			endpointId, tenantId, userId, name, settings,
+			request.ExpiredAt, request.Auth, request.ClearAuth,
+		))
 	})
 }
 
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func GetPluginNetworkApproval(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `form:"plugin_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.GetPluginNetworkApproval(request.TenantID, request.PluginID))
+	})
+}
+
+func ApprovePluginNetworkDomains(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string   `uri:"tenant_id" validate:"required"`
+		PluginID string   `json:"plugin_id" validate:"required"`
+		Domains  []string `json:"domains" validate:"omitempty,max=128"`
+	}) {
+		c.JSON(http.StatusOK, service.ApprovePluginNetworkDomains(request.TenantID, request.PluginID, request.Domains))
+	})
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func GetPluginLogs(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		PluginUniqueIdentifier string `form:"plugin_unique_identifier" validate:"required"`
+		Limit                  int    `form:"limit" validate:"omitempty,min=1,max=10000"`
+	}) {
+		ctx.JSON(200, service.GetPluginLogs(request.PluginUniqueIdentifier, request.Limit))
+	})
+}
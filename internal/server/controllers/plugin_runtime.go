@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func GetPluginRuntimeState(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		PluginUniqueIdentifier string `form:"plugin_unique_identifier" validate:"required"`
+	}) {
+		ctx.JSON(200, service.GetPluginRuntimeState(request.PluginUniqueIdentifier))
+	})
+}
+
+func ReleasePluginQuarantine(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		PluginUniqueIdentifier string `json:"plugin_unique_identifier" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ReleasePluginQuarantine(request.PluginUniqueIdentifier))
+	})
+}
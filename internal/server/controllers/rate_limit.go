@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func GetRateLimitUsage(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Date     string `form:"date" validate:"omitempty"`
+	}) {
+		ctx.JSON(200, service.GetRateLimitUsage(request.TenantID, request.Date))
+	})
+}
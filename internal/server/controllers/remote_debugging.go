@@ -1,15 +1,59 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
 )
 
-func GetRemoteDebuggingKey(c *gin.Context) {
+func GetRemoteDebuggingKey(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(
+			c, func(request requests.RequestGetRemoteDebuggingKey) {
+				c.JSON(200, service.GetRemoteDebuggingKey(
+					request.TenantID,
+					request.UserID,
+					time.Duration(config.PluginRemoteInstallingKeyTTL)*time.Second,
+					config.PluginRemoteInstallingMaxSessionsPerKey,
+				))
+			},
+		)
+	}
+}
+
+func ListRemoteDebuggingKeys(c *gin.Context) {
+	BindRequest(
+		c, func(request requests.RequestListRemoteDebuggingKeys) {
+			c.JSON(200, service.ListRemoteDebuggingKeys(request.TenantID))
+		},
+	)
+}
+
+func RevokeRemoteDebuggingKey(c *gin.Context) {
 	BindRequest(
-		c, func(request requests.RequestGetRemoteDebuggingKey) {
-			c.JSON(200, service.GetRemoteDebuggingKey(request.TenantID))
+		c, func(request requests.RequestRevokeRemoteDebuggingKey) {
+			c.JSON(200, service.RevokeRemoteDebuggingKey(request.TenantID, request.KeyID))
 		},
 	)
 }
+
+func ListRemoteDebuggingAudits(c *gin.Context) {
+	BindRequest(
+		c, func(request requests.RequestListRemoteDebuggingAudits) {
+			c.JSON(200, service.ListRemoteDebuggingAudits(
+				request.TenantID, request.KeyID, request.Page, request.PageSize,
+			))
+		},
+	)
+}
+
+func ServeRemoteDebuggingWebSocket(c *gin.Context) {
+	if err := plugin_manager.Manager().ServeDebuggingWebSocket(c.Writer, c.Request); err != nil {
+		c.JSON(404, exception.NotFoundError(err).ToResponse())
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limiter"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
@@ -49,6 +51,43 @@ func (app *App) EndpointHandler(ctx *gin.Context, hookId string, maxExecutionTim
 		return
 	}
 
+	// a disabled endpoint does not exist for callers, its guards are not revealed
+	if !endpoint.Enabled {
+		ctx.JSON(404, exception.NotFoundError(errors.New("endpoint not found")).ToResponse())
+		return
+	}
+
+	if isRedirectedRequest(ctx) {
+		// guards and rate limits are checked by the node receiving the request, the expiry is checked again
+		// in case the endpoint expired meanwhile
+		if err := service.CheckEndpointExpiry(&endpoint); err != nil {
+			writeEndpointVerifyError(ctx, err)
+			return
+		}
+	} else {
+		// limits are checked before the guards, decrypting them may call dify
+		if !app.checkRateLimit(
+			ctx, endpoint.TenantID,
+			rate_limiter.Subject{Dimension: rate_limiter.DIMENSION_TENANT, ID: endpoint.TenantID},
+			rate_limiter.Subject{Dimension: rate_limiter.DIMENSION_PLUGIN, ID: endpoint.PluginID},
+			rate_limiter.Subject{Dimension: rate_limiter.DIMENSION_ACTION, ID: string(access_types.PLUGIN_ACCESS_ACTION_INVOKE_ENDPOINT)},
+			rate_limiter.Subject{Dimension: rate_limiter.DIMENSION_ENDPOINT, ID: endpoint.HookID},
+		) {
+			return
+		}
+
+		if err := service.DecryptEndpointAuth(&endpoint); err != nil {
+			log.Error("decrypt endpoint auth error %v", err)
+			ctx.JSON(500, exception.InternalServerError(errors.New("internal server error")).ToResponse())
+			return
+		}
+
+		if err := service.VerifyEndpointRequest(ctx.Request, ctx.ClientIP(), &endpoint); err != nil {
+			writeEndpointVerifyError(ctx, err)
+			return
+		}
+	}
+
 	// get plugin installation
 	pluginInstallation, err := db.GetOne[models.PluginInstallation](
 		db.Equal("plugin_id", endpoint.PluginID),
@@ -71,8 +110,19 @@ func (app *App) EndpointHandler(ctx *gin.Context, hookId string, maxExecutionTim
 
 	// check if plugin exists in current node
 	if ok, originalError := app.cluster.IsPluginOnCurrentNode(pluginUniqueIdentifier); !ok {
-		app.redirectPluginInvokeByPluginIdentifier(ctx, pluginUniqueIdentifier, originalError)
+		app.redirectPluginInvokeByPluginIdentifier(ctx, endpoint.TenantID, pluginUniqueIdentifier, originalError)
 	} else {
 		service.Endpoint(ctx, &endpoint, &pluginInstallation, maxExecutionTime, path)
 	}
 }
+
+func writeEndpointVerifyError(ctx *gin.Context, err error) {
+	switch err {
+	case service.ErrEndpointExpired, service.ErrEndpointIPNotAllowed:
+		ctx.JSON(403, exception.PermissionDeniedError(err.Error()).ToResponse())
+	case service.ErrEndpointSecretMismatch, service.ErrEndpointSignatureInvalid:
+		ctx.JSON(401, exception.UnauthorizedError().ToResponse())
+	default:
+		ctx.JSON(400, exception.BadRequestError(err).ToResponse())
+	}
+}
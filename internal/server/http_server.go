@@ -3,15 +3,19 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 
 	sentrygin "github.com/getsentry/sentry-go/gin"
 )
@@ -27,12 +31,31 @@ func (app *App) server(config *app.Config) func() {
 		}))
 	}
 	engine.Use(gin.Recovery())
+	// only trust forwarded headers from known proxies, the client ip is used by endpoint ip allowlists
+	if err := engine.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Panic("invalid trusted proxies: %s", err)
+	}
 	engine.GET("/health/check", controllers.HealthCheck(config))
 
 	endpointGroup := engine.Group("/e")
 	awsLambdaTransactionGroup := engine.Group("/backwards-invocation")
 	pluginGroup := engine.Group("/plugin/:tenant_id")
+	remoteDebuggingWebSocketGroup := engine.Group("/plugin/debugging")
 	pprofGroup := engine.Group("/debug/pprof")
+	apiCredentialGroup := engine.Group("/credentials")
+	logsGroup := engine.Group("/logs")
+	runtimeGroup := engine.Group("/runtime")
+
+	if config.TracingEnabled {
+		// health checks are not traced
+		for _, group := range []*gin.RouterGroup{
+			endpointGroup,
+			awsLambdaTransactionGroup,
+			pluginGroup,
+		} {
+			group.Use(Tracing())
+		}
+	}
 
 	if config.SentryEnabled {
 		// setup sentry for all groups
@@ -51,67 +74,127 @@ func (app *App) server(config *app.Config) func() {
 	app.endpointGroup(endpointGroup, config)
 	app.awsLambdaTransactionGroup(awsLambdaTransactionGroup, config)
 	app.pluginGroup(pluginGroup, config)
+	app.remoteDebuggingWebSocketGroup(remoteDebuggingWebSocketGroup, config)
 	app.pprofGroup(pprofGroup, config)
+	app.apiCredentialGroup(apiCredentialGroup, config)
+	app.logsGroup(logsGroup, config)
+	app.runtimeGroup(runtimeGroup, config)
 
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", config.ServerPort),
 		Handler: engine,
 	}
 
+	// bind before returning so the server is reachable as soon as server() returns
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Panic("listen: %s\n", err)
+	}
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Panic("listen: %s\n", err)
 		}
 	}()
 
+	stopMetrics := func() {}
+	if config.MetricsEnabled {
+		stopMetrics = app.metricsServer(config)
+	}
+
 	return func() {
+		stopMetrics()
 		if err := srv.Shutdown(context.Background()); err != nil {
 			log.Panic("Server Shutdown: %s\n", err)
 		}
 	}
 }
 
-func (app *App) pluginGroup(group *gin.RouterGroup, config *app.Config) {
-	group.Use(CheckingKey(config.ServerKey))
+// metricsServer serves /metrics on its own listener, metrics expose plugins, traffic of tenants
+// and the cluster topology, they should never be reachable through the public api
+func (app *App) metricsServer(config *app.Config) func() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	srv := &http.Server{
+		Addr:    config.MetricsAddress,
+		Handler: mux,
+	}
+
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Panic("listen metrics: %s\n", err)
+	}
 
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Panic("listen metrics: %s\n", err)
+		}
+	}()
+
+	return func() {
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Panic("Metrics Server Shutdown: %s\n", err)
+		}
+	}
+}
+
+func (app *App) pluginGroup(group *gin.RouterGroup, config *app.Config) {
 	app.remoteDebuggingGroup(group.Group("/debugging"), config)
-	app.pluginDispatchGroup(group.Group("/dispatch"), config)
-	app.pluginManagementGroup(group.Group("/management"), config)
-	app.endpointManagementGroup(group.Group("/endpoint"))
-	app.pluginAssetGroup(group.Group("/asset"))
+	app.pluginDispatchGroup(group.Group("/dispatch", CheckingCredential(config, models.API_CREDENTIAL_SCOPE_DISPATCH)), config)
+	app.pluginManagementGroup(group.Group("/management", CheckingCredential(config, models.API_CREDENTIAL_SCOPE_MANAGEMENT)), config)
+	app.endpointManagementGroup(group.Group("/endpoint", CheckingCredential(config, models.API_CREDENTIAL_SCOPE_ENDPOINT)))
+	app.pluginAssetGroup(group.Group("/asset", CheckingCredential(config, models.API_CREDENTIAL_SCOPE_ASSET)))
 }
 
 func (app *App) pluginDispatchGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(app.VerifyRedirectedRequest())
 	group.Use(app.FetchPluginInstallation())
 	group.Use(app.RedirectPluginInvoke())
 	group.Use(app.InitClusterID())
 
-	group.POST("/tool/invoke", controllers.InvokeTool(config))
-	group.POST("/tool/validate_credentials", controllers.ValidateToolCredentials(config))
-	group.POST("/tool/get_runtime_parameters", controllers.GetToolRuntimeParameters(config))
-	group.POST("/agent_strategy/invoke", controllers.InvokeAgentStrategy(config))
-	group.POST("/llm/invoke", controllers.InvokeLLM(config))
-	group.POST("/llm/num_tokens", controllers.GetLLMNumTokens(config))
-	group.POST("/text_embedding/invoke", controllers.InvokeTextEmbedding(config))
-	group.POST("/text_embedding/num_tokens", controllers.GetTextEmbeddingNumTokens(config))
-	group.POST("/rerank/invoke", controllers.InvokeRerank(config))
-	group.POST("/tts/invoke", controllers.InvokeTTS(config))
-	group.POST("/tts/model/voices", controllers.GetTTSModelVoices(config))
-	group.POST("/speech2text/invoke", controllers.InvokeSpeech2Text(config))
-	group.POST("/moderation/invoke", controllers.InvokeModeration(config))
-	group.POST("/model/validate_provider_credentials", controllers.ValidateProviderCredentials(config))
-	group.POST("/model/validate_model_credentials", controllers.ValidateModelCredentials(config))
-	group.POST("/model/schema", controllers.GetAIModelSchema(config))
+	limit := app.RateLimitDispatch
+	coldStart := app.ColdStartPlugin()
+
+	group.POST("/tool/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL), coldStart, controllers.InvokeTool(config))
+	group.POST("/tool/validate_credentials", limit(access_types.PLUGIN_ACCESS_ACTION_VALIDATE_TOOL_CREDENTIALS), coldStart, controllers.ValidateToolCredentials(config))
+	group.POST("/tool/get_runtime_parameters", limit(access_types.PLUGIN_ACCESS_ACTION_GET_TOOL_RUNTIME_PARAMETERS), coldStart, controllers.GetToolRuntimeParameters(config))
+	group.POST("/agent_strategy/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY), coldStart, controllers.InvokeAgentStrategy(config))
+	group.POST("/llm/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM), coldStart, controllers.InvokeLLM(config))
+	group.POST("/llm/num_tokens", limit(access_types.PLUGIN_ACCESS_ACTION_GET_LLM_NUM_TOKENS), coldStart, controllers.GetLLMNumTokens(config))
+	group.POST("/text_embedding/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_TEXT_EMBEDDING), coldStart, controllers.InvokeTextEmbedding(config))
+	group.POST("/text_embedding/num_tokens", limit(access_types.PLUGIN_ACCESS_ACTION_GET_TEXT_EMBEDDING_NUM_TOKENS), coldStart, controllers.GetTextEmbeddingNumTokens(config))
+	group.POST("/rerank/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_RERANK), coldStart, controllers.InvokeRerank(config))
+	group.POST("/tts/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_TTS), coldStart, controllers.InvokeTTS(config))
+	group.POST("/tts/model/voices", limit(access_types.PLUGIN_ACCESS_ACTION_GET_TTS_MODEL_VOICES), coldStart, controllers.GetTTSModelVoices(config))
+	group.POST("/speech2text/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_SPEECH2TEXT), coldStart, controllers.InvokeSpeech2Text(config))
+	group.POST("/moderation/invoke", limit(access_types.PLUGIN_ACCESS_ACTION_INVOKE_MODERATION), coldStart, controllers.InvokeModeration(config))
+	group.POST("/model/validate_provider_credentials", limit(access_types.PLUGIN_ACCESS_ACTION_VALIDATE_PROVIDER_CREDENTIALS), coldStart, controllers.ValidateProviderCredentials(config))
+	group.POST("/model/validate_model_credentials", limit(access_types.PLUGIN_ACCESS_ACTION_VALIDATE_MODEL_CREDENTIALS), coldStart, controllers.ValidateModelCredentials(config))
+	group.POST("/model/schema", limit(access_types.PLUGIN_ACCESS_ACTION_GET_AI_MODEL_SCHEMAS), coldStart, controllers.GetAIModelSchema(config))
 }
 
 func (app *App) remoteDebuggingGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PluginRemoteInstallingEnabled != nil && *config.PluginRemoteInstallingEnabled {
-		group.POST("/key", CheckingKey(config.ServerKey), controllers.GetRemoteDebuggingKey)
+		group.Use(CheckingCredential(config, models.API_CREDENTIAL_SCOPE_DEBUGGING))
+		group.POST("/key", controllers.GetRemoteDebuggingKey(config))
+		group.GET("/keys", controllers.ListRemoteDebuggingKeys)
+		group.POST("/keys/revoke", controllers.RevokeRemoteDebuggingKey)
+		group.GET("/audits", controllers.ListRemoteDebuggingAudits)
+	}
+}
+
+// plugins attached through websockets authenticate with debugging keys in handshake like the tcp ones
+func (app *App) remoteDebuggingWebSocketGroup(group *gin.RouterGroup, config *app.Config) {
+	if config.PluginRemoteInstallingEnabled != nil && *config.PluginRemoteInstallingEnabled &&
+		config.PluginRemoteInstallingWebSocketEnabled != nil && *config.PluginRemoteInstallingWebSocketEnabled {
+		group.GET("/ws", controllers.ServeRemoteDebuggingWebSocket)
 	}
 }
 
 func (app *App) endpointGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PluginEndpointEnabled != nil && *config.PluginEndpointEnabled {
+		group.Use(app.VerifyRedirectedRequest())
 		group.HEAD("/:hook_id/*path", app.Endpoint(config))
 		group.POST("/:hook_id/*path", app.Endpoint(config))
 		group.GET("/:hook_id/*path", app.Endpoint(config))
@@ -165,6 +248,9 @@ func (app *App) pluginManagementGroup(group *gin.RouterGroup, config *app.Config
 	group.POST("/tools/check_existence", controllers.CheckToolExistence)
 	group.GET("/agent_strategies", controllers.ListAgentStrategies)
 	group.GET("/agent_strategy", controllers.GetAgentStrategy)
+	group.GET("/rate_limit/usage", controllers.GetRateLimitUsage)
+	group.GET("/network/approval", controllers.GetPluginNetworkApproval)
+	group.POST("/network/approve", controllers.ApprovePluginNetworkDomains)
 }
 
 func (app *App) pluginAssetGroup(group *gin.RouterGroup) {
@@ -188,3 +274,32 @@ func (app *App) pprofGroup(group *gin.RouterGroup, config *app.Config) {
 		group.GET("/threadcreate", controllers.PprofThreadcreate)
 	}
 }
+
+// apiCredentialGroup manages scoped api credentials, only the server key is allowed here
+func (app *App) apiCredentialGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(CheckingKey(config.ServerKey))
+
+	group.POST("/create", controllers.CreateApiCredential)
+	group.GET("/list", controllers.ListApiCredentials)
+	group.POST("/rotate", controllers.RotateApiCredential(config))
+	group.POST("/revoke", controllers.RevokeApiCredential)
+}
+
+// plugin logs may contain data of any tenant, only the server key is accepted
+func (app *App) logsGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(CheckingKey(config.ServerKey))
+	// lines are captured by the node running the plugin
+	group.Use(app.VerifyRedirectedRequest())
+
+	group.GET("/plugin", app.RedirectPluginRuntimeRequest(), controllers.GetPluginLogs)
+}
+
+// runtimes of plugins are shared by all tenants and local to each node, only the server key is accepted,
+// states are read from a node running the plugin and releases are published to all nodes
+func (app *App) runtimeGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(CheckingKey(config.ServerKey))
+	group.Use(app.VerifyRedirectedRequest())
+
+	group.GET("/plugin", app.RedirectPluginRuntimeRequest(), controllers.GetPluginRuntimeState)
+	group.POST("/plugin/release", controllers.ReleasePluginQuarantine)
+}
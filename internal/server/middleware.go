@@ -1,16 +1,25 @@
 package server
 
 import (
+	"crypto/subtle"
 	"errors"
-	"io"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func CheckingKey(key string) gin.HandlerFunc {
@@ -25,6 +34,45 @@ func CheckingKey(key string) gin.HandlerFunc {
 	}
 }
 
+// CheckingCredential authorizes the request with a scoped api credential bound to the tenant in path,
+// the server key is accepted as well if legacy mode is enabled
+func CheckingCredential(config *app.Config, scope models.ApiCredentialScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(constants.X_API_KEY)
+		if key == "" {
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+
+		if config.ServerKeyLegacyEnabled != nil && *config.ServerKeyLegacyEnabled &&
+			subtle.ConstantTimeCompare([]byte(key), []byte(config.ServerKey)) == 1 {
+			c.Next()
+			return
+		}
+
+		credential, err := service.AuthenticateApiCredential(key)
+		if err == db.ErrDatabaseNotFound || err == service.ErrApiCredentialNotUsable {
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+
+		if err != nil {
+			c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
+			return
+		}
+
+		if !credential.Allows(c.Param("tenant_id"), scope) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				fmt.Sprintf("api credential is not allowed to access %s of this tenant", scope),
+			).ToResponse())
+			return
+		}
+
+		c.Set(constants.CONTEXT_KEY_API_CREDENTIAL, credential)
+		c.Next()
+	}
+}
+
 func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		pluginId := ctx.Request.Header.Get(constants.X_PLUGIN_ID)
@@ -91,7 +139,7 @@ func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 
 		// check if plugin in current node
 		if ok, originalError := app.cluster.IsPluginOnCurrentNode(identity); !ok {
-			app.redirectPluginInvokeByPluginIdentifier(ctx, identity, originalError)
+			app.redirectPluginInvokeByPluginIdentifier(ctx, ctx.Param("tenant_id"), identity, originalError)
 			ctx.Abort()
 		} else {
 			ctx.Next()
@@ -99,11 +147,45 @@ func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 	}
 }
 
+// ColdStartPlugin starts the plugin if it's stopped for idling, it's placed after the rate limits
+// so that a limited request never starts a plugin, it relies on the identifier set by FetchPluginInstallation
+func (app *App) ColdStartPlugin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity, ok := ctx.Value(constants.CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER).(plugin_entities.PluginUniqueIdentifier)
+		if !ok {
+			ctx.AbortWithStatusJSON(
+				500,
+				exception.InternalServerError(errors.New("plugin unique identifier not found")).ToResponse(),
+			)
+			return
+		}
+
+		if err := plugin_manager.Manager().ColdStart(identity); err != nil {
+			ctx.AbortWithStatusJSON(503, exception.ErrPluginUnavailable(err).ToResponse())
+			return
+		}
+		ctx.Next()
+	}
+}
+
 func (app *App) redirectPluginInvokeByPluginIdentifier(
 	ctx *gin.Context,
+	tenantId string,
 	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
 	originalError error,
 ) {
+	// a redirected request should never be redirected again, it may cause a loop
+	// once the cluster state is not synchronized between nodes
+	if isRedirectedRequest(ctx) {
+		ctx.AbortWithStatusJSON(
+			404,
+			exception.NotFoundError(
+				errors.New("plugin is not available on the redirected node, "+originalError.Error()),
+			).ToResponse(),
+		)
+		return
+	}
+
 	// try find the correct node
 	nodes, err := app.cluster.FetchPluginAvailableNodesById(plugin_unique_identifier.String())
 	if err != nil {
@@ -124,9 +206,13 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		return
 	}
 
-	// redirect to the correct node
-	nodeId := nodes[0]
-	statusCode, header, body, err := app.cluster.RedirectRequest(nodeId, ctx.Request)
+	// redirect to one of the available nodes, requests are balanced by tenant
+	app.redirectRequestToNodes(ctx, tenantId, nodes)
+}
+
+// redirectRequestToNodes redirects the request to one of the nodes and streams the response back
+func (app *App) redirectRequestToNodes(ctx *gin.Context, key string, nodes []string) {
+	statusCode, header, body, err := app.cluster.RedirectRequestToNodes(key, nodes, ctx.Request)
 	if err != nil {
 		log.Error("redirect request failed: %s", err.Error())
 		ctx.AbortWithStatusJSON(
@@ -135,38 +221,99 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		)
 		return
 	}
+	defer body.Close()
 
-	// set status code
-	ctx.Writer.WriteHeader(statusCode)
+	if err := cluster.WriteRedirectedResponse(ctx.Writer, statusCode, header, body); err != nil {
+		log.Warn("failed to stream redirected response: %s", err.Error())
+	}
+}
 
-	// set header
-	for key, values := range header {
-		for _, value := range values {
-			ctx.Writer.Header().Set(key, value)
+// RedirectPluginRuntimeRequest redirects requests about a plugin runtime, like its logs, to a node running it
+// if it's not running on current node, the plugin is identified by the plugin_unique_identifier query
+func (app *App) RedirectPluginRuntimeRequest() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		identity, err := plugin_entities.NewPluginUniqueIdentifier(ctx.Query("plugin_unique_identifier"))
+		if err != nil {
+			ctx.AbortWithStatusJSON(400, exception.UniqueIdentifierError(err).ToResponse())
+			return
+		}
+
+		// a redirected request is answered by current node anyway to avoid loops
+		if plugin_manager.Manager().Running(identity) || isRedirectedRequest(ctx) {
+			ctx.Next()
+			return
+		}
+
+		nodes, err := app.cluster.FetchPluginNodesById(identity.String())
+		if err != nil {
+			ctx.AbortWithStatusJSON(
+				500,
+				exception.InternalServerError(errors.New("failed to fetch plugin nodes, "+err.Error())).ToResponse(),
+			)
+			return
 		}
-	}
 
-	for {
-		buf := make([]byte, 1024)
-		n, err := body.Read(buf)
-		if err != nil && err != io.EOF {
-			break
-		} else if err != nil {
-			ctx.Writer.Write(buf[:n])
-			ctx.Writer.Flush()
-			break
+		// not running anywhere, current node answers with what it has
+		if len(nodes) == 0 {
+			ctx.Next()
+			return
 		}
 
-		if n > 0 {
-			ctx.Writer.Write(buf[:n])
-			ctx.Writer.Flush()
+		app.redirectRequestToNodes(ctx, identity.String(), nodes)
+		ctx.Abort()
+	}
+}
+
+// VerifyRedirectedRequest checks whether the request is redirected by another node of the cluster,
+// it's verified only once since the nonce of the request is single-use, handlers read the result by isRedirectedRequest
+func (app *App) VerifyRedirectedRequest() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		redirected := false
+		if ctx.GetHeader(cluster.X_PLUGIN_DAEMON_NODE_SIGNATURE) != "" {
+			redirected = app.cluster.IsRedirectedRequest(ctx.Request)
 		}
+		ctx.Set(constants.CONTEXT_KEY_REDIRECTED_REQUEST, redirected)
+		ctx.Next()
 	}
 }
 
+// isRedirectedRequest returns true if the request has been verified as redirected by VerifyRedirectedRequest
+func isRedirectedRequest(ctx *gin.Context) bool {
+	return ctx.GetBool(constants.CONTEXT_KEY_REDIRECTED_REQUEST)
+}
+
 func (app *App) InitClusterID() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Set(constants.CONTEXT_KEY_CLUSTER_ID, app.cluster.ID())
 		ctx.Next()
 	}
 }
+
+// Tracing extracts the w3c trace context from the incoming request and starts a server span,
+// handlers get the span through ctx.Request.Context()
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := tracing.Start(
+			tracing.Extract(c.Request.Context(), c.Request.Header),
+			c.Request.Method+" "+route,
+			trace.SpanKindServer,
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status code %d", status))
+		}
+	}
+}
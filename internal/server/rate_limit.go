@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limiter"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// checkRateLimit aborts the request with 429 if any of the subjects is limited,
+// requests are let through if the limiter is unavailable
+func (app *App) checkRateLimit(ctx *gin.Context, tenantId string, subjects ...rate_limiter.Subject) bool {
+	if app.rateLimiter == nil {
+		return true
+	}
+
+	decision, err := app.rateLimiter.Allow(tenantId, subjects...)
+	if err != nil {
+		log.With(log.FIELD_TENANT_ID, tenantId).Warn("failed to check rate limit: %s", err.Error())
+		return true
+	}
+
+	if !decision.Allowed {
+		ctx.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(decision.RetryAfter.Seconds()))))
+		ctx.AbortWithStatusJSON(
+			429,
+			exception.RateLimitedError(decision.LimitedBy.String(), decision.RetryAfter).ToResponse(),
+		)
+		return false
+	}
+
+	return true
+}
+
+// RateLimitDispatch limits dispatch requests by tenant, plugin and action,
+// it relies on the installation set by FetchPluginInstallation
+func (app *App) RateLimitDispatch(action access_types.PluginAccessAction) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tenantId := ctx.Param("tenant_id")
+		subjects := []rate_limiter.Subject{
+			{Dimension: rate_limiter.DIMENSION_TENANT, ID: tenantId},
+			{Dimension: rate_limiter.DIMENSION_ACTION, ID: string(action)},
+		}
+
+		if installation, ok := ctx.Value(constants.CONTEXT_KEY_PLUGIN_INSTALLATION).(models.PluginInstallation); ok {
+			subjects = append(subjects, rate_limiter.Subject{
+				Dimension: rate_limiter.DIMENSION_PLUGIN,
+				ID:        installation.PluginID,
+			})
+		}
+
+		if app.checkRateLimit(ctx, tenantId, subjects...) {
+			ctx.Next()
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package server
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/getsentry/sentry-go"
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limiter"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss/local"
 	"github.com/langgenius/dify-plugin-daemon/internal/oss/s3"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 )
 
 func initOSS(config *app.Config) oss.OSS {
@@ -51,20 +60,46 @@ func (app *App) Run(config *app.Config) {
 		routine.InitPool(config.RoutinePoolSize)
 	}
 
+	// init tracing
+	shutdownTracing := func(context.Context) error { return nil }
+	if config.TracingEnabled {
+		shutdown, err := tracing.Init(tracing.Options{
+			Exporter:     config.TracingExporter,
+			OTLPEndpoint: config.TracingOTLPEndpoint,
+			OTLPInsecure: config.TracingOTLPInsecure,
+			SampleRate:   *config.TracingSampleRate,
+		})
+		if err != nil {
+			log.Panic("failed to init tracing: %s", err)
+		}
+		shutdownTracing = shutdown
+	}
+
 	// init db
 	db.Init(config)
 
 	// init oss
 	oss := initOSS(config)
 
+	// keep recent plugin output in memory
+	plugin_logs.SetCapacity(config.PluginLogBufferSize)
+
 	// create manager
 	manager := plugin_manager.InitGlobalManager(oss, config)
 
 	// create cluster
 	app.cluster = cluster.NewCluster(config, manager)
 
+	// attach node id to all logs of current node
+	log.SetNodeID(app.cluster.ID())
+
 	// register plugin lifetime event
 	manager.AddPluginRegisterHandler(app.cluster.RegisterPlugin)
+	manager.AddPluginDrainHandler(app.cluster.PublishPluginState)
+
+	// only launch local plugins placed onto current node
+	manager.SetLocalPluginScheduler(app.cluster.IsPluginPlacedOnCurrentNode)
+	manager.SetLocalPluginPlacer(app.cluster.PlaceInstalledPlugin)
 
 	// init manager
 	manager.Launch(config)
@@ -75,9 +110,31 @@ func (app *App) Run(config *app.Config) {
 	// launch cluster
 	app.cluster.Launch()
 
+	// report runtime and cluster states
+	metrics.SetRuntimeSource(manager.Runtimes)
+	metrics.SetClusterSource(app.cluster)
+
+	// init rate limiter
+	if config.RateLimitEnabled != nil && *config.RateLimitEnabled {
+		rules, err := config.RateLimitRules()
+		if err != nil {
+			log.Panic("failed to parse rate limit rules: %s", err)
+		}
+		app.rateLimiter = rate_limiter.NewLimiter(rules)
+	}
+
 	// start http server
 	app.server(config)
 
-	// block
-	select {}
+	// block until the daemon is asked to exit
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+
+	// flush spans of the last seconds, the exporter connection is closed as well
+	ctx, cancel := context.WithTimeout(context.Background(), tracing.SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to shutdown tracing: %s", err)
+	}
 }
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	API_CREDENTIAL_KEY_PREFIX = "dpk-"
+)
+
+var (
+	ErrApiCredentialNotUsable = errors.New("api credential is revoked or expired")
+)
+
+type apiCredentialWithKey struct {
+	models.ApiCredential
+	// plaintext key, only returned once
+	Key string `json:"key"`
+}
+
+func HashApiCredentialKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func generateApiCredentialKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return API_CREDENTIAL_KEY_PREFIX + hex.EncodeToString(buf), nil
+}
+
+func apiCredentialCacheKey(keyHash string) []db.KeyValuePair {
+	return []db.KeyValuePair{{Key: "key_hash", Val: keyHash}}
+}
+
+func invalidateApiCredentialCache(credential *models.ApiCredential) error {
+	keys := [][]db.KeyValuePair{apiCredentialCacheKey(credential.KeyHash)}
+	if credential.PreviousKeyHash != "" {
+		keys = append(keys, apiCredentialCacheKey(credential.PreviousKeyHash))
+	}
+	return db.InvalidateCache[models.ApiCredential](keys...)
+}
+
+// AuthenticateApiCredential returns the credential which the key belongs to,
+// db.ErrDatabaseNotFound or ErrApiCredentialNotUsable is returned if the key is not acceptable
+func AuthenticateApiCredential(key string) (*models.ApiCredential, error) {
+	keyHash := HashApiCredentialKey(key)
+
+	credential, err := db.GetCache(&db.GetCachePayload[models.ApiCredential]{
+		Getter: func() (*models.ApiCredential, error) {
+			credential, err := db.GetOne[models.ApiCredential](
+				db.WhereSQL("key_hash = ? OR previous_key_hash = ?", keyHash, keyHash),
+			)
+			if err != nil {
+				return nil, err
+			}
+			return &credential, nil
+		},
+		CacheKey: apiCredentialCacheKey(keyHash),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !credential.Usable(keyHash, time.Now()) {
+		return nil, ErrApiCredentialNotUsable
+	}
+
+	return credential, nil
+}
+
+func validateApiCredentialBindings(tenants []string, scopes []models.ApiCredentialScope) error {
+	if len(tenants) == 0 {
+		return errors.New("at least one tenant is required")
+	}
+
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	return nil
+}
+
+func CreateApiCredential(
+	name string,
+	tenants []string,
+	scopes []models.ApiCredentialScope,
+	expiredAt *time.Time,
+) *entities.Response {
+	if err := validateApiCredentialBindings(tenants, scopes); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	if expiredAt != nil && expiredAt.Before(time.Now()) {
+		return exception.BadRequestError(errors.New("expired_at is in the past")).ToResponse()
+	}
+
+	key, err := generateApiCredentialKey()
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to generate key: %v", err)).ToResponse()
+	}
+
+	credential := models.ApiCredential{
+		Name:      name,
+		KeyPrefix: key[:len(API_CREDENTIAL_KEY_PREFIX)+8],
+		KeyHash:   HashApiCredentialKey(key),
+		Tenants:   tenants,
+		Scopes:    scopes,
+		ExpiredAt: expiredAt,
+	}
+
+	if err := db.Create(&credential); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to create api credential: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(apiCredentialWithKey{
+		ApiCredential: credential,
+		Key:           key,
+	})
+}
+
+func ListApiCredentials(page int, page_size int) *entities.Response {
+	credentials, err := db.GetAll[models.ApiCredential](
+		db.OrderBy("created_at", true),
+		db.Page(page, page_size),
+	)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to list api credentials: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(credentials)
+}
+
+// RotateApiCredential issues a new key for the credential, the current key keeps working
+// during the grace period so that callers are able to switch without downtime
+func RotateApiCredential(credential_id string, gracePeriod time.Duration) *entities.Response {
+	credential, err := db.GetOne[models.ApiCredential](
+		db.Equal("id", credential_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("api credential not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to find api credential: %v", err)).ToResponse()
+	}
+
+	if credential.RevokedAt != nil {
+		return exception.BadRequestError(errors.New("api credential has been revoked")).ToResponse()
+	}
+
+	key, err := generateApiCredentialKey()
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to generate key: %v", err)).ToResponse()
+	}
+
+	stale := credential
+	previousKeyExpiredAt := time.Now().Add(gracePeriod)
+	credential.PreviousKeyHash = credential.KeyHash
+	credential.PreviousKeyExpiredAt = &previousKeyExpiredAt
+	credential.KeyHash = HashApiCredentialKey(key)
+	credential.KeyPrefix = key[:len(API_CREDENTIAL_KEY_PREFIX)+8]
+
+	if err := db.Update(&credential); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to rotate api credential: %v", err)).ToResponse()
+	}
+
+	// drop cached entries of both the current and the previous key
+	if err := invalidateApiCredentialCache(&stale); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to invalidate cache: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(apiCredentialWithKey{
+		ApiCredential: credential,
+		Key:           key,
+	})
+}
+
+func RevokeApiCredential(credential_id string) *entities.Response {
+	credential, err := db.GetOne[models.ApiCredential](
+		db.Equal("id", credential_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("api credential not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to find api credential: %v", err)).ToResponse()
+	}
+
+	if credential.RevokedAt != nil {
+		return entities.NewSuccessResponse(true)
+	}
+
+	now := time.Now()
+	credential.RevokedAt = &now
+	if err := db.Update(&credential); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to revoke api credential: %v", err)).ToResponse()
+	}
+
+	// invalidate after updating, otherwise a concurrent lookup may cache the unrevoked credential
+	if err := invalidateApiCredentialCache(&credential); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to invalidate cache: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
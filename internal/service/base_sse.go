@@ -10,7 +10,10 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // baseSSEService is a helper function to handle SSE service
@@ -20,6 +23,9 @@ func baseSSEService[R any](
 	ctx *gin.Context,
 	max_timeout_seconds int,
 ) {
+	_, span := tracing.Start(ctx.Request.Context(), "sse.stream", trace.SpanKindInternal)
+	defer span.End()
+
 	writer := ctx.Writer
 	writer.WriteHeader(200)
 	writer.Header().Set("Content-Type", "text/event-stream")
@@ -41,7 +47,9 @@ func baseSSEService[R any](
 	pluginDaemonResponse, err := generator()
 
 	if err != nil {
-		writeData(exception.InternalServerError(err).ToResponse())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		writeData(sessionError(err).ToResponse())
 		close(done)
 		return
 	}
@@ -73,11 +81,13 @@ func baseSSEService[R any](
 
 	select {
 	case <-writer.CloseNotify():
+		span.AddEvent("client disconnected")
 		pluginDaemonResponse.Close()
 		return
 	case <-done:
 		return
 	case <-timer.C:
+		span.SetStatus(codes.Error, "killed by timeout")
 		writeData(exception.InternalServerError(errors.New("killed by timeout")).ToResponse())
 		if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
 			close(done)
@@ -1,25 +1,75 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 )
 
-func GetRemoteDebuggingKey(tenant_id string) *entities.Response {
+func GetRemoteDebuggingKey(tenant_id string, user_id string, ttl time.Duration, max_sessions int) *entities.Response {
 	type response struct {
-		Key string `json:"key"`
+		Key       string    `json:"key"`
+		KeyID     string    `json:"key_id"`
+		ExpiredAt time.Time `json:"expired_at"`
 	}
 
 	key, err := debugging_runtime.GetConnectionKey(debugging_runtime.ConnectionInfo{
-		TenantId: tenant_id,
-	})
+		TenantId:    tenant_id,
+		UserId:      user_id,
+		MaxSessions: max_sessions,
+	}, ttl)
 
 	if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
 	return entities.NewSuccessResponse(response{
-		Key: key,
+		Key:       key.Key,
+		KeyID:     key.KeyId,
+		ExpiredAt: key.ExpiredAt,
 	})
 }
+
+func ListRemoteDebuggingKeys(tenant_id string) *entities.Response {
+	keys, err := debugging_runtime.ListConnectionKeys(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to list debugging keys: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(keys)
+}
+
+// RevokeRemoteDebuggingKey invalidates the key, plugins attached with it are disconnected
+func RevokeRemoteDebuggingKey(tenant_id string, key_id string) *entities.Response {
+	err := debugging_runtime.RevokeConnectionKey(tenant_id, key_id)
+	if err == debugging_runtime.ErrConnectionKeyNotFound {
+		return exception.NotFoundError(err).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to revoke debugging key: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func ListRemoteDebuggingAudits(tenant_id string, key_id string, page int, page_size int) *entities.Response {
+	query := []db.GenericQuery{
+		db.Equal("tenant_id", tenant_id),
+	}
+	if key_id != "" {
+		query = append(query, db.Equal("key_id", key_id))
+	}
+	query = append(query, db.OrderBy("created_at", true), db.Page(page, page_size))
+
+	audits, err := db.GetAll[models.PluginDebuggingAudit](query...)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to list debugging audits: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(audits)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
@@ -23,6 +24,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
@@ -39,7 +41,7 @@ func copyRequest(req *http.Request, hookId string, path string) (*bytes.Buffer,
 	newReq.URL.RawQuery = queryParams.Encode()
 
 	// read request body until complete, max 10MB
-	body, err := io.ReadAll(io.LimitReader(req.Body, 10*1024*1024))
+	body, err := io.ReadAll(io.LimitReader(req.Body, ENDPOINT_MAX_BODY_SIZE))
 	if err != nil {
 		return nil, err
 	}
@@ -103,11 +105,21 @@ func Endpoint(
 	// fetch plugin
 	manager := plugin_manager.Manager()
 	runtime, err := manager.Get(identifier)
+	if errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		ctx.JSON(503, exception.ErrPluginUnavailable(err).ToResponse())
+		return
+	}
 	if err != nil {
 		ctx.JSON(404, exception.ErrPluginNotFound().ToResponse())
 		return
 	}
 
+	// start the plugin if it's stopped for idling
+	if err := manager.ColdStart(identifier); err != nil {
+		ctx.JSON(503, exception.ErrPluginUnavailable(err).ToResponse())
+		return
+	}
+
 	// fetch endpoint declaration
 	endpointDeclaration := runtime.Configuration().Endpoint
 	if endpointDeclaration == nil {
@@ -148,6 +160,7 @@ func Endpoint(
 			BackwardsInvocation:    manager.BackwardsInvocation(),
 			IgnoreCache:            false,
 			EndpointID:             &endpoint.ID,
+			TraceContext:           tracing.Carrier(ctx.Request.Context()),
 		},
 	)
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -162,6 +175,10 @@ func Endpoint(
 			Settings:       settings,
 		},
 	)
+	if errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		ctx.JSON(503, exception.ErrPluginUnavailable(err).ToResponse())
+		return
+	}
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
 		return
@@ -245,6 +262,13 @@ func ListEndpoints(tenant_id string, page int, page_size int) *entities.Response
 
 	// decrypt settings
 	for i, endpoint := range endpoints {
+		if err := DecryptEndpointAuth(&endpoint); err != nil {
+			return exception.InternalServerError(
+				fmt.Errorf("failed to decrypt auth: %v", err),
+			).ToResponse()
+		}
+		endpoint.Auth = MaskEndpointAuth(endpoint.Auth)
+
 		pluginInstallation, err := db.GetOne[models.PluginInstallation](
 			db.Equal("plugin_id", endpoint.PluginID),
 			db.Equal("tenant_id", tenant_id),
@@ -339,6 +363,13 @@ func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size
 
 	// decrypt settings
 	for i, endpoint := range endpoints {
+		if err := DecryptEndpointAuth(&endpoint); err != nil {
+			return exception.InternalServerError(
+				fmt.Errorf("failed to decrypt auth: %v", err),
+			).ToResponse()
+		}
+		endpoint.Auth = MaskEndpointAuth(endpoint.Auth)
+
 		// get installation
 		pluginInstallation, err := db.GetOne[models.PluginInstallation](
 			db.Equal("plugin_id", plugin_id),
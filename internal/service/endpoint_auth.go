@@ -0,0 +1,261 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var (
+	ErrEndpointExpired          = errors.New("endpoint expired")
+	ErrEndpointIPNotAllowed     = errors.New("client ip is not allowed to access the endpoint")
+	ErrEndpointSecretMismatch   = errors.New("endpoint secret mismatch")
+	ErrEndpointSignatureInvalid = errors.New("endpoint signature invalid")
+)
+
+const (
+	// max body size accepted by endpoints, 10MB
+	ENDPOINT_MAX_BODY_SIZE = 10 * 1024 * 1024
+
+	// secrets of guards are encrypted apart from settings, under the endpoint id with this suffix
+	ENDPOINT_AUTH_ENCRYPT_IDENTITY_SUFFIX = ":auth"
+
+	// max endpoints whose decrypted guards are kept in memory
+	ENDPOINT_AUTH_CACHE_SIZE = 4096
+)
+
+// decrypted guards keyed by endpoint id, an entry is used only while the endpoint is not updated since,
+// so that public requests do not ask dify to decrypt the secrets every time
+var decryptedEndpointAuths, _ = lru.New[string, decryptedEndpointAuth](ENDPOINT_AUTH_CACHE_SIZE)
+
+type decryptedEndpointAuth struct {
+	updatedAt time.Time
+	auth      *models.EndpointAuth
+}
+
+// secrets of guards are encrypted and masked the same way as secret-input settings
+var endpointAuthSecretConfigs = []plugin_entities.ProviderConfig{
+	{Name: "secret", Type: plugin_entities.CONFIG_TYPE_SECRET_INPUT},
+	{Name: "signature_secret", Type: plugin_entities.CONFIG_TYPE_SECRET_INPUT},
+}
+
+// CheckEndpointExpiry returns ErrEndpointExpired once the endpoint has expired
+func CheckEndpointExpiry(endpoint *models.Endpoint) error {
+	if !endpoint.ExpiredAt.IsZero() && time.Now().After(endpoint.ExpiredAt) {
+		return ErrEndpointExpired
+	}
+	return nil
+}
+
+// VerifyEndpointRequest checks the expiry and auth guards of the endpoint,
+// the body is restored if it was read to verify the signature
+func VerifyEndpointRequest(request *http.Request, clientIp string, endpoint *models.Endpoint) error {
+	if err := CheckEndpointExpiry(endpoint); err != nil {
+		return err
+	}
+
+	auth := endpoint.Auth
+	if auth == nil {
+		return nil
+	}
+
+	if len(auth.IPAllowlist) > 0 && !endpointIPAllowed(clientIp, auth.IPAllowlist) {
+		return ErrEndpointIPNotAllowed
+	}
+
+	if auth.SecretHeader != "" {
+		if subtle.ConstantTimeCompare(
+			[]byte(request.Header.Get(auth.SecretHeader)),
+			[]byte(auth.Secret),
+		) != 1 {
+			return ErrEndpointSecretMismatch
+		}
+	}
+
+	if auth.SignatureHeader != "" {
+		body, err := io.ReadAll(io.LimitReader(request.Body, ENDPOINT_MAX_BODY_SIZE))
+		if err != nil {
+			return err
+		}
+		request.Body.Close()
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !verifyEndpointSignature(body, request.Header.Get(auth.SignatureHeader), auth.SignatureSecret) {
+			return ErrEndpointSignatureInvalid
+		}
+	}
+
+	return nil
+}
+
+func endpointIPAllowed(clientIp string, allowlist []string) bool {
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return false
+	}
+
+	for _, entry := range allowlist {
+		network, err := models.ParseEndpointAllowedIP(entry)
+		if err != nil {
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func verifyEndpointSignature(body []byte, signature string, secret string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func endpointAuthSecrets(auth *models.EndpointAuth) map[string]any {
+	return map[string]any{
+		"secret":           auth.Secret,
+		"signature_secret": auth.SignatureSecret,
+	}
+}
+
+// withEndpointAuthSecrets returns a copy of auth with secrets replaced
+func withEndpointAuthSecrets(auth *models.EndpointAuth, secrets map[string]any) *models.EndpointAuth {
+	copied := *auth
+	copied.Secret, _ = secrets["secret"].(string)
+	copied.SignatureSecret, _ = secrets["signature_secret"].(string)
+	return &copied
+}
+
+func invokeEndpointAuthEncrypt(
+	tenant_id string,
+	user_id string,
+	endpoint_id string,
+	opt dify_invocation.EncryptOpt,
+	auth *models.EndpointAuth,
+) (*models.EndpointAuth, error) {
+	if auth == nil || (auth.Secret == "" && auth.SignatureSecret == "") {
+		return auth, nil
+	}
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return nil, errors.New("failed to get plugin manager")
+	}
+
+	secrets, err := manager.BackwardsInvocation().InvokeEncrypt(&dify_invocation.InvokeEncryptRequest{
+		BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
+			TenantId: tenant_id,
+			UserId:   user_id,
+			Type:     dify_invocation.INVOKE_TYPE_ENCRYPT,
+		},
+		InvokeEncryptSchema: dify_invocation.InvokeEncryptSchema{
+			Opt:       opt,
+			Namespace: dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT,
+			Identity:  endpoint_id + ENDPOINT_AUTH_ENCRYPT_IDENTITY_SUFFIX,
+			Data:      endpointAuthSecrets(auth),
+			Config:    endpointAuthSecretConfigs,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withEndpointAuthSecrets(auth, secrets), nil
+}
+
+// DecryptEndpointAuth replaces the stored guards of the endpoint with decrypted ones
+func DecryptEndpointAuth(endpoint *models.Endpoint) error {
+	if cached, ok := decryptedEndpointAuths.Get(endpoint.ID); ok && cached.updatedAt.Equal(endpoint.UpdatedAt) {
+		endpoint.Auth = cached.auth
+		return nil
+	}
+
+	auth, err := invokeEndpointAuthEncrypt(
+		endpoint.TenantID, "", endpoint.ID, dify_invocation.ENCRYPT_OPT_DECRYPT, endpoint.Auth,
+	)
+	if err != nil {
+		return err
+	}
+
+	decryptedEndpointAuths.Add(endpoint.ID, decryptedEndpointAuth{
+		updatedAt: endpoint.UpdatedAt,
+		auth:      auth,
+	})
+	endpoint.Auth = auth
+	return nil
+}
+
+// MaskEndpointAuth returns a copy of decrypted guards with secrets masked
+func MaskEndpointAuth(auth *models.EndpointAuth) *models.EndpointAuth {
+	if auth == nil {
+		return nil
+	}
+
+	return withEndpointAuthSecrets(
+		auth, encryption.MaskConfigCredentials(endpointAuthSecrets(auth), endpointAuthSecretConfigs),
+	)
+}
+
+// mergeMaskedEndpointAuth keeps the original secrets if the masked values are sent back unchanged
+func mergeMaskedEndpointAuth(auth *models.EndpointAuth, original *models.EndpointAuth) *models.EndpointAuth {
+	if auth == nil || original == nil {
+		return auth
+	}
+
+	masked := MaskEndpointAuth(original)
+	merged := *auth
+	if merged.Secret != "" && merged.Secret == masked.Secret {
+		merged.Secret = original.Secret
+	}
+	if merged.SignatureSecret != "" && merged.SignatureSecret == masked.SignatureSecret {
+		merged.SignatureSecret = original.SignatureSecret
+	}
+
+	return &merged
+}
+
+func clearEndpointAuthCache(tenant_id string, user_id string, endpoint_id string) error {
+	decryptedEndpointAuths.Remove(endpoint_id)
+
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return errors.New("failed to get plugin manager")
+	}
+
+	_, err := manager.BackwardsInvocation().InvokeEncrypt(&dify_invocation.InvokeEncryptRequest{
+		BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
+			TenantId: tenant_id,
+			UserId:   user_id,
+			Type:     dify_invocation.INVOKE_TYPE_ENCRYPT,
+		},
+		InvokeEncryptSchema: dify_invocation.InvokeEncryptSchema{
+			Opt:       dify_invocation.ENCRYPT_OPT_CLEAR,
+			Namespace: dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT,
+			Identity:  endpoint_id + ENDPOINT_AUTH_ENCRYPT_IDENTITY_SUFFIX,
+		},
+	})
+	return err
+}
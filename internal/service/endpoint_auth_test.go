@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func newEndpointRequest(t *testing.T, body string) *http.Request {
+	req, err := http.NewRequest("POST", "http://localhost:8080/e/hook/test", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestVerifyEndpointRequestExpired(t *testing.T) {
+	endpoint := &models.Endpoint{ExpiredAt: time.Now().Add(-time.Minute)}
+	if err := VerifyEndpointRequest(newEndpointRequest(t, ""), "127.0.0.1", endpoint); err != ErrEndpointExpired {
+		t.Fatalf("expected ErrEndpointExpired, got %v", err)
+	}
+
+	endpoint.ExpiredAt = time.Now().Add(time.Minute)
+	if err := VerifyEndpointRequest(newEndpointRequest(t, ""), "127.0.0.1", endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyEndpointRequestIPAllowlist(t *testing.T) {
+	endpoint := &models.Endpoint{
+		ExpiredAt: time.Now().Add(time.Minute),
+		Auth:      &models.EndpointAuth{IPAllowlist: []string{"10.0.0.0/8", "192.168.1.1"}},
+	}
+
+	for ip, allowed := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.1": true,
+		"192.168.1.2": false,
+		"invalid":     false,
+	} {
+		err := VerifyEndpointRequest(newEndpointRequest(t, ""), ip, endpoint)
+		if allowed && err != nil {
+			t.Fatalf("%s should be allowed, got %v", ip, err)
+		}
+		if !allowed && err != ErrEndpointIPNotAllowed {
+			t.Fatalf("%s should not be allowed, got %v", ip, err)
+		}
+	}
+}
+
+func TestVerifyEndpointRequestSecret(t *testing.T) {
+	endpoint := &models.Endpoint{
+		ExpiredAt: time.Now().Add(time.Minute),
+		Auth:      &models.EndpointAuth{SecretHeader: "X-Webhook-Secret", Secret: "secret"},
+	}
+
+	req := newEndpointRequest(t, "")
+	req.Header.Set("X-Webhook-Secret", "wrong")
+	if err := VerifyEndpointRequest(req, "127.0.0.1", endpoint); err != ErrEndpointSecretMismatch {
+		t.Fatalf("expected ErrEndpointSecretMismatch, got %v", err)
+	}
+
+	req.Header.Set("X-Webhook-Secret", "secret")
+	if err := VerifyEndpointRequest(req, "127.0.0.1", endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifyEndpointRequestSignature(t *testing.T) {
+	endpoint := &models.Endpoint{
+		ExpiredAt: time.Now().Add(time.Minute),
+		Auth:      &models.EndpointAuth{SignatureHeader: "X-Signature", SignatureSecret: "secret"},
+	}
+
+	body := `{"event":"push"}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	req := newEndpointRequest(t, body)
+	req.Header.Set("X-Signature", "sha256="+signature)
+	if err := VerifyEndpointRequest(req, "127.0.0.1", endpoint); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// body must still be readable by the plugin
+	restored, err := io.ReadAll(req.Body)
+	if err != nil || string(restored) != body {
+		t.Fatalf("body not restored: %q, %v", restored, err)
+	}
+
+	req = newEndpointRequest(t, `{"event":"tampered"}`)
+	req.Header.Set("X-Signature", signature)
+	if err := VerifyEndpointRequest(req, "127.0.0.1", endpoint); err != ErrEndpointSignatureInvalid {
+		t.Fatalf("expected ErrEndpointSignatureInvalid, got %v", err)
+	}
+}
+
+func TestMaskEndpointAuth(t *testing.T) {
+	original := &models.EndpointAuth{
+		SecretHeader:    "X-Webhook-Secret",
+		Secret:          "webhook-secret",
+		SignatureHeader: "X-Signature",
+		SignatureSecret: "signature-secret",
+	}
+
+	masked := MaskEndpointAuth(original)
+	if masked.Secret == original.Secret || masked.SignatureSecret == original.SignatureSecret {
+		t.Fatalf("secrets should be masked, got %v", masked)
+	}
+	if masked.SecretHeader != original.SecretHeader || original.Secret != "webhook-secret" {
+		t.Fatalf("only secrets of a copy should be masked, got %v", masked)
+	}
+
+	// masked secrets sent back are replaced with the original ones, changed ones are kept
+	masked.SignatureSecret = "rotated"
+	merged := mergeMaskedEndpointAuth(masked, original)
+	if merged.Secret != original.Secret || merged.SignatureSecret != "rotated" {
+		t.Fatalf("unexpected merged auth %v", merged)
+	}
+}
+
+func TestDecryptEndpointAuthCached(t *testing.T) {
+	updatedAt := time.Now()
+	decryptedEndpointAuths.Add("endpoint-cached", decryptedEndpointAuth{
+		updatedAt: updatedAt,
+		auth:      &models.EndpointAuth{SecretHeader: "X-Webhook-Secret", Secret: "webhook-secret"},
+	})
+	defer decryptedEndpointAuths.Remove("endpoint-cached")
+
+	endpoint := &models.Endpoint{
+		Model: models.Model{ID: "endpoint-cached", UpdatedAt: updatedAt},
+		Auth:  &models.EndpointAuth{SecretHeader: "X-Webhook-Secret", Secret: "encrypted"},
+	}
+	if err := DecryptEndpointAuth(endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Auth.Secret != "webhook-secret" {
+		t.Fatalf("expected cached secret, got %s", endpoint.Auth.Secret)
+	}
+
+	// an updated endpoint is decrypted again, no plugin manager is available here
+	endpoint = &models.Endpoint{
+		Model: models.Model{ID: "endpoint-cached", UpdatedAt: updatedAt.Add(time.Second)},
+		Auth:  &models.EndpointAuth{SecretHeader: "X-Webhook-Secret", Secret: "encrypted"},
+	}
+	if err := DecryptEndpointAuth(endpoint); err == nil {
+		t.Fatal("expected the guards of an updated endpoint to be decrypted again")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/metrics"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -163,6 +164,11 @@ func InstallPluginRuntimeToTenant(
 
 					modifier(taskPointer, pluginStatus)
 
+					if pluginStatus.Status == models.InstallTaskStatusSuccess ||
+						pluginStatus.Status == models.InstallTaskStatusFailed {
+						metrics.ObservePluginInstallation(string(pluginStatus.Status))
+					}
+
 					successes := 0
 					for _, plugin := range taskPointer.Plugins {
 						if plugin.Status == models.InstallTaskStatusSuccess {
@@ -75,15 +75,20 @@ func UninstallPlugin(
 }
 
 // setup a plugin to db,
+// InstallEndpoint creates an enabled endpoint, settings and auth are encrypted under endpoint_id by the caller
 func InstallEndpoint(
+	endpoint_id string,
 	plugin_id plugin_entities.PluginUniqueIdentifier,
 	installation_id string,
 	tenant_id string,
 	user_id string,
 	name string,
 	settings map[string]any,
+	expired_at *time.Time,
+	auth *models.EndpointAuth,
 ) (*models.Endpoint, error) {
 	installation := &models.Endpoint{
+		Model:     models.Model{ID: endpoint_id},
 		HookID:    strings.RandomLowercaseString(16),
 		PluginID:  plugin_id.PluginID(),
 		TenantID:  tenant_id,
@@ -92,6 +97,10 @@ func InstallEndpoint(
 		Enabled:   true,
 		ExpiredAt: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
 		Settings:  settings,
+		Auth:      auth,
+	}
+	if expired_at != nil {
+		installation.ExpiredAt = *expired_at
 	}
 
 	if err := db.WithTransaction(func(tx *gorm.DB) error {
@@ -5,7 +5,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
-	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -22,10 +21,10 @@ func InvokeAgentStrategy(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_AGENT_STRATEGY,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
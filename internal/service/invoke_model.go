@@ -5,7 +5,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
-	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -22,10 +21,10 @@ func InvokeLLM(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -51,9 +50,9 @@ func InvokeTextEmbedding(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TEXT_EMBEDDING,
-		ctx.GetString("cluster_id"))
+		ctx)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -79,10 +78,10 @@ func InvokeRerank(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_RERANK,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -108,10 +107,10 @@ func InvokeTTS(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TTS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -137,10 +136,10 @@ func InvokeSpeech2Text(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_SPEECH2TEXT,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -166,10 +165,10 @@ func InvokeModeration(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_MODERATION,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -195,10 +194,10 @@ func ValidateProviderCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_PROVIDER_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -224,10 +223,10 @@ func ValidateModelCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_MODEL_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -252,10 +251,10 @@ func GetTTSModelVoices(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TTS_MODEL_VOICES,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -280,10 +279,10 @@ func GetTextEmbeddingNumTokens(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TEXT_EMBEDDING_NUM_TOKENS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -308,10 +307,10 @@ func GetAIModelSchema(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_AI_MODEL_SCHEMAS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -336,10 +335,10 @@ func GetLLMNumTokens(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_MODEL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_LLM_NUM_TOKENS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -5,7 +5,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
-	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
@@ -22,10 +21,10 @@ func InvokeTool(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -51,10 +50,10 @@ func ValidateToolCredentials(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_TOOL_CREDENTIALS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -80,10 +79,10 @@ func GetToolRuntimeParameters(
 		r,
 		access_types.PLUGIN_ACCESS_TYPE_TOOL,
 		access_types.PLUGIN_ACCESS_ACTION_GET_TOOL_RUNTIME_PARAMETERS,
-		ctx.GetString("cluster_id"),
+		ctx,
 	)
 	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		ctx.JSON(500, sessionError(err).ToResponse())
 		return
 	}
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/egress_proxy"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// declaredNetworkDomains returns the domains declared by the version of the plugin the tenant installed
+func declaredNetworkDomains(tenant_id string, plugin_id string) ([]string, error) {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	declaration, err := helper.CombinedGetPluginDeclaration(
+		pluginUniqueIdentifier,
+		plugin_entities.PluginRuntimeType(installation.RuntimeType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := declaration.Resource.Permission.NetworkDomains()
+	if domains == nil {
+		domains = []string{}
+	}
+	return domains, nil
+}
+
+// GetPluginNetworkApproval returns the domains declared by the plugin and the ones approved by the tenant
+func GetPluginNetworkApproval(tenant_id string, plugin_id string) *entities.Response {
+	declared, err := declaredNetworkDomains(tenant_id, plugin_id)
+	if errors.Is(err, db.ErrDatabaseNotFound) {
+		return exception.NotFoundError(errors.New("plugin installation not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	approved, err := egress_proxy.ApprovedDomains(tenant_id, plugin_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if approved == nil {
+		approved = []string{}
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"declared": declared,
+		"approved": approved,
+	})
+}
+
+// ApprovePluginNetworkDomains replaces the domains the tenant allows the plugin to access,
+// only declared domains are accepted, an empty list revokes all of them
+func ApprovePluginNetworkDomains(tenant_id string, plugin_id string, domains []string) *entities.Response {
+	declared, err := declaredNetworkDomains(tenant_id, plugin_id)
+	if errors.Is(err, db.ErrDatabaseNotFound) {
+		return exception.NotFoundError(errors.New("plugin installation not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	for _, domain := range domains {
+		if !slices.Contains(declared, domain) {
+			return exception.BadRequestError(fmt.Errorf("domain %s is not declared by the plugin", domain)).ToResponse()
+		}
+	}
+
+	approval, err := db.GetOne[models.PluginNetworkApproval](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_id),
+	)
+	if errors.Is(err, db.ErrDatabaseNotFound) {
+		approval = models.PluginNetworkApproval{
+			TenantID: tenant_id,
+			PluginID: plugin_id,
+			Domains:  domains,
+		}
+		err = db.Create(&approval)
+	} else if err == nil {
+		approval.Domains = domains
+		err = db.Update(&approval)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if err := egress_proxy.InvalidateApproval(tenant_id, plugin_id); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(approval)
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_logs"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// GetPluginLogs returns the latest stdout/stderr lines captured on current node,
+// requests are redirected to a node running the plugin beforehand,
+// the lines are shared by all tenants using the plugin
+func GetPluginLogs(plugin_unique_identifier string, limit int) *entities.Response {
+	return entities.NewSuccessResponse(map[string]any{
+		"logs": plugin_logs.Get(plugin_unique_identifier, limit),
+	})
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// GetPluginRuntimeState returns the status, restarts and last exit errors of the plugin on current node,
+// requests are redirected to a node running the plugin beforehand
+func GetPluginRuntimeState(plugin_unique_identifier string) *entities.Response {
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(plugin_unique_identifier)
+	if err != nil {
+		return exception.UniqueIdentifierError(err).ToResponse()
+	}
+
+	for _, runtime := range plugin_manager.Manager().Runtimes() {
+		identity, err := runtime.Identity()
+		if err != nil || identity != identifier {
+			continue
+		}
+
+		state := runtime.RuntimeState()
+		return entities.NewSuccessResponse(map[string]any{
+			"status":      state.Status,
+			"restarts":    state.Restarts,
+			"exit_errors": state.ExitErrors,
+		})
+	}
+
+	return exception.NotFoundError(errors.New("plugin is not running on current node")).ToResponse()
+}
+
+// ReleasePluginQuarantine restarts the plugin on every node it's quarantined on
+func ReleasePluginQuarantine(plugin_unique_identifier string) *entities.Response {
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(plugin_unique_identifier)
+	if err != nil {
+		return exception.UniqueIdentifierError(err).ToResponse()
+	}
+
+	if err := plugin_manager.Manager().PublishQuarantineRelease(identifier); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limiter"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// GetRateLimitUsage returns usage counters of the tenant on date (YYYY-MM-DD, utc), today if empty
+func GetRateLimitUsage(tenant_id string, date string) *entities.Response {
+	day := time.Now().UTC()
+	if date != "" {
+		parsed, err := time.Parse(rate_limiter.RATE_LIMIT_USAGE_DATE_FORMAT, date)
+		if err != nil {
+			return exception.BadRequestError(fmt.Errorf("invalid date: %v", err)).ToResponse()
+		}
+		day = parsed
+	}
+
+	usage, err := rate_limiter.GetUsage(tenant_id, day)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to get rate limit usage: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"date":  day.Format(rate_limiter.RATE_LIMIT_USAGE_DATE_FORMAT),
+		"usage": usage,
+	})
+}
@@ -3,9 +3,13 @@ package service
 import (
 	"errors"
 
+	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -13,7 +17,7 @@ func createSession[T any](
 	r *plugin_entities.InvokePluginRequest[T],
 	access_type access_types.PluginAccessType,
 	access_action access_types.PluginAccessAction,
-	cluster_id string,
+	ctx *gin.Context,
 ) (*session_manager.Session, error) {
 	manager := plugin_manager.Manager()
 	if manager == nil {
@@ -23,6 +27,9 @@ func createSession[T any](
 	// try fetch plugin identifier from plugin id
 
 	runtime, err := manager.Get(r.UniqueIdentifier)
+	if errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, errors.New("failed to get plugin runtime")
 	}
@@ -32,7 +39,7 @@ func createSession[T any](
 			TenantID:               r.TenantId,
 			UserID:                 r.UserId,
 			PluginUniqueIdentifier: r.UniqueIdentifier,
-			ClusterID:              cluster_id,
+			ClusterID:              ctx.GetString("cluster_id"),
 			InvokeFrom:             access_type,
 			Action:                 access_action,
 			Declaration:            runtime.Configuration(),
@@ -42,9 +49,18 @@ func createSession[T any](
 			MessageID:              r.MessageID,
 			AppID:                  r.AppID,
 			EndpointID:             r.EndpointID,
+			TraceContext:           tracing.Carrier(ctx.Request.Context()),
 		},
 	)
 
 	session.BindRuntime(runtime)
 	return session, nil
 }
+
+// sessionError converts errors of createSession into responses
+func sessionError(err error) exception.PluginDaemonError {
+	if errors.Is(err, plugin_errors.ErrPluginUnavailable) {
+		return exception.ErrPluginUnavailable(err)
+	}
+	return exception.InternalServerError(err)
+}
@@ -3,7 +3,9 @@ package service
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
@@ -22,7 +24,13 @@ func SetupEndpoint(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
 	name string,
 	settings map[string]any,
+	expired_at *time.Time,
+	auth *models.EndpointAuth,
 ) *entities.Response {
+	if err := validateEndpointGuards(expired_at, auth); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
 	// try find plugin installation
 	installation, err := db.GetOne[models.PluginInstallation](
 		db.Equal("tenant_id", tenant_id),
@@ -55,16 +63,15 @@ func SetupEndpoint(
 		return exception.BadRequestError(fmt.Errorf("failed to validate settings: %v", err)).ToResponse()
 	}
 
-	endpoint, err := install_service.InstallEndpoint(
-		pluginUniqueIdentifier,
-		installation.ID,
-		tenant_id,
-		user_id,
-		name,
-		map[string]any{},
+	// secrets are encrypted under the id of the endpoint before it's created, the endpoint is written
+	// along with its encrypted settings and guards at once, it's never served without its guards
+	endpointId := uuid.New().String()
+
+	encryptedAuth, err := invokeEndpointAuthEncrypt(
+		tenant_id, user_id, endpointId, dify_invocation.ENCRYPT_OPT_ENCRYPT, auth,
 	)
 	if err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to setup endpoint: %v", err)).ToResponse()
+		return exception.InternalServerError(fmt.Errorf("failed to encrypt auth: %v", err)).ToResponse()
 	}
 
 	manager := plugin_manager.Manager()
@@ -83,7 +90,7 @@ func SetupEndpoint(
 			InvokeEncryptSchema: dify_invocation.InvokeEncryptSchema{
 				Opt:       dify_invocation.ENCRYPT_OPT_ENCRYPT,
 				Namespace: dify_invocation.ENCRYPT_NAMESPACE_ENDPOINT,
-				Identity:  endpoint.ID,
+				Identity:  endpointId,
 				Data:      settings,
 				Config:    pluginDeclaration.Endpoint.Settings,
 			},
@@ -94,8 +101,18 @@ func SetupEndpoint(
 		return exception.InternalServerError(fmt.Errorf("failed to encrypt settings: %v", err)).ToResponse()
 	}
 
-	if err := install_service.UpdateEndpoint(endpoint, name, encryptedSettings); err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to update endpoint: %v", err)).ToResponse()
+	if _, err := install_service.InstallEndpoint(
+		endpointId,
+		pluginUniqueIdentifier,
+		installation.ID,
+		tenant_id,
+		user_id,
+		name,
+		encryptedSettings,
+		expired_at,
+		encryptedAuth,
+	); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to setup endpoint: %v", err)).ToResponse()
 	}
 
 	return entities.NewSuccessResponse(true)
@@ -136,10 +153,33 @@ func RemoveEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 		return exception.InternalServerError(fmt.Errorf("failed to clear credentials cache: %v", err)).ToResponse()
 	}
 
+	if err := clearEndpointAuthCache(tenant_id, "", endpoint.ID); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to clear credentials cache: %v", err)).ToResponse()
+	}
+
 	return entities.NewSuccessResponse(true)
 }
 
-func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name string, settings map[string]any) *entities.Response {
+// UpdateEndpoint keeps the current expiry and guards if they are not specified,
+// guards are removed only if clear_auth is set
+func UpdateEndpoint(
+	endpoint_id string,
+	tenant_id string,
+	user_id string,
+	name string,
+	settings map[string]any,
+	expired_at *time.Time,
+	auth *models.EndpointAuth,
+	clear_auth bool,
+) *entities.Response {
+	if clear_auth && auth != nil {
+		return exception.BadRequestError(errors.New("auth and clear_auth cannot be set together")).ToResponse()
+	}
+
+	if err := validateEndpointGuards(expired_at, auth); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
 	// get endpoint
 	endpoint, err := db.GetOne[models.Endpoint](
 		db.Equal("id", endpoint_id),
@@ -252,6 +292,32 @@ func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name s
 		return exception.InternalServerError(fmt.Errorf("failed to encrypt settings: %v", err)).ToResponse()
 	}
 
+	if auth != nil {
+		// masked secrets are sent back if they are not changed
+		originalAuth, err := invokeEndpointAuthEncrypt(
+			tenant_id, user_id, endpoint.ID, dify_invocation.ENCRYPT_OPT_DECRYPT, endpoint.Auth,
+		)
+		if err != nil {
+			return exception.InternalServerError(fmt.Errorf("failed to decrypt auth: %v", err)).ToResponse()
+		}
+
+		encryptedAuth, err := invokeEndpointAuthEncrypt(
+			tenant_id, user_id, endpoint.ID, dify_invocation.ENCRYPT_OPT_ENCRYPT,
+			mergeMaskedEndpointAuth(auth, originalAuth),
+		)
+		if err != nil {
+			return exception.InternalServerError(fmt.Errorf("failed to encrypt auth: %v", err)).ToResponse()
+		}
+		endpoint.Auth = encryptedAuth
+	} else if clear_auth {
+		endpoint.Auth = nil
+	}
+
+	// keep current expiry if not specified
+	if expired_at != nil {
+		endpoint.ExpiredAt = *expired_at
+	}
+
 	// update endpoint
 	if err := install_service.UpdateEndpoint(&endpoint, name, encryptedSettings); err != nil {
 		return exception.InternalServerError(fmt.Errorf("failed to update endpoint: %v", err)).ToResponse()
@@ -275,5 +341,23 @@ func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name s
 		return exception.InternalServerError(fmt.Errorf("failed to clear credentials cache: %v", err)).ToResponse()
 	}
 
+	if err := clearEndpointAuthCache(tenant_id, user_id, endpoint.ID); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to clear credentials cache: %v", err)).ToResponse()
+	}
+
 	return entities.NewSuccessResponse(true)
 }
+
+func validateEndpointGuards(expired_at *time.Time, auth *models.EndpointAuth) error {
+	if expired_at != nil && expired_at.Before(time.Now()) {
+		return errors.New("expired_at is in the past")
+	}
+
+	if auth != nil {
+		if err := auth.Validate(); err != nil {
+			return fmt.Errorf("invalid auth: %v", err)
+		}
+	}
+
+	return nil
+}
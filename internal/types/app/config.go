@@ -2,6 +2,9 @@ package app
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,6 +14,13 @@ type Config struct {
 	ServerPort uint16 `envconfig:"SERVER_PORT" validate:"required"`
 	ServerKey  string `envconfig:"SERVER_KEY" validate:"required"`
 
+	// accept SERVER_KEY on every tenant route, otherwise it is only used to manage api credentials
+	ServerKeyLegacyEnabled *bool `envconfig:"SERVER_KEY_LEGACY_ENABLED"`
+	// seconds the previous key of a rotated api credential keeps working, 0 revokes it at once
+	ApiCredentialRotationGracePeriod *int `envconfig:"API_CREDENTIAL_ROTATION_GRACE_PERIOD"`
+	// ips or CIDRs of reverse proxies whose X-Forwarded-For is trusted
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+
 	// dify inner api
 	DifyInnerApiURL string `envconfig:"DIFY_INNER_API_URL" validate:"required"`
 	DifyInnerApiKey string `envconfig:"DIFY_INNER_API_KEY" validate:"required"`
@@ -31,6 +41,20 @@ type Config struct {
 	PluginRemoteInstallingMaxSingleTenantConn int    `envconfig:"PLUGIN_REMOTE_INSTALLING_MAX_SINGLE_TENANT_CONN"`
 	PluginRemoteInstallServerEventLoopNums    int    `envconfig:"PLUGIN_REMOTE_INSTALL_SERVER_EVENT_LOOP_NUMS"`
 
+	// serve the debugging server over tls, client certificates signed by the client ca are required if it's set
+	PluginRemoteInstallingTLSEnabled      *bool  `envconfig:"PLUGIN_REMOTE_INSTALLING_TLS_ENABLED"`
+	PluginRemoteInstallingTLSCertFile     string `envconfig:"PLUGIN_REMOTE_INSTALLING_TLS_CERT_FILE"`
+	PluginRemoteInstallingTLSKeyFile      string `envconfig:"PLUGIN_REMOTE_INSTALLING_TLS_KEY_FILE"`
+	PluginRemoteInstallingTLSClientCAFile string `envconfig:"PLUGIN_REMOTE_INSTALLING_TLS_CLIENT_CA_FILE"`
+
+	// debugging keys are issued per developer and expire after the ttl in seconds,
+	// plugins attached with a key at the same time are capped if max sessions is greater than 0
+	PluginRemoteInstallingKeyTTL            int `envconfig:"PLUGIN_REMOTE_INSTALLING_KEY_TTL" validate:"min=0"`
+	PluginRemoteInstallingMaxSessionsPerKey int `envconfig:"PLUGIN_REMOTE_INSTALLING_MAX_SESSIONS_PER_KEY" validate:"min=0"`
+
+	// accept debugging plugins through websockets at /plugin/debugging/ws of the http server
+	PluginRemoteInstallingWebSocketEnabled *bool `envconfig:"PLUGIN_REMOTE_INSTALLING_WEBSOCKET_ENABLED"`
+
 	// plugin endpoint
 	PluginEndpointEnabled *bool `envconfig:"PLUGIN_ENDPOINT_ENABLED"`
 
@@ -44,9 +68,54 @@ type Config struct {
 	// request timeout
 	PluginMaxExecutionTimeout int `envconfig:"PLUGIN_MAX_EXECUTION_TIMEOUT" validate:"required"`
 
+	// seconds a plugin being uninstalled or upgraded waits for in-flight sessions before it's stopped
+	PluginDrainTimeout int `envconfig:"PLUGIN_DRAIN_TIMEOUT"`
+
+	// seconds before restarting a crashed local plugin, doubled on each crash up to the max
+	PluginRestartBackoffBase int `envconfig:"PLUGIN_RESTART_BACKOFF_BASE" validate:"min=0"`
+	PluginRestartBackoffMax  int `envconfig:"PLUGIN_RESTART_BACKOFF_MAX" validate:"min=0"`
+	// a plugin crashed threshold times within the window (seconds) is quarantined until released
+	PluginCrashLoopQuarantineEnabled *bool `envconfig:"PLUGIN_CRASH_LOOP_QUARANTINE_ENABLED"`
+	PluginCrashLoopThreshold         int   `envconfig:"PLUGIN_CRASH_LOOP_THRESHOLD" validate:"min=0"`
+	PluginCrashLoopWindow            int   `envconfig:"PLUGIN_CRASH_LOOP_WINDOW" validate:"min=0"`
+
 	// local launching max concurrent
 	PluginLocalLaunchingConcurrent int `envconfig:"PLUGIN_LOCAL_LAUNCHING_CONCURRENT" validate:"required"`
 
+	// how many nodes a local plugin runs on, 0 means every node of the cluster
+	PluginLocalReplicas int `envconfig:"PLUGIN_LOCAL_REPLICAS" validate:"min=0"`
+	// per-plugin override of replicas, formatted as `author/name:replicas,author/name:replicas`
+	PluginLocalReplicasOverride string `envconfig:"PLUGIN_LOCAL_REPLICAS_OVERRIDE"`
+
+	// worker processes of a local plugin on each node, the pool scales between min and max by in-flight sessions
+	PluginLocalWorkers    int `envconfig:"PLUGIN_LOCAL_WORKERS" validate:"min=0"`
+	PluginLocalMaxWorkers int `envconfig:"PLUGIN_LOCAL_MAX_WORKERS" validate:"min=0"`
+	// per-plugin override of workers, formatted as `author/name:min-max,author/name:workers`
+	PluginLocalWorkersOverride string `envconfig:"PLUGIN_LOCAL_WORKERS_OVERRIDE"`
+	// in-flight sessions per worker which starts one more worker
+	PluginLocalWorkerScaleUpSessions int `envconfig:"PLUGIN_LOCAL_WORKER_SCALE_UP_SESSIONS" validate:"min=0"`
+	// seconds an extra worker stays idle before it's stopped
+	PluginLocalWorkerIdleTimeout int `envconfig:"PLUGIN_LOCAL_WORKER_IDLE_TIMEOUT" validate:"min=0"`
+
+	// seconds a local plugin receives no request before its processes are stopped, 0 keeps them running,
+	// the plugin stays installed and is started again by the next request
+	PluginLocalIdleTimeout int `envconfig:"PLUGIN_LOCAL_IDLE_TIMEOUT" validate:"min=0"`
+	// seconds a request waits for an idle plugin to start
+	PluginLocalColdStartTimeout int `envconfig:"PLUGIN_LOCAL_COLD_START_TIMEOUT" validate:"min=0"`
+
+	// rate limiting, each limit is formatted as `rate/burst` where rate is requests per second,
+	// overrides are formatted as `id:rate/burst,id:rate/burst`, an empty limit means unlimited,
+	// plugin and action limits apply to each tenant apart
+	RateLimitEnabled          *bool  `envconfig:"RATE_LIMIT_ENABLED"`
+	RateLimitTenant           string `envconfig:"RATE_LIMIT_TENANT"`
+	RateLimitTenantOverride   string `envconfig:"RATE_LIMIT_TENANT_OVERRIDE"`
+	RateLimitPlugin           string `envconfig:"RATE_LIMIT_PLUGIN"`
+	RateLimitPluginOverride   string `envconfig:"RATE_LIMIT_PLUGIN_OVERRIDE"`
+	RateLimitAction           string `envconfig:"RATE_LIMIT_ACTION"`
+	RateLimitActionOverride   string `envconfig:"RATE_LIMIT_ACTION_OVERRIDE"`
+	RateLimitEndpoint         string `envconfig:"RATE_LIMIT_ENDPOINT"`
+	RateLimitEndpointOverride string `envconfig:"RATE_LIMIT_ENDPOINT_OVERRIDE"`
+
 	// platform like local or aws lambda
 	Platform PlatformType `envconfig:"PLATFORM" validate:"required"`
 
@@ -96,10 +165,51 @@ type Config struct {
 	PipVerbose            *bool  `envconfig:"PIP_VERBOSE"`
 	PipExtraArgs          string `envconfig:"PIP_EXTRA_ARGS"`
 
+	// share virtual environments between plugins with identical requirements.txt and python version
+	PythonEnvCacheEnabled *bool  `envconfig:"PYTHON_ENV_CACHE_ENABLED"`
+	PythonEnvCachePath    string `envconfig:"PYTHON_ENV_CACHE_PATH"`
+	// unused environments are kept for this many seconds before being collected
+	PythonEnvCacheGCGrace int `envconfig:"PYTHON_ENV_CACHE_GC_GRACE"`
+
+	// go toolchain used to build go plugins shipped without a prebuilt binary
+	GoCompilerPath string `envconfig:"GO_COMPILER_PATH"`
+	GoBuildTimeout int    `envconfig:"GO_BUILD_TIMEOUT"`
+
+	// node runtime and package managers of nodejs plugins, npm and pnpm are looked up next to node
+	NodeExecutablePath string `envconfig:"NODE_EXECUTABLE_PATH"`
+	NodeEnvInitTimeout int    `envconfig:"NODE_ENV_INIT_TIMEOUT"`
+	NpmRegistryUrl     string `envconfig:"NPM_REGISTRY_URL"`
+
+	// enforce the memory declared in plugin manifest on local plugin processes
+	PluginMemoryLimitEnabled *bool  `envconfig:"PLUGIN_MEMORY_LIMIT_ENABLED"`
+	PluginCgroupRoot         string `envconfig:"PLUGIN_CGROUP_ROOT"`
+
+	// isolate local plugin processes from the host, none or bwrap
+	PluginSandbox               string `envconfig:"PLUGIN_SANDBOX" validate:"omitempty,oneof=none bwrap"`
+	PluginSandboxUnverifiedOnly *bool  `envconfig:"PLUGIN_SANDBOX_UNVERIFIED_ONLY"`
+	PluginSandboxBwrapPath      string `envconfig:"PLUGIN_SANDBOX_BWRAP_PATH"`
+	PluginSandboxSocatPath      string `envconfig:"PLUGIN_SANDBOX_SOCAT_PATH"`
+	// none, proxy or host
+	PluginSandboxNetwork        string `envconfig:"PLUGIN_SANDBOX_NETWORK" validate:"omitempty,oneof=none proxy host"`
+	PluginSandboxSeccompProfile string `envconfig:"PLUGIN_SANDBOX_SECCOMP_PROFILE"`
+	// comma separated names of environment variables passed into the sandbox
+	PluginSandboxEnvAllowlist string `envconfig:"PLUGIN_SANDBOX_ENV_ALLOWLIST"`
+
 	DisplayClusterLog bool `envconfig:"DISPLAY_CLUSTER_LOG"`
 
+	// strategy to select a node when redirecting requests, round_robin, least_sessions or consistent_hash
+	ClusterNodeSelectionStrategy string `envconfig:"CLUSTER_NODE_SELECTION_STRATEGY" validate:"omitempty,oneof=round_robin least_sessions consistent_hash"`
+
+	// secret to sign requests redirected between nodes, SERVER_KEY is used if empty
+	ClusterNodeSecret string `envconfig:"CLUSTER_NODE_SECRET"`
+
 	PPROFEnabled bool `envconfig:"PPROF_ENABLED"`
 
+	// expose prometheus metrics on /metrics, it's not authenticated, so it's served on its own listener
+	// instead of the api one, keep it on the internal network
+	MetricsEnabled bool   `envconfig:"METRICS_ENABLED"`
+	MetricsAddress string `envconfig:"METRICS_ADDRESS"`
+
 	SentryEnabled          bool    `envconfig:"SENTRY_ENABLED"`
 	SentryDSN              string  `envconfig:"SENTRY_DSN"`
 	SentryAttachStacktrace bool    `envconfig:"SENTRY_ATTACH_STACKTRACE"`
@@ -107,12 +217,29 @@ type Config struct {
 	SentryTracesSampleRate float64 `envconfig:"SENTRY_TRACES_SAMPLE_RATE"`
 	SentrySampleRate       float64 `envconfig:"SENTRY_SAMPLE_RATE"`
 
+	// opentelemetry tracing, spans are exported through otlp http or printed to stdout
+	TracingEnabled      bool     `envconfig:"TRACING_ENABLED"`
+	TracingExporter     string   `envconfig:"TRACING_EXPORTER" validate:"omitempty,oneof=otlp stdout"`
+	TracingOTLPEndpoint string   `envconfig:"TRACING_OTLP_ENDPOINT"`
+	TracingOTLPInsecure bool     `envconfig:"TRACING_OTLP_INSECURE"`
+	TracingSampleRate   *float64 `envconfig:"TRACING_SAMPLE_RATE" validate:"omitempty,gte=0,lte=1"`
+
 	// proxy settings
 	HttpProxy  string `envconfig:"HTTP_PROXY"`
 	HttpsProxy string `envconfig:"HTTPS_PROXY"`
 
+	// built-in forward proxy of local plugins, only hosts declared in manifest and approved by tenants are reachable,
+	// it replaces HTTP_PROXY and HTTPS_PROXY given to plugins
+	PluginEgressProxyEnabled *bool  `envconfig:"PLUGIN_EGRESS_PROXY_ENABLED"`
+	PluginEgressProxyAddress string `envconfig:"PLUGIN_EGRESS_PROXY_ADDRESS"`
+
 	// log settings
-	HealthApiLogEnabled *bool `envconfig:"HEALTH_API_LOG_ENABLED"`
+	HealthApiLogEnabled *bool  `envconfig:"HEALTH_API_LOG_ENABLED"`
+	LogLevel            string `envconfig:"LOG_LEVEL" validate:"omitempty,oneof=debug info warn error"`
+	LogFormat           string `envconfig:"LOG_FORMAT" validate:"omitempty,oneof=text json"`
+
+	// lines of plugin stdout/stderr logs kept in memory for each plugin
+	PluginLogBufferSize int `envconfig:"PLUGIN_LOG_BUFFER_SIZE"`
 }
 
 func (c *Config) Validate() error {
@@ -122,6 +249,12 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+	}
+
 	if c.PluginRemoteInstallingEnabled != nil && *c.PluginRemoteInstallingEnabled {
 		if c.PluginRemoteInstallingHost == "" {
 			return fmt.Errorf("plugin remote installing host is empty")
@@ -135,6 +268,11 @@ func (c *Config) Validate() error {
 		if c.PluginRemoteInstallServerEventLoopNums == 0 {
 			return fmt.Errorf("plugin remote install server event loop nums is empty")
 		}
+		if c.PluginRemoteInstallingTLSEnabled != nil && *c.PluginRemoteInstallingTLSEnabled {
+			if c.PluginRemoteInstallingTLSCertFile == "" || c.PluginRemoteInstallingTLSKeyFile == "" {
+				return fmt.Errorf("plugin remote installing tls certificate or key is empty")
+			}
+		}
 	}
 
 	if c.Platform == PLATFORM_SERVERLESS {
@@ -157,6 +295,22 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid platform")
 	}
 
+	if _, err := c.PluginLocalReplicasOverrideMap(); err != nil {
+		return err
+	}
+
+	if c.PluginLocalMaxWorkers < c.PluginLocalWorkers {
+		return fmt.Errorf("plugin local max workers is less than plugin local workers")
+	}
+
+	if _, err := c.PluginLocalWorkersOverrideMap(); err != nil {
+		return err
+	}
+
+	if _, err := c.RateLimitRules(); err != nil {
+		return err
+	}
+
 	if c.PluginPackageCachePath == "" {
 		return fmt.Errorf("plugin package cache path is empty")
 	}
@@ -174,6 +328,78 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// PluginLocalReplicasOverrideMap parses PluginLocalReplicasOverride into a map of plugin id to replicas
+func (c *Config) PluginLocalReplicasOverrideMap() (map[string]int, error) {
+	result := map[string]int{}
+	if strings.TrimSpace(c.PluginLocalReplicasOverride) == "" {
+		return result, nil
+	}
+
+	for _, item := range strings.Split(c.PluginLocalReplicasOverride, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(item, ":")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid plugin local replicas override: %s", item)
+		}
+
+		replicas, err := strconv.Atoi(item[separator+1:])
+		if err != nil || replicas < 0 {
+			return nil, fmt.Errorf("invalid replicas of plugin local replicas override: %s", item)
+		}
+
+		result[item[:separator]] = replicas
+	}
+
+	return result, nil
+}
+
+// PluginLocalWorkers is the range of worker processes of a local plugin
+type PluginLocalWorkers struct {
+	Min int
+	Max int
+}
+
+// PluginLocalWorkersOverrideMap parses PluginLocalWorkersOverride into a map of plugin id to workers
+func (c *Config) PluginLocalWorkersOverrideMap() (map[string]PluginLocalWorkers, error) {
+	result := map[string]PluginLocalWorkers{}
+	if strings.TrimSpace(c.PluginLocalWorkersOverride) == "" {
+		return result, nil
+	}
+
+	for _, item := range strings.Split(c.PluginLocalWorkersOverride, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		separator := strings.LastIndex(item, ":")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid plugin local workers override: %s", item)
+		}
+
+		minWorkers, maxWorkers, ranged := strings.Cut(item[separator+1:], "-")
+		if !ranged {
+			maxWorkers = minWorkers
+		}
+
+		workers := PluginLocalWorkers{}
+		var minErr, maxErr error
+		workers.Min, minErr = strconv.Atoi(minWorkers)
+		workers.Max, maxErr = strconv.Atoi(maxWorkers)
+		if minErr != nil || maxErr != nil || workers.Min < 1 || workers.Max < workers.Min {
+			return nil, fmt.Errorf("invalid workers of plugin local workers override: %s", item)
+		}
+
+		result[item[:separator]] = workers
+	}
+
+	return result, nil
+}
+
 type PlatformType string
 
 const (
@@ -0,0 +1,51 @@
+package app
+
+import "testing"
+
+func TestPluginLocalWorkersOverrideMap(t *testing.T) {
+	config := &Config{
+		PluginLocalWorkersOverride: "langgenius/openai:2-8, langgenius/google:3",
+	}
+
+	workers, err := config.PluginLocalWorkersOverrideMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := workers["langgenius/openai"]; w.Min != 2 || w.Max != 8 {
+		t.Fatalf("unexpected workers of langgenius/openai: %+v", w)
+	}
+
+	if w := workers["langgenius/google"]; w.Min != 3 || w.Max != 3 {
+		t.Fatalf("unexpected workers of langgenius/google: %+v", w)
+	}
+}
+
+func TestPluginLocalWorkersOverrideMapInvalid(t *testing.T) {
+	for _, override := range []string{
+		"langgenius/openai",
+		"langgenius/openai:0",
+		"langgenius/openai:4-2",
+		"langgenius/openai:1-x",
+	} {
+		config := &Config{PluginLocalWorkersOverride: override}
+		if _, err := config.PluginLocalWorkersOverrideMap(); err == nil {
+			t.Fatalf("expected error for %s", override)
+		}
+	}
+}
+
+func TestApiCredentialRotationGracePeriodDefault(t *testing.T) {
+	config := &Config{}
+	config.SetDefault()
+	if *config.ApiCredentialRotationGracePeriod != 24*60*60 {
+		t.Fatalf("unexpected default grace period: %d", *config.ApiCredentialRotationGracePeriod)
+	}
+
+	disabled := 0
+	config = &Config{ApiCredentialRotationGracePeriod: &disabled}
+	config.SetDefault()
+	if *config.ApiCredentialRotationGracePeriod != 0 {
+		t.Fatalf("explicit zero grace period is overridden: %d", *config.ApiCredentialRotationGracePeriod)
+	}
+}
@@ -4,6 +4,8 @@ import "golang.org/x/exp/constraints"
 
 func (config *Config) SetDefault() {
 	setDefaultInt(&config.ServerPort, 5002)
+	setDefaultBoolPtr(&config.ServerKeyLegacyEnabled, true)
+	setDefaultIntPtr(&config.ApiCredentialRotationGracePeriod, 24*60*60)
 	setDefaultInt(&config.RoutinePoolSize, 10000)
 	setDefaultInt(&config.LifetimeCollectionGCInterval, 60)
 	setDefaultInt(&config.LifetimeCollectionHeartbeatInterval, 5)
@@ -15,10 +17,20 @@ func (config *Config) SetDefault() {
 	setDefaultInt(&config.MaxBundlePackageSize, 52428800*12)
 	setDefaultInt(&config.MaxServerlessTransactionTimeout, 300)
 	setDefaultInt(&config.PluginMaxExecutionTimeout, 10*60)
+	// no invocation lasts longer than the max execution timeout
+	setDefaultInt(&config.PluginDrainTimeout, config.PluginMaxExecutionTimeout)
+	setDefaultInt(&config.PluginRestartBackoffBase, 5)
+	setDefaultInt(&config.PluginRestartBackoffMax, 300)
+	setDefaultBoolPtr(&config.PluginCrashLoopQuarantineEnabled, true)
+	setDefaultInt(&config.PluginCrashLoopThreshold, 5)
+	setDefaultInt(&config.PluginCrashLoopWindow, 600)
 	setDefaultString(&config.PluginStorageType, "local")
 	setDefaultInt(&config.PluginMediaCacheSize, 1024)
 	setDefaultInt(&config.PluginRemoteInstallingMaxSingleTenantConn, 5)
 	setDefaultBoolPtr(&config.PluginRemoteInstallingEnabled, true)
+	setDefaultBoolPtr(&config.PluginRemoteInstallingTLSEnabled, false)
+	setDefaultInt(&config.PluginRemoteInstallingKeyTTL, 7200)
+	setDefaultBoolPtr(&config.PluginRemoteInstallingWebSocketEnabled, false)
 	setDefaultBoolPtr(&config.PluginEndpointEnabled, true)
 	setDefaultString(&config.DBSslMode, "disable")
 	setDefaultString(&config.PluginStorageLocalRoot, "storage")
@@ -26,15 +38,45 @@ func (config *Config) SetDefault() {
 	setDefaultString(&config.PluginMediaCachePath, "assets")
 	setDefaultString(&config.PersistenceStoragePath, "persistence")
 	setDefaultInt(&config.PluginLocalLaunchingConcurrent, 2)
+	setDefaultInt(&config.PluginLocalWorkers, 1)
+	setDefaultInt(&config.PluginLocalMaxWorkers, config.PluginLocalWorkers)
+	setDefaultInt(&config.PluginLocalWorkerScaleUpSessions, 4)
+	setDefaultInt(&config.PluginLocalWorkerIdleTimeout, 300)
+	setDefaultInt(&config.PluginLocalColdStartTimeout, 60)
 	setDefaultInt(&config.PersistenceStorageMaxSize, 100*1024*1024)
 	setDefaultString(&config.PluginPackageCachePath, "plugin_packages")
 	setDefaultString(&config.PythonInterpreterPath, "/usr/bin/python3")
 	setDefaultInt(&config.PythonEnvInitTimeout, 120)
+	setDefaultBoolPtr(&config.PythonEnvCacheEnabled, true)
+	setDefaultString(&config.PythonEnvCachePath, "python_env_cache")
+	setDefaultInt(&config.PythonEnvCacheGCGrace, 24*60*60)
+	setDefaultString(&config.GoCompilerPath, "go")
+	setDefaultInt(&config.GoBuildTimeout, 300)
+	setDefaultString(&config.NodeExecutablePath, "node")
+	setDefaultInt(&config.NodeEnvInitTimeout, 300)
 	setDefaultBoolPtr(&config.ForceVerifyingSignature, true)
 	setDefaultBoolPtr(&config.PipPreferBinary, true)
 	setDefaultBoolPtr(&config.PipVerbose, true)
 	setDefaultString(&config.DBDefaultDatabase, "postgres")
 	setDefaultBoolPtr(&config.HealthApiLogEnabled, true)
+	setDefaultString(&config.LogLevel, "info")
+	setDefaultString(&config.LogFormat, "text")
+	setDefaultInt(&config.PluginLogBufferSize, 1000)
+	setDefaultBoolPtr(&config.PluginMemoryLimitEnabled, false)
+	setDefaultString(&config.PluginSandbox, "none")
+	setDefaultBoolPtr(&config.PluginSandboxUnverifiedOnly, true)
+	setDefaultString(&config.PluginSandboxBwrapPath, "bwrap")
+	setDefaultString(&config.PluginSandboxSocatPath, "socat")
+	setDefaultString(&config.PluginSandboxNetwork, "proxy")
+	setDefaultString(&config.PluginSandboxEnvAllowlist, "PATH,LANG,LC_ALL,TZ")
+	setDefaultBoolPtr(&config.PluginEgressProxyEnabled, false)
+	setDefaultString(&config.PluginEgressProxyAddress, "127.0.0.1:5005")
+	setDefaultString(&config.MetricsAddress, "127.0.0.1:5006")
+	setDefaultBoolPtr(&config.RateLimitEnabled, false)
+	setDefaultString(&config.ClusterNodeSelectionStrategy, "round_robin")
+	setDefaultString(&config.TracingExporter, "otlp")
+	setDefaultFloatPtr(&config.TracingSampleRate, 1)
+	setDefaultString(&config.PluginCgroupRoot, "/sys/fs/cgroup/dify-plugin-daemon")
 }
 
 func setDefaultInt[T constraints.Integer](value *T, defaultValue T) {
@@ -49,6 +91,18 @@ func setDefaultString(value *string, defaultValue string) {
 	}
 }
 
+func setDefaultIntPtr(value **int, defaultValue int) {
+	if *value == nil {
+		*value = &defaultValue
+	}
+}
+
+func setDefaultFloatPtr(value **float64, defaultValue float64) {
+	if *value == nil {
+		*value = &defaultValue
+	}
+}
+
 func setDefaultBoolPtr(value **bool, defaultValue bool) {
 	if *value == nil {
 		*value = &defaultValue
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RateLimit is a token bucket refilled by Rate tokens per second and holding at most Burst tokens
+type RateLimit struct {
+	Rate  float64
+	Burst int64
+}
+
+// RateLimitRule is the default limit of a dimension and the overrides of specific ids
+type RateLimitRule struct {
+	Default   *RateLimit
+	Overrides map[string]RateLimit
+}
+
+// Limit returns the limit of id, nil if it's unlimited
+func (r RateLimitRule) Limit(id string) *RateLimit {
+	if limit, ok := r.Overrides[id]; ok {
+		return &limit
+	}
+	return r.Default
+}
+
+type RateLimitRules struct {
+	Tenant   RateLimitRule
+	Plugin   RateLimitRule
+	Action   RateLimitRule
+	Endpoint RateLimitRule
+}
+
+// RateLimitRules parses all RateLimit* settings
+func (c *Config) RateLimitRules() (*RateLimitRules, error) {
+	tenant, err := parseRateLimitRule(c.RateLimitTenant, c.RateLimitTenantOverride)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant rate limit: %v", err)
+	}
+
+	plugin, err := parseRateLimitRule(c.RateLimitPlugin, c.RateLimitPluginOverride)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin rate limit: %v", err)
+	}
+
+	action, err := parseRateLimitRule(c.RateLimitAction, c.RateLimitActionOverride)
+	if err != nil {
+		return nil, fmt.Errorf("invalid action rate limit: %v", err)
+	}
+
+	endpoint, err := parseRateLimitRule(c.RateLimitEndpoint, c.RateLimitEndpointOverride)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint rate limit: %v", err)
+	}
+
+	return &RateLimitRules{
+		Tenant:   tenant,
+		Plugin:   plugin,
+		Action:   action,
+		Endpoint: endpoint,
+	}, nil
+}
+
+func parseRateLimitRule(limit string, overrides string) (RateLimitRule, error) {
+	rule := RateLimitRule{Overrides: map[string]RateLimit{}}
+
+	if strings.TrimSpace(limit) != "" {
+		parsed, err := parseRateLimit(limit)
+		if err != nil {
+			return rule, err
+		}
+		rule.Default = parsed
+	}
+
+	for _, item := range strings.Split(overrides, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// plugin ids contain no colon, the last one separates the limit
+		separator := strings.LastIndex(item, ":")
+		if separator <= 0 {
+			return rule, fmt.Errorf("invalid override: %s", item)
+		}
+
+		parsed, err := parseRateLimit(item[separator+1:])
+		if err != nil {
+			return rule, err
+		}
+		rule.Overrides[item[:separator]] = *parsed
+	}
+
+	return rule, nil
+}
+
+func parseRateLimit(limit string) (*RateLimit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(limit), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid limit %s, expected rate/burst", limit)
+	}
+
+	parsedRate, err := strconv.ParseFloat(rate, 64)
+	if err != nil || parsedRate <= 0 {
+		return nil, fmt.Errorf("invalid rate of limit: %s", limit)
+	}
+
+	parsedBurst, err := strconv.ParseInt(burst, 10, 64)
+	if err != nil || parsedBurst <= 0 {
+		return nil, fmt.Errorf("invalid burst of limit: %s", limit)
+	}
+
+	return &RateLimit{Rate: parsedRate, Burst: parsedBurst}, nil
+}
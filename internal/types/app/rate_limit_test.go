@@ -0,0 +1,40 @@
+package app
+
+import "testing"
+
+func TestRateLimitRules(t *testing.T) {
+	config := &Config{
+		RateLimitTenant:         "10/20",
+		RateLimitPluginOverride: "langgenius/openai:0.5/1, langgenius/anthropic:2/4",
+	}
+
+	rules, err := config.RateLimitRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limit := rules.Tenant.Limit("any"); limit == nil || limit.Rate != 10 || limit.Burst != 20 {
+		t.Fatalf("unexpected tenant limit: %v", limit)
+	}
+
+	if limit := rules.Plugin.Limit("langgenius/openai"); limit == nil || limit.Rate != 0.5 || limit.Burst != 1 {
+		t.Fatalf("unexpected plugin override: %v", limit)
+	}
+
+	if limit := rules.Plugin.Limit("langgenius/other"); limit != nil {
+		t.Fatalf("plugin without override should be unlimited, got %v", limit)
+	}
+}
+
+func TestRateLimitRulesInvalid(t *testing.T) {
+	for _, config := range []*Config{
+		{RateLimitTenant: "10"},
+		{RateLimitAction: "0/10"},
+		{RateLimitEndpoint: "10/-1"},
+		{RateLimitEndpointOverride: "hook"},
+	} {
+		if _, err := config.RateLimitRules(); err == nil {
+			t.Fatalf("expected error for %+v", config)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package exception
 
 import (
+	"math"
 	"runtime/debug"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 )
@@ -13,12 +15,14 @@ const (
 	PluginDaemonUnauthorizedError     = "PluginDaemonUnauthorizedError"
 	PluginDaemonPermissionDeniedError = "PluginDaemonPermissionDeniedError"
 	PluginDaemonInvokeError           = "PluginDaemonInvokeError"
+	PluginDaemonRateLimitedError      = "PluginDaemonRateLimitedError"
 	PluginUniqueIdentifierError       = "PluginUniqueIdentifierError"
 	PluginNotFoundError               = "PluginNotFoundError"
 	PluginUnauthorizedError           = "PluginUnauthorizedError"
 	PluginPermissionDeniedError       = "PluginPermissionDeniedError"
 	PluginInvokeError                 = "PluginInvokeError"
 	PluginConnectionClosedError       = "ConnectionClosedError"
+	PluginUnavailableError            = "PluginUnavailableError"
 )
 
 func InternalServerError(err error) PluginDaemonError {
@@ -56,10 +60,29 @@ func PermissionDeniedError(msg string) PluginDaemonError {
 	return ErrorWithTypeAndCode(msg, PluginPermissionDeniedError, -403)
 }
 
+// RateLimitedError notifies the caller which limit was hit and how long to wait before retrying
+func RateLimitedError(limitedBy string, retryAfter time.Duration) PluginDaemonError {
+	return ErrorWithTypeCodeAndArgs(
+		"rate limited by "+limitedBy,
+		PluginDaemonRateLimitedError,
+		-429,
+		map[string]any{
+			"limited_by":  limitedBy,
+			"retry_after": int(math.Ceil(retryAfter.Seconds())),
+		},
+	)
+}
+
 func InvokePluginError(err error) PluginDaemonError {
 	return ErrorWithTypeAndCode(err.Error(), PluginInvokeError, -500)
 }
 
+// ErrPluginUnavailable notifies the caller that the plugin is running but refuses new requests,
+// e.g. it's quarantined after crashing repeatedly
+func ErrPluginUnavailable(err error) PluginDaemonError {
+	return ErrorWithTypeAndCode(err.Error(), PluginUnavailableError, -503)
+}
+
 // ConnectionClosedError is designed to be used when the connection was closed unexpectedly
 // but the session is not closed yet.
 func ConnectionClosedError() PluginDaemonError {
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type ApiCredentialScope string
+
+const (
+	API_CREDENTIAL_SCOPE_DISPATCH   ApiCredentialScope = "dispatch"
+	API_CREDENTIAL_SCOPE_MANAGEMENT ApiCredentialScope = "management"
+	API_CREDENTIAL_SCOPE_ENDPOINT   ApiCredentialScope = "endpoint"
+	API_CREDENTIAL_SCOPE_ASSET      ApiCredentialScope = "asset"
+	API_CREDENTIAL_SCOPE_DEBUGGING  ApiCredentialScope = "debugging"
+
+	// a credential bound to this tenant is allowed to access all tenants
+	API_CREDENTIAL_ALL_TENANTS = "*"
+)
+
+func (s ApiCredentialScope) Valid() bool {
+	switch s {
+	case API_CREDENTIAL_SCOPE_DISPATCH,
+		API_CREDENTIAL_SCOPE_MANAGEMENT,
+		API_CREDENTIAL_SCOPE_ENDPOINT,
+		API_CREDENTIAL_SCOPE_ASSET,
+		API_CREDENTIAL_SCOPE_DEBUGGING:
+		return true
+	}
+	return false
+}
+
+// ApiCredential is a key bound to a set of tenants and route groups,
+// only the sha256 of the key is stored, the plaintext is returned once on creation or rotation.
+// hashes are hidden from api responses but kept in the cbor encoded cache
+type ApiCredential struct {
+	Model
+	Name                 string               `json:"name" gorm:"size:127;not null"`
+	KeyPrefix            string               `json:"key_prefix" gorm:"size:16"`
+	KeyHash              string               `json:"-" cbor:"key_hash" gorm:"uniqueIndex;size:64;not null"`
+	PreviousKeyHash      string               `json:"-" cbor:"previous_key_hash" gorm:"index;size:64"`
+	PreviousKeyExpiredAt *time.Time           `json:"previous_key_expired_at"`
+	Tenants              []string             `json:"tenants" gorm:"serializer:json"`
+	Scopes               []ApiCredentialScope `json:"scopes" gorm:"serializer:json"`
+	ExpiredAt            *time.Time           `json:"expired_at"`
+	RevokedAt            *time.Time           `json:"revoked_at"`
+}
+
+// Usable checks whether the key with the given hash is still usable at the moment
+func (c *ApiCredential) Usable(keyHash string, now time.Time) bool {
+	if c.RevokedAt != nil {
+		return false
+	}
+
+	if c.ExpiredAt != nil && now.After(*c.ExpiredAt) {
+		return false
+	}
+
+	if keyHash == c.KeyHash {
+		return true
+	}
+
+	// the previous key is accepted until its grace period ends
+	return keyHash == c.PreviousKeyHash &&
+		c.PreviousKeyExpiredAt != nil &&
+		now.Before(*c.PreviousKeyExpiredAt)
+}
+
+// Allows checks whether the credential grants the scope on the tenant
+func (c *ApiCredential) Allows(tenantId string, scope ApiCredentialScope) bool {
+	if !slices.Contains(c.Scopes, scope) {
+		return false
+	}
+
+	if slices.Contains(c.Tenants, API_CREDENTIAL_ALL_TENANTS) {
+		return true
+	}
+
+	return tenantId != "" && slices.Contains(c.Tenants, tenantId)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApiCredentialUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	credential := ApiCredential{
+		KeyHash:              "current",
+		PreviousKeyHash:      "previous",
+		PreviousKeyExpiredAt: &future,
+	}
+
+	if !credential.Usable("current", now) {
+		t.Fatal("current key should be usable")
+	}
+	if !credential.Usable("previous", now) {
+		t.Fatal("previous key should be usable during grace period")
+	}
+	if credential.Usable("unknown", now) {
+		t.Fatal("unknown key should not be usable")
+	}
+
+	credential.PreviousKeyExpiredAt = &past
+	if credential.Usable("previous", now) {
+		t.Fatal("previous key should not be usable after grace period")
+	}
+
+	credential.ExpiredAt = &past
+	if credential.Usable("current", now) {
+		t.Fatal("expired credential should not be usable")
+	}
+
+	credential.ExpiredAt = nil
+	credential.RevokedAt = &past
+	if credential.Usable("current", now) {
+		t.Fatal("revoked credential should not be usable")
+	}
+}
+
+func TestApiCredentialAllows(t *testing.T) {
+	credential := ApiCredential{
+		Tenants: []string{"tenant-a"},
+		Scopes:  []ApiCredentialScope{API_CREDENTIAL_SCOPE_DISPATCH},
+	}
+
+	if !credential.Allows("tenant-a", API_CREDENTIAL_SCOPE_DISPATCH) {
+		t.Fatal("bound tenant and scope should be allowed")
+	}
+	if credential.Allows("tenant-b", API_CREDENTIAL_SCOPE_DISPATCH) {
+		t.Fatal("other tenant should not be allowed")
+	}
+	if credential.Allows("tenant-a", API_CREDENTIAL_SCOPE_MANAGEMENT) {
+		t.Fatal("other scope should not be allowed")
+	}
+	if credential.Allows("", API_CREDENTIAL_SCOPE_DISPATCH) {
+		t.Fatal("empty tenant should not be allowed")
+	}
+
+	credential.Tenants = []string{API_CREDENTIAL_ALL_TENANTS}
+	if !credential.Allows("tenant-b", API_CREDENTIAL_SCOPE_DISPATCH) {
+		t.Fatal("wildcard tenant should allow any tenant")
+	}
+}
@@ -494,3 +494,89 @@ func UpgradePlugin(
 
 	return &response, nil
 }
+
+// Replace the declaration of a remote plugin in place, the plugin unique identifier and the installation
+// are kept, provider installations are recreated since providers may have been renamed
+func UpdateRemotePluginDeclaration(
+	tenant_id string,
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	declaration *plugin_entities.PluginDeclaration,
+) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		plugin, err := db.GetOne[models.Plugin](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_unique_identifier", plugin_unique_identifier.String()),
+			db.Equal("install_type", string(plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE)),
+			db.WLock(),
+		)
+
+		if err == db.ErrDatabaseNotFound {
+			return errors.New("plugin has not been installed")
+		} else if err != nil {
+			return err
+		}
+
+		plugin.RemoteDeclaration = *declaration
+		if err := db.Update(&plugin, tx); err != nil {
+			return err
+		}
+
+		// delete the original provider installations
+		if err := db.DeleteByCondition(models.AIModelInstallation{
+			PluginUniqueIdentifier: plugin_unique_identifier.String(),
+			TenantID:               tenant_id,
+		}, tx); err != nil {
+			return err
+		}
+
+		if err := db.DeleteByCondition(models.ToolInstallation{
+			PluginUniqueIdentifier: plugin_unique_identifier.String(),
+			TenantID:               tenant_id,
+		}, tx); err != nil {
+			return err
+		}
+
+		if err := db.DeleteByCondition(models.AgentStrategyInstallation{
+			PluginUniqueIdentifier: plugin_unique_identifier.String(),
+			TenantID:               tenant_id,
+		}, tx); err != nil {
+			return err
+		}
+
+		// create the new provider installations
+		if declaration.Model != nil {
+			if err := db.Create(&models.AIModelInstallation{
+				PluginUniqueIdentifier: plugin_unique_identifier.String(),
+				TenantID:               tenant_id,
+				Provider:               declaration.Model.Provider,
+				PluginID:               plugin_unique_identifier.PluginID(),
+			}, tx); err != nil {
+				return err
+			}
+		}
+
+		if declaration.Tool != nil {
+			if err := db.Create(&models.ToolInstallation{
+				PluginUniqueIdentifier: plugin_unique_identifier.String(),
+				TenantID:               tenant_id,
+				Provider:               declaration.Tool.Identity.Name,
+				PluginID:               plugin_unique_identifier.PluginID(),
+			}, tx); err != nil {
+				return err
+			}
+		}
+
+		if declaration.AgentStrategy != nil {
+			if err := db.Create(&models.AgentStrategyInstallation{
+				PluginUniqueIdentifier: plugin_unique_identifier.String(),
+				TenantID:               tenant_id,
+				Provider:               declaration.AgentStrategy.Identity.Name,
+				PluginID:               plugin_unique_identifier.PluginID(),
+			}, tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,21 @@
+package models
+
+type PluginDebuggingAuditAction string
+
+const (
+	PluginDebuggingAuditActionAttached PluginDebuggingAuditAction = "attached"
+	PluginDebuggingAuditActionDetached PluginDebuggingAuditAction = "detached"
+	// declarations of the plugin are replaced without reconnecting
+	PluginDebuggingAuditActionReregistered PluginDebuggingAuditAction = "reregistered"
+)
+
+// PluginDebuggingAudit records a plugin attached to or detached from the debugging server,
+// the key is referred by its id, the key itself is never stored
+type PluginDebuggingAudit struct {
+	Model
+	TenantID               string                     `json:"tenant_id" gorm:"index;type:uuid;not null"`
+	UserID                 string                     `json:"user_id" gorm:"size:127"`
+	KeyID                  string                     `json:"key_id" gorm:"index;size:36"`
+	PluginUniqueIdentifier string                     `json:"plugin_unique_identifier" gorm:"size:255"`
+	Action                 PluginDebuggingAuditAction `json:"action" gorm:"size:16;not null"`
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -17,5 +20,58 @@ type Endpoint struct {
 	ExpiredAt   time.Time                                    `json:"expired_at" gorm:"column:expired_at"`
 	Enabled     bool                                         `json:"enabled" gorm:"column:enabled"`
 	Settings    map[string]any                               `json:"settings" gorm:"column:settings;serializer:json"`
+	Auth        *EndpointAuth                                `json:"auth" gorm:"column:auth;serializer:json"`
 	Declaration *plugin_entities.EndpointProviderDeclaration `json:"declaration" gorm:"-"` // not stored in db
 }
+
+// EndpointAuth holds optional guards checked by the daemon before a request is handed to the plugin,
+// every configured guard must pass, secrets are stored encrypted and masked when listed
+type EndpointAuth struct {
+	// the header must carry exactly the secret
+	SecretHeader string `json:"secret_header,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+	// the header must carry the hex encoded HMAC-SHA256 of the body, optionally prefixed with "sha256="
+	SignatureHeader string `json:"signature_header,omitempty"`
+	SignatureSecret string `json:"signature_secret,omitempty"`
+	// client ips or CIDRs allowed to call the endpoint
+	IPAllowlist []string `json:"ip_allowlist,omitempty"`
+}
+
+func (a *EndpointAuth) Validate() error {
+	if (a.SecretHeader == "") != (a.Secret == "") {
+		return errors.New("secret_header and secret must be set together")
+	}
+
+	if (a.SignatureHeader == "") != (a.SignatureSecret == "") {
+		return errors.New("signature_header and signature_secret must be set together")
+	}
+
+	for _, entry := range a.IPAllowlist {
+		if _, err := ParseEndpointAllowedIP(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseEndpointAllowedIP parses an ip or a CIDR, a single ip is treated as a /32 or /128 network
+func ParseEndpointAllowedIP(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		return network, err
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, errors.New("invalid ip: " + entry)
+	}
+
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -0,0 +1,10 @@
+package models
+
+// PluginNetworkApproval is the set of domains a tenant allows a plugin to access through the egress proxy,
+// it's kept across upgrades of the plugin, only domains declared in the manifest take effect
+type PluginNetworkApproval struct {
+	Model
+	TenantID string   `json:"tenant_id" gorm:"uniqueIndex:idx_plugin_network_approval;type:uuid"`
+	PluginID string   `json:"plugin_id" gorm:"uniqueIndex:idx_plugin_network_approval;size:255"`
+	Domains  []string `json:"domains" gorm:"serializer:json"`
+}
@@ -121,11 +121,30 @@ func (c *memCache) set(key string, declaration *plugin_entities.PluginDeclaratio
 	c.itemSize++
 }
 
-func CombinedGetPluginDeclaration(
+func (c *memCache) delete(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, exists := c.items[key]; exists {
+		c.itemSize--
+		delete(c.items, key)
+	}
+}
+
+const (
+	PLUGIN_DECLARATION_UPDATED_CHANNEL = "plugin:declaration:updated"
+)
+
+type PluginDeclarationUpdatedEvent struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	RuntimeType            plugin_entities.PluginRuntimeType      `json:"runtime_type"`
+}
+
+func declarationCacheKey(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
 	runtimeType plugin_entities.PluginRuntimeType,
-) (*plugin_entities.PluginDeclaration, error) {
-	cacheKey := strings.Join(
+) string {
+	return strings.Join(
 		[]string{
 			"declaration_cache",
 			string(runtimeType),
@@ -133,6 +152,13 @@ func CombinedGetPluginDeclaration(
 		},
 		":",
 	)
+}
+
+func CombinedGetPluginDeclaration(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	runtimeType plugin_entities.PluginRuntimeType,
+) (*plugin_entities.PluginDeclaration, error) {
+	cacheKey := declarationCacheKey(pluginUniqueIdentifier, runtimeType)
 
 	// Try memory cache first
 	if declaration := pluginCache.get(cacheKey); declaration != nil {
@@ -182,3 +208,27 @@ func CombinedGetPluginDeclaration(
 
 	return declaration, err
 }
+
+// InvalidatePluginDeclaration drops the cached declaration once it's updated in db,
+// other nodes drop their memory cache after receiving the published event
+func InvalidatePluginDeclaration(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	runtimeType plugin_entities.PluginRuntimeType,
+) error {
+	cacheKey := declarationCacheKey(pluginUniqueIdentifier, runtimeType)
+	pluginCache.delete(cacheKey)
+
+	if err := cache.AutoDelete[plugin_entities.PluginDeclaration](cacheKey); err != nil {
+		return err
+	}
+
+	return cache.Publish(PLUGIN_DECLARATION_UPDATED_CHANNEL, PluginDeclarationUpdatedEvent{
+		PluginUniqueIdentifier: pluginUniqueIdentifier,
+		RuntimeType:            runtimeType,
+	})
+}
+
+// OnPluginDeclarationUpdated drops the memory cache of a declaration updated by any node
+func OnPluginDeclarationUpdated(event PluginDeclarationUpdatedEvent) {
+	pluginCache.delete(declarationCacheKey(event.PluginUniqueIdentifier, event.RuntimeType))
+}
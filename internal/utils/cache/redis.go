@@ -205,6 +205,28 @@ func SetMapOneField(key string, field string, value any, context ...redis.Cmdabl
 	return getCmdable(context...).HSet(ctx, serialKey(key), field, value).Err()
 }
 
+// SetMapOneFieldNX set the map field with key only if the field does not exist
+func SetMapOneFieldNX(key string, field string, value any, context ...redis.Cmdable) (bool, error) {
+	if client == nil {
+		return false, ErrDBNotInit
+	}
+
+	if _, ok := value.(string); !ok {
+		value = parser.MarshalJson(value)
+	}
+
+	return getCmdable(context...).HSetNX(ctx, serialKey(key), field, value).Result()
+}
+
+// IncreaseMapField increases the map field with key by n
+func IncreaseMapField(key string, field string, n int64, context ...redis.Cmdable) (int64, error) {
+	if client == nil {
+		return 0, ErrDBNotInit
+	}
+
+	return getCmdable(context...).HIncrBy(ctx, serialKey(key), field, n).Result()
+}
+
 // GetMapField get the map field with key
 func GetMapField[T any](key string, field string, context ...redis.Cmdable) (*T, error) {
 	if client == nil {
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type TokenBucket struct {
+	Key string
+	// tokens refilled per second
+	Rate  float64
+	Burst int64
+}
+
+const (
+	TOKEN_USAGE_ALLOWED = "allowed"
+	TOKEN_USAGE_LIMITED = "limited"
+)
+
+// TokenUsage counts the outcome of taking tokens in the same round trip,
+// each field of the hash is suffixed with the outcome, e.g. field:allowed
+type TokenUsage struct {
+	Key    string
+	Fields []string
+	TTL    time.Duration
+}
+
+// checks every bucket first and only takes tokens if all of them have enough, then counts the outcome if requested,
+// returns the 1-based index of the first exhausted bucket (0 if allowed) and milliseconds to wait.
+// KEYS are the buckets followed by the optional usage hash,
+// ARGV is cost, number of buckets, rate and burst of each bucket, then ttl of usage and its fields
+var takeTokensScript = redis.NewScript(`
+-- TIME is non-deterministic, replicate effects instead of the script on redis < 5
+redis.replicate_commands()
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+local cost = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+
+local tokens = {}
+local rejected = 0
+local wait = 0
+for i = 1, n do
+	local rate = tonumber(ARGV[i * 2 + 1])
+	local burst = tonumber(ARGV[i * 2 + 2])
+	local state = redis.call('HMGET', KEYS[i], 'tokens', 'ts')
+	local current = tonumber(state[1]) or burst
+	local ts = tonumber(state[2]) or now
+	current = math.min(burst, current + math.max(0, now - ts) * rate / 1000)
+	tokens[i] = current
+	if rejected == 0 and current < cost then
+		rejected = i
+		wait = math.ceil((cost - current) * 1000 / rate)
+	end
+end
+
+for i = 1, n do
+	local rate = tonumber(ARGV[i * 2 + 1])
+	local burst = tonumber(ARGV[i * 2 + 2])
+	local current = tokens[i]
+	if rejected == 0 then
+		current = current - cost
+	end
+	redis.call('HSET', KEYS[i], 'tokens', tostring(current), 'ts', tostring(now))
+	-- a bucket refilled to burst is equal to a missing one
+	redis.call('PEXPIRE', KEYS[i], math.ceil(burst * 1000 / rate) + 1000)
+end
+
+if #KEYS > n then
+	local outcome = '` + TOKEN_USAGE_ALLOWED + `'
+	if rejected > 0 then
+		outcome = '` + TOKEN_USAGE_LIMITED + `'
+	end
+	for i = n * 2 + 4, #ARGV do
+		redis.call('HINCRBY', KEYS[n + 1], ARGV[i] .. ':' .. outcome, 1)
+	end
+	redis.call('PEXPIRE', KEYS[n + 1], tonumber(ARGV[n * 2 + 3]))
+end
+
+return {rejected, wait}
+`)
+
+// TakeTokens takes cost tokens from all buckets atomically, nothing is taken if any of them is exhausted.
+// the index of the exhausted bucket and the time to wait are returned, index is -1 if tokens were taken,
+// the outcome is counted into usage if it's not nil
+func TakeTokens(
+	buckets []TokenBucket, cost int64, usage *TokenUsage, context ...redis.Cmdable,
+) (int, time.Duration, error) {
+	if client == nil {
+		return -1, 0, ErrDBNotInit
+	}
+
+	if len(buckets) == 0 && usage == nil {
+		return -1, 0, nil
+	}
+
+	keys := make([]string, 0, len(buckets)+1)
+	args := make([]any, 0, len(buckets)*2+2)
+	args = append(args, cost, len(buckets))
+	for _, bucket := range buckets {
+		keys = append(keys, serialKey(bucket.Key))
+		args = append(args, bucket.Rate, bucket.Burst)
+	}
+
+	if usage != nil {
+		keys = append(keys, serialKey(usage.Key))
+		args = append(args, usage.TTL.Milliseconds())
+		for _, field := range usage.Fields {
+			args = append(args, field)
+		}
+	}
+
+	result, err := takeTokensScript.Run(ctx, getCmdable(context...), keys, args...).Int64Slice()
+	if err != nil {
+		return -1, 0, err
+	}
+
+	return int(result[0]) - 1, time.Duration(result[1]) * time.Millisecond, nil
+}
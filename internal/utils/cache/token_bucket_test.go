@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTakeTokens(t *testing.T) {
+	if err := getRedisConnection(); err != nil {
+		t.Errorf("get redis connection failed: %v", err)
+		return
+	}
+	defer Close()
+
+	buckets := []TokenBucket{
+		{Key: TEST_PREFIX + ":bucket:large", Rate: 1, Burst: 10},
+		{Key: TEST_PREFIX + ":bucket:small", Rate: 0.001, Burst: 2},
+	}
+	defer Del(buckets[0].Key)
+	defer Del(buckets[1].Key)
+
+	for i := 0; i < 2; i++ {
+		rejected, _, err := TakeTokens(buckets, 1, nil)
+		if err != nil {
+			t.Errorf("take tokens failed: %v", err)
+			return
+		}
+		if rejected != -1 {
+			t.Errorf("expected tokens to be taken, rejected by %d", rejected)
+			return
+		}
+	}
+
+	rejected, wait, err := TakeTokens(buckets, 1, nil)
+	if err != nil {
+		t.Errorf("take tokens failed: %v", err)
+		return
+	}
+	if rejected != 1 {
+		t.Errorf("expected the small bucket to be exhausted, got %d", rejected)
+		return
+	}
+	if wait <= 0 {
+		t.Errorf("expected a positive wait, got %v", wait)
+		return
+	}
+
+	// the large bucket must not be charged by the rejected request
+	tokens, err := GetMapFieldString(buckets[0].Key, "tokens")
+	if err != nil {
+		t.Errorf("get tokens failed: %v", err)
+		return
+	}
+	if remaining, _ := strconv.ParseFloat(tokens, 64); remaining < 8 {
+		t.Errorf("large bucket was charged by a rejected request: %s", tokens)
+	}
+}
+
+func TestTakeTokensCountsUsage(t *testing.T) {
+	if err := getRedisConnection(); err != nil {
+		t.Errorf("get redis connection failed: %v", err)
+		return
+	}
+	defer Close()
+
+	buckets := []TokenBucket{{Key: TEST_PREFIX + ":bucket:usage", Rate: 0.001, Burst: 1}}
+	usage := &TokenUsage{Key: TEST_PREFIX + ":usage", Fields: []string{"tenant:a"}, TTL: time.Minute}
+	defer Del(buckets[0].Key)
+	defer Del(usage.Key)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := TakeTokens(buckets, 1, usage); err != nil {
+			t.Errorf("take tokens failed: %v", err)
+			return
+		}
+	}
+
+	counts, err := GetMap[int64](usage.Key)
+	if err != nil {
+		t.Errorf("get usage failed: %v", err)
+		return
+	}
+	if counts["tenant:a:"+TOKEN_USAGE_ALLOWED] != 1 || counts["tenant:a:"+TOKEN_USAGE_LIMITED] != 1 {
+		t.Errorf("expected one allowed and one limited request, got %v", counts)
+	}
+}
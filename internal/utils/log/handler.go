@@ -0,0 +1,141 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	LOG_LEVEL_DEBUG_COLOR = "\033[34m"
+	LOG_LEVEL_INFO_COLOR  = "\033[32m"
+	LOG_LEVEL_WARN_COLOR  = "\033[33m"
+	LOG_LEVEL_ERROR_COLOR = "\033[31m"
+	LOG_LEVEL_COLOR_END   = "\033[0m"
+)
+
+// nodeHandler attaches the node id to every record once it's known
+type nodeHandler struct {
+	slog.Handler
+}
+
+func (h *nodeHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := node_id.Load().(string); ok && id != "" {
+		record.AddAttrs(slog.String(FIELD_NODE_ID, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *nodeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &nodeHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *nodeHandler) WithGroup(name string) slog.Handler {
+	return &nodeHandler{h.Handler.WithGroup(name)}
+}
+
+// textHandler writes coloured lines like
+//
+//	2024/01/01 00:00:00 file.go:12: [INFO]message key=value
+type textHandler struct {
+	mu     *sync.Mutex
+	writer io.Writer
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string
+}
+
+func newTextHandler(writer io.Writer, level slog.Leveler) *textHandler {
+	return &textHandler{
+		mu:     &sync.Mutex{},
+		writer: writer,
+		level:  level,
+	}
+}
+
+func (h *textHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(record.Time.Format("2006/01/02 15:04:05"))
+	buf.WriteByte(' ')
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		fmt.Fprintf(buf, "%s:%d: ", filepath.Base(frame.File), frame.Line)
+	}
+
+	color := LOG_LEVEL_INFO_COLOR
+	switch {
+	case record.Level >= slog.LevelError:
+		color = LOG_LEVEL_ERROR_COLOR
+	case record.Level >= slog.LevelWarn:
+		color = LOG_LEVEL_WARN_COLOR
+	case record.Level < slog.LevelInfo:
+		color = LOG_LEVEL_DEBUG_COLOR
+	}
+	buf.WriteString(color + "[" + levelName(record.Level) + "]" + record.Message + LOG_LEVEL_COLOR_END)
+
+	for _, attr := range h.attrs {
+		writeTextAttr(buf, "", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		writeTextAttr(buf, h.prefix, attr)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.writer.Write(buf.Bytes())
+	return err
+}
+
+func writeTextAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			writeTextAttr(buf, prefix+attr.Key+".", a)
+		}
+		return
+	}
+
+	value := attr.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	buf.WriteString(" " + prefix + attr.Key + "=" + value)
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	handler.attrs = append(handler.attrs, h.attrs...)
+	for _, attr := range attrs {
+		attr.Key = h.prefix + attr.Key
+		handler.attrs = append(handler.attrs, attr)
+	}
+	return &handler
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	handler.prefix = h.prefix + name + "."
+	return &handler
+}
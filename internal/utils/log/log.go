@@ -1,67 +1,184 @@
 package log
 
 /*
-	log module is used to write log info to log file
-	open a log file when log was created, and close it when log was destroyed
+	log module writes leveled logs to stdout, based on log/slog
+	text format keeps the coloured lines for humans, json format is for log collectors like loki
+	printf-style helpers are kept, structured fields are attached with With
 */
 
 import (
+	"context"
 	"fmt"
-	go_log "log"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-var show_log bool = true
-var logger = go_log.New(os.Stdout, "", go_log.Ldate|go_log.Ltime|go_log.Lshortfile)
-
 const (
-	LOG_LEVEL_DEBUG_COLOR = "\033[34m"
-	LOG_LEVEL_INFO_COLOR  = "\033[32m"
-	LOG_LEVEL_WARN_COLOR  = "\033[33m"
-	LOG_LEVEL_ERROR_COLOR = "\033[31m"
-	LOG_LEVEL_COLOR_END   = "\033[0m"
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+
+	// well-known fields, used to correlate logs with an invocation
+	FIELD_TENANT_ID  = "tenant_id"
+	FIELD_PLUGIN     = "plugin_unique_identifier"
+	FIELD_SESSION_ID = "session_id"
+	FIELD_NODE_ID    = "node_id"
+)
+
+// LevelPanic is logged right before panicking
+const LevelPanic = slog.Level(12)
+
+var (
+	show_log = atomic.Bool{}
+	level    = new(slog.LevelVar)
+	node_id  = atomic.Value{}
+	root     = atomic.Pointer[Logger]{}
 )
 
-func writeLog(level string, format string, stdout bool, v ...interface{}) {
-	//write log
-	format = fmt.Sprintf("["+level+"]"+format, v...)
-
-	if show_log && stdout {
-		if level == "DEBUG" {
-			logger.Output(3, LOG_LEVEL_DEBUG_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "INFO" {
-			logger.Output(3, LOG_LEVEL_INFO_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "WARN" {
-			logger.Output(3, LOG_LEVEL_WARN_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "ERROR" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "PANIC" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-			panic(fmt.Sprintf(format, v...))
+func init() {
+	show_log.Store(true)
+	root.Store(&Logger{logger: slog.New(&nodeHandler{newTextHandler(os.Stdout, level)})})
+}
+
+type Logger struct {
+	logger *slog.Logger
+}
+
+// Init sets the minimal level and the output format of all loggers
+func Init(levelName string, format string) error {
+	l, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+
+	var handler slog.Handler
+	switch format {
+	case "", FORMAT_TEXT:
+		handler = newTextHandler(os.Stdout, level)
+	case FORMAT_JSON:
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+			AddSource:   true,
+			Level:       level,
+			ReplaceAttr: replaceLevelName,
+		})
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	root.Store(&Logger{logger: slog.New(&nodeHandler{handler})})
+	return nil
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level: %s", name)
+}
+
+func levelName(l slog.Level) string {
+	if l == LevelPanic {
+		return "PANIC"
+	}
+	return l.String()
+}
+
+func replaceLevelName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if l, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, levelName(l))
 		}
 	}
+	return a
+}
+
+// SetNodeID attaches the cluster node id to all following logs
+func SetNodeID(id string) {
+	node_id.Store(id)
 }
 
 func SetShowLog(show bool) {
-	show_log = show
+	show_log.Store(show)
+}
+
+// With returns a logger which attaches the key-value pairs to every log, e.g.
+//
+//	log.With(log.FIELD_TENANT_ID, tenantId).Info("plugin %s installed", identifier)
+func With(args ...any) *Logger {
+	return root.Load().With(args...)
+}
+
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{logger: l.logger.With(args...)}
+}
+
+func (l *Logger) write(lvl slog.Level, format string, v ...any) {
+	if !show_log.Load() && lvl != LevelPanic {
+		return
+	}
+
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, lvl) {
+		return
+	}
+
+	// skip runtime.Callers, write and the exported helper to report the real caller
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), lvl, fmt.Sprintf(format, v...), pcs[0])
+	l.logger.Handler().Handle(ctx, record)
+}
+
+func (l *Logger) Debug(format string, v ...any) {
+	l.write(slog.LevelDebug, format, v...)
+}
+
+func (l *Logger) Info(format string, v ...any) {
+	l.write(slog.LevelInfo, format, v...)
+}
+
+func (l *Logger) Warn(format string, v ...any) {
+	l.write(slog.LevelWarn, format, v...)
+}
+
+func (l *Logger) Error(format string, v ...any) {
+	l.write(slog.LevelError, format, v...)
+}
+
+func (l *Logger) Panic(format string, v ...any) {
+	l.write(LevelPanic, format, v...)
+	panic(fmt.Sprintf(format, v...))
 }
 
-func Debug(format string, v ...interface{}) {
-	writeLog("DEBUG", format, true, v...)
+func Debug(format string, v ...any) {
+	root.Load().write(slog.LevelDebug, format, v...)
 }
 
-func Info(format string, v ...interface{}) {
-	writeLog("INFO", format, true, v...)
+func Info(format string, v ...any) {
+	root.Load().write(slog.LevelInfo, format, v...)
 }
 
-func Warn(format string, v ...interface{}) {
-	writeLog("WARN", format, true, v...)
+func Warn(format string, v ...any) {
+	root.Load().write(slog.LevelWarn, format, v...)
 }
 
-func Error(format string, v ...interface{}) {
-	writeLog("ERROR", format, true, v...)
+func Error(format string, v ...any) {
+	root.Load().write(slog.LevelError, format, v...)
 }
 
-func Panic(format string, v ...interface{}) {
-	writeLog("PANIC", format, true, v...)
+func Panic(format string, v ...any) {
+	root.Load().write(LevelPanic, format, v...)
+	panic(fmt.Sprintf(format, v...))
 }
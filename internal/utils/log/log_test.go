@@ -0,0 +1,54 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestJSONLogWithFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	level.Set(slog.LevelInfo)
+	logger := &Logger{logger: slog.New(&nodeHandler{slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceLevelName,
+	})})}
+
+	SetNodeID("node-a")
+	defer SetNodeID("")
+
+	logger.Debug("filtered")
+	if buf.Len() != 0 {
+		t.Fatalf("debug log should be filtered: %s", buf.String())
+	}
+
+	logger.With(FIELD_TENANT_ID, "tenant-a", FIELD_SESSION_ID, "session-a").Warn("hello %s", "world")
+
+	record := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"msg":            "hello world",
+		"level":          "WARN",
+		FIELD_TENANT_ID:  "tenant-a",
+		FIELD_SESSION_ID: "session-a",
+		FIELD_NODE_ID:    "node-a",
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Fatalf("expected %s=%s, got %v", key, value, record[key])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatal("unknown level should be rejected")
+	}
+	if l, _ := ParseLevel("WARN"); l != slog.LevelWarn {
+		t.Fatalf("unexpected level %s", l)
+	}
+}
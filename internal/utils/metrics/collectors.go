@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	routinePoolRunningDesc = prometheus.NewDesc(
+		NAMESPACE+"_routine_pool_running", "Running goroutines of the routine pool", nil, nil,
+	)
+	routinePoolCapacityDesc = prometheus.NewDesc(
+		NAMESPACE+"_routine_pool_capacity", "Capacity of the routine pool", nil, nil,
+	)
+
+	pluginRuntimesDesc = prometheus.NewDesc(
+		NAMESPACE+"_plugin_runtimes", "Plugin runtimes on this node by plugin, runtime type and status",
+		[]string{"plugin", "runtime_type", "status"}, nil,
+	)
+	pluginRestartsDesc = prometheus.NewDesc(
+		NAMESPACE+"_plugin_restarts_total", "Restarts of plugin runtimes on this node",
+		[]string{"plugin", "runtime_type"}, nil,
+	)
+
+	clusterMasterDesc = prometheus.NewDesc(
+		NAMESPACE+"_cluster_master", "Whether this node is the master of the cluster", nil, nil,
+	)
+	clusterNodesDesc = prometheus.NewDesc(
+		NAMESPACE+"_cluster_nodes", "Alive nodes of the cluster seen by this node", nil, nil,
+	)
+)
+
+type ClusterSource interface {
+	IsMaster() bool
+	NodeCount() int
+}
+
+var (
+	runtimeSource atomic.Pointer[func() []plugin_entities.PluginLifetime]
+	clusterSource atomic.Pointer[ClusterSource]
+)
+
+// SetRuntimeSource sets where runtime states are collected from on scrape
+func SetRuntimeSource(source func() []plugin_entities.PluginLifetime) {
+	runtimeSource.Store(&source)
+}
+
+// SetClusterSource sets where cluster role and size are collected from on scrape
+func SetClusterSource(source ClusterSource) {
+	clusterSource.Store(&source)
+}
+
+type routinePoolCollector struct{}
+
+func (routinePoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- routinePoolRunningDesc
+	ch <- routinePoolCapacityDesc
+}
+
+func (routinePoolCollector) Collect(ch chan<- prometheus.Metric) {
+	running, capacity := routine.Stats()
+	ch <- prometheus.MustNewConstMetric(routinePoolRunningDesc, prometheus.GaugeValue, float64(running))
+	ch <- prometheus.MustNewConstMetric(routinePoolCapacityDesc, prometheus.GaugeValue, float64(capacity))
+}
+
+type runtimeCollector struct{}
+
+func (runtimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pluginRuntimesDesc
+	ch <- pluginRestartsDesc
+}
+
+func (runtimeCollector) Collect(ch chan<- prometheus.Metric) {
+	source := runtimeSource.Load()
+	if source == nil {
+		return
+	}
+
+	type runtimeKey struct {
+		plugin      string
+		runtimeType string
+		status      string
+	}
+
+	runtimes := map[runtimeKey]int{}
+	restarts := map[runtimeKey]int{}
+	for _, runtime := range (*source)() {
+		identity, err := runtime.Identity()
+		if err != nil {
+			continue
+		}
+
+		state := runtime.RuntimeState()
+		key := runtimeKey{
+			plugin:      identity.PluginID(),
+			runtimeType: string(runtime.Type()),
+			status:      state.Status,
+		}
+		runtimes[key]++
+		restarts[runtimeKey{plugin: key.plugin, runtimeType: key.runtimeType}] += state.Restarts
+	}
+
+	for key, count := range runtimes {
+		ch <- prometheus.MustNewConstMetric(
+			pluginRuntimesDesc, prometheus.GaugeValue, float64(count),
+			key.plugin, key.runtimeType, key.status,
+		)
+	}
+
+	for key, count := range restarts {
+		ch <- prometheus.MustNewConstMetric(
+			pluginRestartsDesc, prometheus.CounterValue, float64(count),
+			key.plugin, key.runtimeType,
+		)
+	}
+}
+
+type clusterCollector struct{}
+
+func (clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clusterMasterDesc
+	ch <- clusterNodesDesc
+}
+
+func (clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	source := clusterSource.Load()
+	if source == nil {
+		return
+	}
+
+	master := 0.0
+	if (*source).IsMaster() {
+		master = 1
+	}
+
+	ch <- prometheus.MustNewConstMetric(clusterMasterDesc, prometheus.GaugeValue, master)
+	ch <- prometheus.MustNewConstMetric(clusterNodesDesc, prometheus.GaugeValue, float64((*source).NodeCount()))
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	NAMESPACE = "plugin_daemon"
+
+	STATUS_SUCCESS  = "success"
+	STATUS_ERROR    = "error"
+	STATUS_CANCELED = "canceled"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	invocationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "invocations_total",
+		Help:      "Plugin invocations by access type, action, plugin and status",
+	}, []string{"access_type", "action", "plugin", "status"})
+
+	invocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "invocation_duration_seconds",
+		Help:      "Time from sending a request to a plugin to the end of its response stream",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"access_type", "action", "plugin"})
+
+	streamChunksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "stream_chunks_total",
+		Help:      "Chunks streamed back from plugins",
+	}, []string{"access_type", "action", "plugin"})
+
+	backwardsInvocationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "backwards_invocations_total",
+		Help:      "Invocations from plugins back to dify by invoke type and status",
+	}, []string{"type", "status"})
+
+	backwardsInvocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "backwards_invocation_duration_seconds",
+		Help:      "Duration of invocations from plugins back to dify",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+
+	coldStartDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "plugin_cold_start_duration_seconds",
+		Help:      "Time from waking an idle local plugin up to its first worker started",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"plugin", "status"})
+
+	pluginInstallationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "plugin_installations_total",
+		Help:      "Outcomes of plugins in install tasks",
+	}, []string{"status"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		invocationsTotal,
+		invocationDuration,
+		streamChunksTotal,
+		backwardsInvocationsTotal,
+		backwardsInvocationDuration,
+		pluginInstallationsTotal,
+		coldStartDuration,
+		routinePoolCollector{},
+		runtimeCollector{},
+		clusterCollector{},
+	)
+}
+
+// Handler serves all metrics in prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Invocation tracks a single invocation of a plugin, Finish should be called once the stream ends
+type Invocation struct {
+	labels    []string
+	startedAt time.Time
+}
+
+func StartInvocation(accessType string, action string, plugin string) *Invocation {
+	return &Invocation{
+		labels:    []string{accessType, action, plugin},
+		startedAt: time.Now(),
+	}
+}
+
+func (i *Invocation) Chunk() {
+	streamChunksTotal.WithLabelValues(i.labels...).Inc()
+}
+
+func (i *Invocation) Finish(status string) {
+	invocationsTotal.WithLabelValues(append(i.labels, status)...).Inc()
+	invocationDuration.WithLabelValues(i.labels...).Observe(time.Since(i.startedAt).Seconds())
+}
+
+func ObserveBackwardsInvocation(typ string, status string, duration time.Duration) {
+	backwardsInvocationsTotal.WithLabelValues(typ, status).Inc()
+	backwardsInvocationDuration.WithLabelValues(typ).Observe(duration.Seconds())
+}
+
+func ObservePluginInstallation(status string) {
+	pluginInstallationsTotal.WithLabelValues(status).Inc()
+}
+
+func ObserveColdStart(plugin string, status string, duration time.Duration) {
+	coldStartDuration.WithLabelValues(plugin, status).Observe(duration.Seconds())
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerExposesInvocations(t *testing.T) {
+	invocation := StartInvocation("tool", "invoke_tool", "langgenius/test:0.0.1")
+	invocation.Chunk()
+	invocation.Finish(STATUS_SUCCESS)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(recorder.Body)
+	for _, expected := range []string{
+		`plugin_daemon_invocations_total{access_type="tool",action="invoke_tool",plugin="langgenius/test:0.0.1",status="success"} 1`,
+		`plugin_daemon_stream_chunks_total{access_type="tool",action="invoke_tool",plugin="langgenius/test:0.0.1"} 1`,
+		`plugin_daemon_routine_pool_capacity`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Fatalf("expected %s in metrics output", expected)
+		}
+	}
+}
@@ -1,6 +1,30 @@
 package parser
 
-import "github.com/fxamacker/cbor/v2"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+// CborDecodable is implemented by types decoded from cbor directly, byte strings are kept as []byte,
+// other types are decoded from the json converted from cbor as cbor never calls custom json unmarshalers
+type CborDecodable interface {
+	CborDecodable()
+}
+
+// maps of cbor messages decode into map[string]any like json
+var cborDecMode = func() cbor.DecMode {
+	mode, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
 
 func MarshalCBOR[T any](v T) ([]byte, error) {
 	return cbor.Marshal(v)
@@ -11,3 +35,53 @@ func UnmarshalCBOR[T any](data []byte) (T, error) {
 	err := cbor.Unmarshal(data, &v)
 	return v, err
 }
+
+// IsCborMap returns true if data is a cbor map, a json text never starts with the major type of cbor maps
+func IsCborMap(data []byte) bool {
+	return len(data) > 0 && data[0] >= 0xa0 && data[0] <= 0xbf
+}
+
+// CborToJson converts a cbor message to json, byte strings become base64 strings, json is returned as is
+func CborToJson(data []byte) ([]byte, error) {
+	if !IsCborMap(data) {
+		return data, nil
+	}
+
+	var message any
+	if err := cborDecMode.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("decode cbor failed: %w", err)
+	}
+
+	result, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("convert cbor to json failed: %w", err)
+	}
+	return result, nil
+}
+
+// UnmarshalJsonOrCborBytes unmarshals a json or cbor message and validates it like UnmarshalJsonBytes,
+// byte strings of cbor are kept as []byte if T is CborDecodable
+func UnmarshalJsonOrCborBytes[T any](data []byte) (T, error) {
+	var result T
+	if !IsCborMap(data) {
+		return UnmarshalJsonBytes[T](data)
+	}
+
+	if _, ok := any(&result).(CborDecodable); !ok {
+		converted, err := CborToJson(data)
+		if err != nil {
+			return result, err
+		}
+		return UnmarshalJsonBytes[T](converted)
+	}
+
+	if err := cborDecMode.Unmarshal(data, &result); err != nil {
+		return result, err
+	}
+
+	if err := validators.GlobalEntitiesValidator.Struct(&result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type cborTestBlob struct {
+	Blob []byte `json:"blob"`
+}
+
+func (cborTestBlob) CborDecodable() {}
+
+// cborTestText only decodes from json, cbor never calls UnmarshalJSON
+type cborTestText struct {
+	Text string
+}
+
+func (c *cborTestText) UnmarshalJSON(data []byte) error {
+	c.Text = strings.ToUpper(strings.Trim(string(data), `{}"`))
+	return nil
+}
+
+func TestUnmarshalJsonOrCborBytes(t *testing.T) {
+	blob := []byte{0x00, 0xff, '\n'}
+	payload, err := cbor.Marshal(map[string]any{"blob": blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsCborMap(payload) || IsCborMap([]byte(`{"blob":""}`)) {
+		t.Fatal("cbor maps and json should be told apart")
+	}
+
+	decoded, err := UnmarshalJsonOrCborBytes[cborTestBlob](payload)
+	if err != nil || !bytes.Equal(decoded.Blob, blob) {
+		t.Fatalf("blob should be decoded as bytes, got %v, %v", decoded.Blob, err)
+	}
+
+	// the same type still accepts json with base64
+	decoded, err = UnmarshalJsonOrCborBytes[cborTestBlob]([]byte(`{"blob":"AP8K"}`))
+	if err != nil || !bytes.Equal(decoded.Blob, blob) {
+		t.Fatalf("blob should be decoded from base64, got %v, %v", decoded.Blob, err)
+	}
+
+	// types not decodable from cbor go through json
+	payload, err = cbor.Marshal(map[string]any{"text": "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := UnmarshalJsonOrCborBytes[cborTestText](payload)
+	if err != nil || !strings.Contains(text.Text, "ABC") {
+		t.Fatalf("custom json unmarshaler should be called, got %q, %v", text.Text, err)
+	}
+}
@@ -39,6 +39,16 @@ func InitPool(size int, sentryOption ...sentry.ClientOptions) {
 	}
 }
 
+// Stats returns running goroutines and capacity of the pool
+func Stats() (running int, capacity int) {
+	l.Lock()
+	defer l.Unlock()
+	if p == nil {
+		return 0, 0
+	}
+	return p.Running(), p.Cap()
+}
+
 func Submit(labels map[string]string, f func()) {
 	if labels == nil {
 		labels = map[string]string{}
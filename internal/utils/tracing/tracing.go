@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SERVICE_NAME = "dify-plugin-daemon"
+
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+
+	// max time to flush pending spans on shutdown
+	SHUTDOWN_TIMEOUT = 5 * time.Second
+)
+
+type Options struct {
+	// otlp or stdout
+	Exporter string
+	// host:port of the otlp http receiver, OTEL_EXPORTER_OTLP_ENDPOINT is used if empty
+	OTLPEndpoint string
+	OTLPInsecure bool
+	// ratio of root spans to sample, spans with a sampled parent are always sampled
+	SampleRate float64
+}
+
+// Init installs the global tracer provider and the w3c trace context propagator,
+// the returned function flushes pending spans and should be called on shutdown
+func Init(options Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch options.Exporter {
+	case EXPORTER_OTLP:
+		opts := []otlptracehttp.Option{}
+		if options.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(options.OTLPEndpoint))
+		}
+		if options.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", options.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", SERVICE_NAME)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRate))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx,
+// it's a no-op span if tracing is not initialized
+func Start(
+	ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(SERVICE_NAME).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End records the error if any and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject writes the trace context of ctx into http headers, e.g. traceparent
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract returns a context carrying the remote span described by the http headers
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Carrier returns the trace context of ctx as a map,
+// it's used to propagate trace context through messages and caches
+func Carrier(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	if ctx != nil {
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+	}
+	return carrier
+}
+
+// FromCarrier is the reverse of Carrier
+func FromCarrier(carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+	shutdown, err := Init(Options{Exporter: EXPORTER_STDOUT, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	ctx, span := Start(context.Background(), "test", trace.SpanKindInternal)
+	defer span.End()
+
+	carrier := Carrier(ctx)
+	if carrier["traceparent"] == "" {
+		t.Fatal("traceparent is missing from carrier")
+	}
+
+	restored := trace.SpanContextFromContext(FromCarrier(carrier))
+	if restored.TraceID() != span.SpanContext().TraceID() {
+		t.Fatal("trace id is not restored from carrier")
+	}
+
+	header := http.Header{}
+	Inject(ctx, header)
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), header))
+	if extracted.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("span id is not restored from headers")
+	}
+}
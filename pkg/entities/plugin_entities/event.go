@@ -2,7 +2,9 @@ package plugin_entities
 
 import (
 	"encoding/json"
+	"errors"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 )
@@ -10,7 +12,32 @@ import (
 type PluginUniversalEvent struct {
 	SessionId string          `json:"session_id"`
 	Event     PluginEventType `json:"event"`
-	Data      json.RawMessage `json:"data"`
+	// raw data of the event, it stays cbor if the event is received in a cbor frame
+	Data json.RawMessage `json:"data"`
+}
+
+// cborUniversalEvent is a PluginUniversalEvent received in a cbor frame
+type cborUniversalEvent struct {
+	SessionId string          `cbor:"session_id"`
+	Event     PluginEventType `cbor:"event"`
+	Data      cbor.RawMessage `cbor:"data"`
+}
+
+// parsePluginUniversalEvent parses a json or cbor event, data of a cbor event is kept as cbor
+func parsePluginUniversalEvent(data []byte) (PluginUniversalEvent, error) {
+	if !parser.IsCborMap(data) {
+		return parser.UnmarshalJsonBytes[PluginUniversalEvent](data)
+	}
+
+	event, err := parser.UnmarshalCBOR[cborUniversalEvent](data)
+	if err != nil {
+		return PluginUniversalEvent{}, err
+	}
+	return PluginUniversalEvent{
+		SessionId: event.SessionId,
+		Event:     event.Event,
+		Data:      json.RawMessage(event.Data),
+	}, nil
 }
 
 // ParsePluginUniversalEvent parses bytes into struct contains basic info of a message
@@ -22,10 +49,10 @@ func ParsePluginUniversalEvent(
 	sessionHandler func(sessionId string, data []byte),
 	heartbeatHandler func(),
 	errorHandler func(err string),
-	infoHandler func(message string),
+	logHandler func(sessionId string, event PluginLogEvent),
 ) {
 	// handle event
-	event, err := parser.UnmarshalJsonBytes[PluginUniversalEvent](data)
+	event, err := parsePluginUniversalEvent(data)
 	if err != nil {
 		if len(data) > 1024 {
 			errorHandler(err.Error() + " status: " + statusText + " original response: " + string(data[:1024]) + "...")
@@ -40,7 +67,7 @@ func ParsePluginUniversalEvent(
 	switch event.Event {
 	case PLUGIN_EVENT_LOG:
 		if event.Event == PLUGIN_EVENT_LOG {
-			logEvent, err := parser.UnmarshalJsonBytes[PluginLogEvent](
+			logEvent, err := parser.UnmarshalJsonOrCborBytes[PluginLogEvent](
 				event.Data,
 			)
 			if err != nil {
@@ -48,12 +75,17 @@ func ParsePluginUniversalEvent(
 				return
 			}
 
-			infoHandler(logEvent.Message)
+			logHandler(sessionId, logEvent)
 		}
 	case PLUGIN_EVENT_SESSION:
 		sessionHandler(sessionId, event.Data)
 	case PLUGIN_EVENT_ERROR:
-		errorHandler(string(event.Data))
+		message, err := parser.CborToJson(event.Data)
+		if err != nil {
+			errorHandler(err.Error())
+			return
+		}
+		errorHandler(string(message))
 	case PLUGIN_EVENT_HEARTBEAT:
 		heartbeatHandler()
 	}
@@ -66,8 +98,21 @@ const (
 	PLUGIN_EVENT_SESSION   PluginEventType = "session"
 	PLUGIN_EVENT_ERROR     PluginEventType = "error"
 	PLUGIN_EVENT_HEARTBEAT PluginEventType = "heartbeat"
+	// sent by the daemon to acknowledge the wire protocol version requested in handshake
+	PLUGIN_EVENT_PROTOCOL PluginEventType = "protocol"
+	// sent by the daemon once a re-registration is applied or rejected
+	PLUGIN_EVENT_RE_REGISTER PluginEventType = "re_register"
 )
 
+type PluginProtocolEvent struct {
+	Version int `json:"version"`
+}
+
+type PluginReRegisterEvent struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
 type PluginLogEvent struct {
 	Level     string  `json:"level"`
 	Message   string  `json:"message"`
@@ -76,7 +121,31 @@ type PluginLogEvent struct {
 
 type SessionMessage struct {
 	Type SESSION_MESSAGE_TYPE `json:"type" validate:"required"`
-	Data json.RawMessage      `json:"data" validate:"required"`
+	// raw data of the message, it stays cbor if the message is received in a cbor frame
+	Data json.RawMessage `json:"data" validate:"required"`
+}
+
+// cborSessionMessage is a SessionMessage received in a cbor frame
+type cborSessionMessage struct {
+	Type SESSION_MESSAGE_TYPE `cbor:"type"`
+	Data cbor.RawMessage      `cbor:"data"`
+}
+
+// ParseSessionMessage parses a json or cbor session message, data of a cbor message is kept as cbor,
+// decode it with parser.UnmarshalJsonOrCborBytes
+func ParseSessionMessage(data []byte) (SessionMessage, error) {
+	if !parser.IsCborMap(data) {
+		return parser.UnmarshalJsonBytes[SessionMessage](data)
+	}
+
+	message, err := parser.UnmarshalCBOR[cborSessionMessage](data)
+	if err != nil {
+		return SessionMessage{}, err
+	}
+	if message.Type == "" || len(message.Data) == 0 {
+		return SessionMessage{}, errors.New("invalid session message, type and data are required")
+	}
+	return SessionMessage{Type: message.Type, Data: json.RawMessage(message.Data)}, nil
 }
 
 type SESSION_MESSAGE_TYPE string
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -29,6 +30,7 @@ type PluginPermissionRequirement struct {
 	Endpoint *PluginPermissionEndpointRequirement `json:"endpoint,omitempty" yaml:"endpoint,omitempty" validate:"omitempty"`
 	App      *PluginPermissionAppRequirement      `json:"app,omitempty" yaml:"app,omitempty" validate:"omitempty"`
 	Storage  *PluginPermissionStorageRequirement  `json:"storage,omitempty" yaml:"storage,omitempty" validate:"omitempty"`
+	Network  *PluginPermissionNetworkRequirement  `json:"network,omitempty" yaml:"network,omitempty" validate:"omitempty"`
 }
 
 func (p *PluginPermissionRequirement) AllowInvokeTool() bool {
@@ -79,6 +81,15 @@ func (p *PluginPermissionRequirement) AllowInvokeStorage() bool {
 	return p != nil && p.Storage != nil && p.Storage.Enabled
 }
 
+// NetworkDomains returns the domains the plugin declares to access, outbound requests to
+// other hosts are rejected by the egress proxy
+func (p *PluginPermissionRequirement) NetworkDomains() []string {
+	if p == nil || p.Network == nil {
+		return nil
+	}
+	return p.Network.Domains
+}
+
 type PluginPermissionToolRequirement struct {
 	Enabled bool `json:"enabled" yaml:"enabled"`
 }
@@ -110,6 +121,31 @@ type PluginPermissionStorageRequirement struct {
 	Size    uint64 `json:"size" yaml:"size" validate:"min=1024,max=1073741824"` // min 1024 bytes, max 1G
 }
 
+type PluginPermissionNetworkRequirement struct {
+	// exact hosts like api.openai.com, or *.openai.com to match all subdomains
+	Domains []string `json:"domains" yaml:"domains" validate:"omitempty,max=128,dive,network_domain"`
+}
+
+var networkDomainRegex = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func isNetworkDomain(fl validator.FieldLevel) bool {
+	domain := fl.Field().String()
+	return len(domain) <= 253 && networkDomainRegex.MatchString(domain)
+}
+
+// MatchNetworkDomain checks if host matches the domain pattern, *.example.com matches
+// subdomains of example.com but not example.com itself
+func MatchNetworkDomain(pattern string, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+
+	return host == pattern
+}
+
 type PluginResourceRequirement struct {
 	// Memory in bytes
 	Memory int64 `json:"memory" yaml:"memory" validate:"required"`
@@ -294,6 +330,7 @@ func (p *PluginDeclaration) FillInDefaultValues() {
 func init() {
 	// init validator
 	validators.GlobalEntitiesValidator.RegisterValidation("plugin_name", isPluginName)
+	validators.GlobalEntitiesValidator.RegisterValidation("network_domain", isNetworkDomain)
 }
 
 func UnmarshalPluginDeclarationFromYaml(data []byte) (*PluginDeclaration, error) {
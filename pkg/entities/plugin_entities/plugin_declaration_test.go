@@ -191,3 +191,48 @@ func TestPluginDeclarationIncorrectName(t *testing.T) {
 		return
 	}
 }
+
+func TestPluginNetworkDomains(t *testing.T) {
+	declaration := preparePluginDeclaration()
+	declaration.Resource.Permission.Network = &PluginPermissionNetworkRequirement{
+		Domains: []string{"api.openai.com", "*.azure.com"},
+	}
+	declarationBytes := parser.MarshalJsonBytes(declaration)
+
+	_, err := parser.UnmarshalJsonBytes[PluginDeclaration](declarationBytes)
+	if err != nil {
+		t.Errorf("failed to unmarshal network domains: %v", err)
+		return
+	}
+
+	for _, domain := range []string{"*", "https://api.openai.com", "api.*.com", "API.openai.com"} {
+		declaration.Resource.Permission.Network.Domains = []string{domain}
+		declarationBytes = parser.MarshalJsonBytes(declaration)
+
+		_, err = parser.UnmarshalJsonBytes[PluginDeclaration](declarationBytes)
+		if err == nil {
+			t.Errorf("domain %s should be rejected", domain)
+		}
+	}
+}
+
+func TestMatchNetworkDomain(t *testing.T) {
+	cases := []struct {
+		pattern string
+		host    string
+		match   bool
+	}{
+		{"api.openai.com", "api.openai.com", true},
+		{"api.openai.com", "API.OpenAI.com.", true},
+		{"api.openai.com", "openai.com", false},
+		{"*.azure.com", "east.openai.azure.com", true},
+		{"*.azure.com", "azure.com", false},
+		{"*.azure.com", "evilazure.com", false},
+	}
+
+	for _, c := range cases {
+		if MatchNetworkDomain(c.pattern, c.host) != c.match {
+			t.Errorf("MatchNetworkDomain(%s, %s) should be %v", c.pattern, c.host, c.match)
+		}
+	}
+}
@@ -18,6 +18,9 @@ const (
 	REGISTER_EVENT_TYPE_ENDPOINT_DECLARATION       RemotePluginRegisterEventType = "endpoint_declaration"
 	REGISTER_EVENT_TYPE_AGENT_STRATEGY_DECLARATION RemotePluginRegisterEventType = "agent_strategy_declaration"
 	REGISTER_EVENT_TYPE_END                        RemotePluginRegisterEventType = "end"
+	// sent after the plugin is initialized to start over the declarations and assets,
+	// the runtime keeps its identity and swaps in the new ones on the next end event
+	REGISTER_EVENT_TYPE_RE_REGISTER RemotePluginRegisterEventType = "re_register"
 )
 
 type RemotePluginRegisterAssetChunk struct {
@@ -28,6 +31,8 @@ type RemotePluginRegisterAssetChunk struct {
 
 type RemotePluginRegisterHandshake struct {
 	Key string `json:"key" validate:"required"`
+	// the highest wire protocol version the plugin speaks, json lines if not set
+	ProtocolVersion int `json:"protocol_version"`
 }
 
 type RemotePluginRegisterPayload struct {
@@ -45,6 +45,16 @@ type (
 		SetRestarting()
 		// set the plugin to pending
 		SetPending()
+		// stop or resume accepting new sessions, a draining plugin is stopped once in-flight sessions finished
+		SetDraining(draining bool)
+		// returns true if the plugin no longer accepts new sessions
+		Draining() bool
+		// stop restarting the plugin after it crashed repeatedly until it's released
+		SetQuarantined()
+		// returns true if the plugin is quarantined
+		Quarantined() bool
+		// record why the plugin exited
+		AddExitError(err string)
 		// set the active time of the plugin
 		SetActiveAt(t time.Time)
 		// set the scheduled time of the plugin
@@ -69,8 +79,8 @@ type (
 	PluginRuntimeSessionIOInterface interface {
 		PluginBasicInfoInterface
 
-		// Listen listens for messages from the plugin
-		Listen(session_id string) *entities.Broadcast[SessionMessage]
+		// Listen listens for messages from the plugin, fails if the plugin can not serve the session
+		Listen(session_id string) (*entities.Broadcast[SessionMessage], error)
 		// Write writes a message to the plugin
 		Write(session_id string, action access_types.PluginAccessAction, data []byte)
 		// Log adds a log to the plugin runtime state
@@ -81,6 +91,13 @@ type (
 		Error(string)
 	}
 
+	// PluginWireProtocolInterface is implemented by runtimes streaming messages with the plugin,
+	// plugins speaking version 2 send binary data without base64
+	PluginWireProtocolInterface interface {
+		// WireProtocolVersion returns the version spoken by the plugin process serving the session
+		WireProtocolVersion(session_id string) int
+	}
+
 	PluginClusterLifetime interface {
 		// stop the plugin
 		Stop()
@@ -168,6 +185,37 @@ func (r *PluginRuntime) SetPending() {
 	r.State.Status = PLUGIN_RUNTIME_STATUS_PENDING
 }
 
+func (r *PluginRuntime) SetDraining(draining bool) {
+	r.State.Draining = draining
+}
+
+func (r *PluginRuntime) Draining() bool {
+	return r.State.Draining
+}
+
+func (r *PluginRuntime) SetIdle() {
+	r.State.Status = PLUGIN_RUNTIME_STATUS_IDLE
+}
+
+func (r *PluginRuntime) SetQuarantined() {
+	r.State.Status = PLUGIN_RUNTIME_STATUS_QUARANTINED
+}
+
+func (r *PluginRuntime) Quarantined() bool {
+	return r.State.Status == PLUGIN_RUNTIME_STATUS_QUARANTINED
+}
+
+// AddExitError keeps the last MAX_PLUGIN_EXIT_ERRORS exit errors
+func (r *PluginRuntime) AddExitError(err string) {
+	r.State.ExitErrors = append(r.State.ExitErrors, PluginExitError{
+		Error:    err,
+		ExitedAt: time.Now(),
+	})
+	if len(r.State.ExitErrors) > MAX_PLUGIN_EXIT_ERRORS {
+		r.State.ExitErrors = r.State.ExitErrors[len(r.State.ExitErrors)-MAX_PLUGIN_EXIT_ERRORS:]
+	}
+}
+
 func (r *PluginRuntime) SetActiveAt(t time.Time) {
 	r.State.ActiveAt = &t
 }
@@ -219,8 +267,18 @@ type PluginRuntimeState struct {
 	Verified    bool       `json:"verified"`
 	ScheduledAt *time.Time `json:"scheduled_at"`
 	Logs        []string   `json:"logs"`
+	Draining    bool       `json:"draining"`
+	// most recent errors the plugin exited with, oldest first
+	ExitErrors []PluginExitError `json:"exit_errors"`
+}
+
+type PluginExitError struct {
+	Error    string    `json:"error"`
+	ExitedAt time.Time `json:"exited_at"`
 }
 
+const MAX_PLUGIN_EXIT_ERRORS = 10
+
 func (s *PluginRuntimeState) Hash() (uint64, error) {
 	buf := bytes.Buffer{}
 	enc := gob.NewEncoder(&buf)
@@ -243,4 +301,8 @@ const (
 	PLUGIN_RUNTIME_STATUS_STOPPED    = "stopped"
 	PLUGIN_RUNTIME_STATUS_RESTARTING = "restarting"
 	PLUGIN_RUNTIME_STATUS_PENDING    = "pending"
+	// no process is running until the plugin is requested
+	PLUGIN_RUNTIME_STATUS_IDLE = "idle"
+	// crashed too many times in a short period, not restarted until released
+	PLUGIN_RUNTIME_STATUS_QUARANTINED = "quarantined"
 )
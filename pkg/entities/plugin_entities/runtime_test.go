@@ -1,6 +1,7 @@
 package plugin_entities
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -48,3 +49,18 @@ func TestRuntimeStateHash(t *testing.T) {
 		return
 	}
 }
+
+func TestRuntimeExitErrors(t *testing.T) {
+	runtime := PluginRuntime{}
+	for i := 0; i < MAX_PLUGIN_EXIT_ERRORS+2; i++ {
+		runtime.AddExitError(fmt.Sprintf("error %d", i))
+	}
+
+	if len(runtime.State.ExitErrors) != MAX_PLUGIN_EXIT_ERRORS {
+		t.Fatalf("expected %d exit errors, got %d", MAX_PLUGIN_EXIT_ERRORS, len(runtime.State.ExitErrors))
+	}
+
+	if runtime.State.ExitErrors[0].Error != "error 2" {
+		t.Fatalf("oldest exit errors should be dropped, got %s", runtime.State.ExitErrors[0].Error)
+	}
+}
@@ -2,4 +2,22 @@ package requests
 
 type RequestGetRemoteDebuggingKey struct {
 	TenantID string `uri:"tenant_id" validate:"required"`
+	// the key is shared by the tenant if no user is given
+	UserID string `json:"user_id" validate:"omitempty,max=127"`
+}
+
+type RequestListRemoteDebuggingKeys struct {
+	TenantID string `uri:"tenant_id" validate:"required"`
+}
+
+type RequestRevokeRemoteDebuggingKey struct {
+	TenantID string `uri:"tenant_id" validate:"required"`
+	KeyID    string `json:"key_id" validate:"required"`
+}
+
+type RequestListRemoteDebuggingAudits struct {
+	TenantID string `uri:"tenant_id" validate:"required"`
+	KeyID    string `form:"key_id" validate:"omitempty"`
+	Page     int    `form:"page" validate:"required,min=1"`
+	PageSize int    `form:"page_size" validate:"required,min=1,max=100"`
 }
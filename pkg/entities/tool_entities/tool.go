@@ -53,6 +53,9 @@ type ToolResponseChunk struct {
 	Meta    map[string]any        `json:"meta"`
 }
 
+// CborDecodable keeps blobs of chunks received in cbor frames as bytes, agent strategy chunks embed it
+func (ToolResponseChunk) CborDecodable() {}
+
 type GetToolRuntimeParametersResponse struct {
 	Parameters []plugin_entities.ToolParameter `json:"parameters"`
 }
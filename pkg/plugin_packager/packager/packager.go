@@ -4,6 +4,8 @@ import (
 	"archive/zip"
 	"bytes"
 	"errors"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -12,8 +14,9 @@ import (
 )
 
 type Packager struct {
-	decoder  decoder.PluginDecoder
-	manifest string // manifest file path
+	decoder   decoder.PluginDecoder
+	manifest  string // manifest file path
+	vendorDir string // wheels downloaded by Vendor, empty if not vendored
 }
 
 func NewPackager(decoder decoder.PluginDecoder) *Packager {
@@ -34,13 +37,7 @@ func (p *Packager) Pack(maxSize int64) ([]byte, error) {
 
 	totalSize := int64(0)
 
-	err = p.decoder.Walk(func(filename, dir string) error {
-		fullPath := filepath.Join(dir, filename)
-		file, err := p.decoder.ReadFile(fullPath)
-		if err != nil {
-			return err
-		}
-
+	addFile := func(fullPath string, file []byte) error {
 		totalSize += int64(len(file))
 		if totalSize > maxSize {
 			return errors.New("plugin package size is too large, please ensure the uncompressed size is less than " + strconv.FormatInt(maxSize, 10) + " bytes")
@@ -61,12 +58,52 @@ func (p *Packager) Pack(maxSize int64) ([]byte, error) {
 		}
 
 		return nil
+	}
+
+	err = p.decoder.Walk(func(filename, dir string) error {
+		fullPath := filepath.Join(dir, filename)
+
+		// vendored wheels replace the ones in the plugin directory
+		if p.vendorDir != "" && strings.HasPrefix(filepath.ToSlash(fullPath), VENDOR_WHEELS_DIR+"/") {
+			return nil
+		}
+
+		file, err := p.decoder.ReadFile(fullPath)
+		if err != nil {
+			return err
+		}
+
+		return addFile(fullPath, file)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if p.vendorDir != "" {
+		err = filepath.WalkDir(filepath.Join(p.vendorDir, VENDOR_WHEELS_DIR), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			relPath, err := filepath.Rel(p.vendorDir, path)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			return addFile(relPath, file)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = zipWriter.Close()
 	if err != nil {
 		return nil, err
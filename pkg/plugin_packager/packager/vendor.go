@@ -0,0 +1,137 @@
+package packager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+)
+
+// wheels are vendored into wheels/<arch> of the package, e.g. wheels/amd64,
+// the local runtime installs from there without accessing any index
+const VENDOR_WHEELS_DIR = "wheels"
+
+// platform tags accepted for each arch, newest first
+var vendorPlatforms = map[constants.Arch][]string{
+	constants.AMD64: {
+		"manylinux_2_28_x86_64",
+		"manylinux_2_17_x86_64",
+		"manylinux2014_x86_64",
+		"manylinux1_x86_64",
+		"linux_x86_64",
+	},
+	constants.ARM64: {
+		"manylinux_2_28_aarch64",
+		"manylinux_2_17_aarch64",
+		"manylinux2014_aarch64",
+		"linux_aarch64",
+	},
+}
+
+type VendorOptions struct {
+	// python interpreter with pip installed, python3 if empty
+	PythonPath string
+	// index to download wheels from, pypi if empty
+	IndexUrl string
+}
+
+// Vendor downloads wheels of requirements.txt for every arch declared in the manifest,
+// they're packed into the package by Pack, Cleanup should be called afterwards
+func (p *Packager) Vendor(options VendorOptions) error {
+	manifest, err := p.fetchManifest()
+	if err != nil {
+		return err
+	}
+
+	if manifest.Meta.Runner.Language != constants.Python {
+		return fmt.Errorf("vendoring is only supported for python plugins, got: %s", manifest.Meta.Runner.Language)
+	}
+
+	requirements, err := p.decoder.ReadFile("requirements.txt")
+	if err != nil {
+		return fmt.Errorf("failed to read requirements.txt: %s", err)
+	}
+
+	if options.PythonPath == "" {
+		options.PythonPath = "python3"
+	}
+
+	vendorDir, err := os.MkdirTemp("", "dify-plugin-vendor-*")
+	if err != nil {
+		return err
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			os.RemoveAll(vendorDir)
+		}
+	}()
+
+	requirementsPath := filepath.Join(vendorDir, "requirements.txt")
+	if err := os.WriteFile(requirementsPath, requirements, 0644); err != nil {
+		return err
+	}
+
+	for _, arch := range manifest.Meta.Arch {
+		dest := filepath.Join(vendorDir, VENDOR_WHEELS_DIR, string(arch))
+		args, err := pipDownloadArgs(requirementsPath, arch, manifest.Meta.Runner.Version, dest, options.IndexUrl)
+		if err != nil {
+			return err
+		}
+
+		cmd := exec.Command(options.PythonPath, args...)
+		output := bytes.NewBuffer(nil)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to download wheels for %s: %s, output: %s", arch, err, output.String())
+		}
+	}
+
+	os.Remove(requirementsPath)
+	p.vendorDir = vendorDir
+	success = true
+	return nil
+}
+
+// Cleanup removes the wheels downloaded by Vendor
+func (p *Packager) Cleanup() {
+	if p.vendorDir != "" {
+		os.RemoveAll(p.vendorDir)
+		p.vendorDir = ""
+	}
+}
+
+// pipDownloadArgs only accepts binary wheels, a dependency without a wheel for the arch fails the download
+// instead of an sdist which needs to be built on the target machine
+func pipDownloadArgs(
+	requirementsPath string, arch constants.Arch, pythonVersion string, dest string, indexUrl string,
+) ([]string, error) {
+	platforms, ok := vendorPlatforms[arch]
+	if !ok {
+		return nil, fmt.Errorf("unsupported arch: %s", arch)
+	}
+
+	args := []string{
+		"-m", "pip", "download",
+		"-r", requirementsPath,
+		"-d", dest,
+		"--only-binary=:all:",
+		"--implementation", "cp",
+		"--python-version", pythonVersion,
+	}
+
+	for _, platform := range platforms {
+		args = append(args, "--platform", platform)
+	}
+
+	if indexUrl != "" {
+		args = append(args, "-i", indexUrl)
+	}
+
+	return args, nil
+}
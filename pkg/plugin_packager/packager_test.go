@@ -4,6 +4,7 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -233,3 +234,62 @@ func TestWrongSign(t *testing.T) {
 		return
 	}
 }
+
+func TestVendorWheels(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "manifest.yaml"), manifest, 0644)
+	os.WriteFile(filepath.Join(root, "neko.yaml"), neko, 0644)
+	os.WriteFile(filepath.Join(root, "requirements.txt"), []byte("dify_plugin==0.0.1b70\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "_assets"), 0755)
+	os.WriteFile(filepath.Join(root, "_assets/test.svg"), test_svg, 0644)
+	// stale wheels in the plugin directory should be replaced
+	os.MkdirAll(filepath.Join(root, "wheels/amd64"), 0755)
+	os.WriteFile(filepath.Join(root, "wheels/amd64/stale-0.1-py3-none-any.whl"), []byte("stale"), 0644)
+
+	// a fake python which writes a wheel named after the first platform into the destination
+	python := filepath.Join(t.TempDir(), "python")
+	os.WriteFile(python, []byte(`#!/bin/sh
+while [ $# -gt 0 ]; do
+	case "$1" in
+		-d) dest="$2"; shift ;;
+		--platform) [ -z "$platform" ] && platform="$2"; shift ;;
+		--only-binary=:all:) binary=1 ;;
+	esac
+	shift
+done
+[ -n "$binary" ] || exit 1
+mkdir -p "$dest" && echo "$platform" > "$dest/dify_plugin-0.0.1b70-py3-none-$platform.whl"
+`), 0755)
+
+	originDecoder, err := decoder.NewFSPluginDecoder(root)
+	if err != nil {
+		t.Fatalf("failed to create decoder: %s", err.Error())
+	}
+
+	p := packager.NewPackager(originDecoder)
+	if err := p.Vendor(packager.VendorOptions{PythonPath: python}); err != nil {
+		t.Fatalf("failed to vendor: %s", err.Error())
+	}
+	defer p.Cleanup()
+
+	zip, err := p.Pack(52428800)
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+
+	zipDecoder, err := decoder.NewZipPluginDecoder(zip)
+	if err != nil {
+		t.Fatalf("failed to create zip decoder: %s", err.Error())
+	}
+
+	for arch, platform := range map[string]string{"amd64": "manylinux_2_28_x86_64", "arm64": "manylinux_2_28_aarch64"} {
+		wheel := fmt.Sprintf("wheels/%s/dify_plugin-0.0.1b70-py3-none-%s.whl", arch, platform)
+		if _, err := zipDecoder.ReadFile(wheel); err != nil {
+			t.Errorf("%s should be vendored: %s", wheel, err.Error())
+		}
+	}
+
+	if _, err := zipDecoder.ReadFile("wheels/amd64/stale-0.1-py3-none-any.whl"); err == nil {
+		t.Errorf("stale wheels should not be packed")
+	}
+}
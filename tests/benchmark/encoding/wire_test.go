@@ -0,0 +1,46 @@
+package encoding
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/wire_protocol"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/tests"
+)
+
+func BenchmarkWireProtocolBlob(b *testing.B) {
+	blob := make([]byte, 1024*1024)
+	rand.Read(blob)
+
+	line, _ := json.Marshal(map[string]any{
+		"event": "session",
+		"data":  map[string]any{"blob": base64.StdEncoding.EncodeToString(blob)},
+	})
+	line = append(line, '\n')
+
+	payload, _ := parser.MarshalCBOR(map[string]any{
+		"event": "session",
+		"data":  map[string]any{"blob": blob},
+	})
+	frame, _ := wire_protocol.EncodeFrame(wire_protocol.FRAME_KIND_CBOR, payload)
+
+	b.Log("JSON line size:", tests.ReadableBytes(len(line)))
+	b.Log("CBOR frame size:", tests.ReadableBytes(len(frame)))
+
+	b.Run("JsonLine", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decoder := wire_protocol.Decoder{}
+			decoder.Feed(line)
+		}
+	})
+
+	b.Run("CborFrame", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			decoder := wire_protocol.Decoder{}
+			decoder.Feed(frame)
+		}
+	})
+}